@@ -0,0 +1,227 @@
+package evaluation
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+)
+
+const (
+	// DefaultBigSegmentCacheCapacity is the default value for BigSegmentCacheOptionCapacity.
+	DefaultBigSegmentCacheCapacity = 1000
+	// DefaultBigSegmentCacheTTL is the default value for BigSegmentCacheOptionTTL.
+	DefaultBigSegmentCacheTTL = 5 * time.Second
+)
+
+// BigSegmentCacheOption is an optional parameter for NewCachingBigSegmentProvider.
+type BigSegmentCacheOption interface {
+	apply(c *cachingBigSegmentProvider)
+}
+
+type bigSegmentCacheOptionCapacity int
+
+func (o bigSegmentCacheOptionCapacity) apply(c *cachingBigSegmentProvider) {
+	if o > 0 {
+		c.capacity = int(o)
+	}
+}
+
+// BigSegmentCacheOptionCapacity is an option for NewCachingBigSegmentProvider that sets the maximum
+// number of context keys whose membership is cached. When the cache is full, the least recently used
+// key is discarded. The default is DefaultBigSegmentCacheCapacity; values less than 1 are ignored.
+func BigSegmentCacheOptionCapacity(capacity int) BigSegmentCacheOption {
+	return bigSegmentCacheOptionCapacity(capacity)
+}
+
+type bigSegmentCacheOptionTTL time.Duration
+
+func (o bigSegmentCacheOptionTTL) apply(c *cachingBigSegmentProvider) {
+	if o > 0 {
+		c.ttl = time.Duration(o)
+	}
+}
+
+// BigSegmentCacheOptionTTL is an option for NewCachingBigSegmentProvider that sets how long a
+// membership result is considered fresh. The default is DefaultBigSegmentCacheTTL; values less than
+// or equal to zero are ignored.
+func BigSegmentCacheOptionTTL(ttl time.Duration) BigSegmentCacheOption {
+	return bigSegmentCacheOptionTTL(ttl)
+}
+
+type bigSegmentCacheOptionStaleWhileRevalidate time.Duration
+
+func (o bigSegmentCacheOptionStaleWhileRevalidate) apply(c *cachingBigSegmentProvider) {
+	c.staleWhileRevalidate = time.Duration(o)
+}
+
+// BigSegmentCacheOptionStaleWhileRevalidate is an option for NewCachingBigSegmentProvider that allows
+// a result to be used for some time after its TTL has expired.
+//
+// During that time, a query for the same context key returns the cached result immediately, with the
+// status ldreason.BigSegmentsStale, and starts a query to the underlying provider on another goroutine
+// to refresh the cache. After that time, or if this option is not set, a query for an expired key
+// waits for the underlying provider. Use CachingBigSegmentProvider.Close to stop the refreshes.
+func BigSegmentCacheOptionStaleWhileRevalidate(duration time.Duration) BigSegmentCacheOption {
+	return bigSegmentCacheOptionStaleWhileRevalidate(duration)
+}
+
+type cachingBigSegmentProvider struct {
+	provider             BigSegmentProvider
+	capacity             int
+	ttl                  time.Duration
+	staleWhileRevalidate time.Duration
+	now                  func() time.Time
+	lock                 sync.Mutex
+	entries              map[string]*list.Element
+	lru                  *list.List // elements are *bigSegmentCacheEntry; most recently used is at the front
+	// fetches are the queries in progress for keys that could not be answered from the cache.
+	fetches   map[string]*bigSegmentFetch
+	refreshes sync.WaitGroup // background refreshes in progress
+	closed    bool
+}
+
+// bigSegmentFetch is a query to the underlying provider that other queries for the same key can wait
+// for, rather than querying the provider again.
+type bigSegmentFetch struct {
+	done       chan struct{}
+	membership BigSegmentMembership
+	status     ldreason.BigSegmentsStatus
+}
+
+type bigSegmentCacheEntry struct {
+	contextKey string
+	membership BigSegmentMembership
+	status     ldreason.BigSegmentsStatus
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// NewCachingBigSegmentProvider creates a BigSegmentProvider that caches the results of another
+// BigSegmentProvider by context key, so that they can be reused across evaluations.
+//
+// The status returned by the underlying provider is cached along with the membership, and is
+// returned for every query that uses the cached result. If the result is used after its TTL has
+// expired (see BigSegmentCacheOptionStaleWhileRevalidate), its status is reported as
+// ldreason.BigSegmentsStale unless the cached status was already a more serious problem. A result
+// with the status ldreason.BigSegmentsStoreError is never cached, so the next query for the same key
+// will try again; if a background refresh gets that status, the previous result is kept.
+//
+// If several queries for the same key need to wait for the underlying provider at the same time, only
+// the first one queries it, and the others get the same result.
+//
+// The returned provider is safe for concurrent use.
+func NewCachingBigSegmentProvider(
+	provider BigSegmentProvider,
+	options ...BigSegmentCacheOption,
+) CachingBigSegmentProvider {
+	c := &cachingBigSegmentProvider{
+		provider: provider,
+		capacity: DefaultBigSegmentCacheCapacity,
+		ttl:      DefaultBigSegmentCacheTTL,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		fetches:  make(map[string]*bigSegmentFetch),
+	}
+	for _, o := range options {
+		if o != nil {
+			o.apply(c)
+		}
+	}
+	return c
+}
+
+func (c *cachingBigSegmentProvider) GetMembership(
+	contextKey string,
+) (BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	c.lock.Lock()
+	if elem, ok := c.entries[contextKey]; ok {
+		entry := elem.Value.(*bigSegmentCacheEntry)
+		age := c.now().Sub(entry.fetchedAt)
+		if age < c.ttl {
+			c.lru.MoveToFront(elem)
+			membership, status := entry.membership, entry.status
+			c.lock.Unlock()
+			return membership, status
+		}
+		if age < c.ttl+c.staleWhileRevalidate && !c.closed {
+			c.lru.MoveToFront(elem)
+			membership := entry.membership
+			status := computeUpdatedBigSegmentsStatus(entry.status, ldreason.BigSegmentsStale)
+			startRefresh := !entry.refreshing
+			if startRefresh {
+				entry.refreshing = true
+				c.refreshes.Add(1)
+			}
+			c.lock.Unlock()
+			if startRefresh {
+				go c.refresh(contextKey)
+			}
+			return membership, status
+		}
+	}
+	if fetch, ok := c.fetches[contextKey]; ok {
+		c.lock.Unlock()
+		<-fetch.done
+		return fetch.membership, fetch.status
+	}
+	fetch := &bigSegmentFetch{done: make(chan struct{})}
+	c.fetches[contextKey] = fetch
+	c.lock.Unlock()
+
+	fetch.membership, fetch.status = c.provider.GetMembership(contextKey)
+	c.lock.Lock()
+	c.store(contextKey, fetch.membership, fetch.status)
+	delete(c.fetches, contextKey)
+	c.lock.Unlock()
+	close(fetch.done)
+	return fetch.membership, fetch.status
+}
+
+func (c *cachingBigSegmentProvider) refresh(contextKey string) {
+	defer c.refreshes.Done()
+	membership, status := c.provider.GetMembership(contextKey)
+	c.lock.Lock()
+	c.store(contextKey, membership, status)
+	c.lock.Unlock()
+}
+
+// Close stops any more background refreshes from starting, and waits for the ones that are in
+// progress. See CachingBigSegmentProvider.
+func (c *cachingBigSegmentProvider) Close() {
+	c.lock.Lock()
+	c.closed = true
+	c.lock.Unlock()
+	c.refreshes.Wait()
+}
+
+// store updates the cache with a result from the underlying provider. It must be called with the
+// lock held.
+func (c *cachingBigSegmentProvider) store(
+	contextKey string,
+	membership BigSegmentMembership,
+	status ldreason.BigSegmentsStatus,
+) {
+	elem, exists := c.entries[contextKey]
+	if status == ldreason.BigSegmentsStoreError {
+		if exists {
+			elem.Value.(*bigSegmentCacheEntry).refreshing = false
+		}
+		return
+	}
+	entry := &bigSegmentCacheEntry{contextKey: contextKey, membership: membership, status: status,
+		fetchedAt: c.now()}
+	if exists {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[contextKey] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*bigSegmentCacheEntry).contextKey)
+	}
+}
@@ -0,0 +1,338 @@
+package evaluation
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBigSegmentProvider is safe for concurrent use, unlike simpleBigSegmentProvider, since the
+// caching provider may query it from another goroutine.
+type countingBigSegmentProvider struct {
+	lock    sync.Mutex
+	status  ldreason.BigSegmentsStatus
+	queries map[string]int
+	queried chan string
+	// If release is not nil, each query waits to receive from it before returning.
+	release chan struct{}
+}
+
+func newCountingBigSegmentProvider(status ldreason.BigSegmentsStatus) *countingBigSegmentProvider {
+	return &countingBigSegmentProvider{status: status, queries: make(map[string]int),
+		queried: make(chan string, 10)}
+}
+
+// singleRefMembership includes only the specified segment ref.
+type singleRefMembership string
+
+func (m singleRefMembership) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	if segmentRef == string(m) {
+		return ldvalue.NewOptionalBool(true)
+	}
+	return ldvalue.OptionalBool{}
+}
+
+func (p *countingBigSegmentProvider) GetMembership(
+	contextKey string,
+) (BigSegmentMembership, ldreason.BigSegmentsStatus) {
+	p.lock.Lock()
+	p.queries[contextKey]++
+	// Each query for the same key returns a different result, so we can tell which one was cached.
+	membership := singleRefMembership(fmt.Sprintf("%s-%d", contextKey, p.queries[contextKey]))
+	status := p.status
+	p.lock.Unlock()
+	p.queried <- contextKey
+	if p.release != nil {
+		<-p.release
+	}
+	return membership, status
+}
+
+func (p *countingBigSegmentProvider) setStatus(status ldreason.BigSegmentsStatus) {
+	p.lock.Lock()
+	p.status = status
+	p.lock.Unlock()
+}
+
+func (p *countingBigSegmentProvider) timesQueried(contextKey string) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.queries[contextKey]
+}
+
+type fakeClock struct {
+	lock sync.Mutex
+	t    time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.lock.Lock()
+	c.t = c.t.Add(d)
+	c.lock.Unlock()
+}
+
+func (p *countingBigSegmentProvider) releaseQuery(t *testing.T) {
+	select {
+	case p.release <- struct{}{}:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting to release query")
+	}
+}
+
+func makeCachingBigSegmentProvider(
+	provider BigSegmentProvider,
+	options ...BigSegmentCacheOption,
+) (*cachingBigSegmentProvider, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	c := NewCachingBigSegmentProvider(provider, options...).(*cachingBigSegmentProvider)
+	c.now = clock.now
+	return c, clock
+}
+
+func requireMembershipIncludes(t *testing.T, membership BigSegmentMembership, segmentRef string) {
+	require.NotNil(t, membership)
+	assert.Equal(t, ldvalue.NewOptionalBool(true), membership.CheckMembership(segmentRef))
+}
+
+func awaitQuery(t *testing.T, p *countingBigSegmentProvider) {
+	select {
+	case <-p.queried:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for query")
+	}
+}
+
+func TestCachingBigSegmentProviderReusesResultWithinTTL(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute))
+
+	m1, status1 := c.GetMembership("a")
+	clock.advance(time.Second * 59)
+	m2, status2 := c.GetMembership("a")
+
+	assert.Equal(t, 1, p.timesQueried("a"))
+	requireMembershipIncludes(t, m1, "a-1")
+	assert.Equal(t, m1, m2)
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status1)
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status2)
+}
+
+func TestCachingBigSegmentProviderQueriesAgainAfterTTL(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute))
+
+	_, _ = c.GetMembership("a")
+	clock.advance(time.Minute)
+	m, status := c.GetMembership("a")
+
+	assert.Equal(t, 2, p.timesQueried("a"))
+	requireMembershipIncludes(t, m, "a-2")
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status)
+}
+
+func TestCachingBigSegmentProviderStaleWhileRevalidate(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute),
+		BigSegmentCacheOptionStaleWhileRevalidate(time.Minute))
+
+	_, _ = c.GetMembership("a")
+	awaitQuery(t, p)
+	clock.advance(time.Minute + time.Second)
+
+	m, status := c.GetMembership("a")
+	requireMembershipIncludes(t, m, "a-1")
+	assert.Equal(t, ldreason.BigSegmentsStale, status)
+	awaitQuery(t, p) // the background refresh
+
+	assert.Eventually(t, func() bool {
+		m, status := c.GetMembership("a")
+		return status == ldreason.BigSegmentsHealthy && m.CheckMembership("a-2").IsDefined()
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 2, p.timesQueried("a"))
+}
+
+func TestCachingBigSegmentProviderDoesNotServeStaleResultAfterWindow(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute),
+		BigSegmentCacheOptionStaleWhileRevalidate(time.Minute))
+
+	_, _ = c.GetMembership("a")
+	clock.advance(2 * time.Minute)
+	m, status := c.GetMembership("a")
+
+	requireMembershipIncludes(t, m, "a-2")
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status)
+}
+
+func TestCachingBigSegmentProviderPassesThroughStatus(t *testing.T) {
+	statuses := []ldreason.BigSegmentsStatus{ldreason.BigSegmentsStale, ldreason.BigSegmentsNotConfigured}
+	for _, status := range statuses {
+		t.Run(string(status), func(t *testing.T) {
+			p := newCountingBigSegmentProvider(status)
+			c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute),
+				BigSegmentCacheOptionStaleWhileRevalidate(time.Minute))
+
+			_, status1 := c.GetMembership("a")
+			_, status2 := c.GetMembership("a")
+			clock.advance(time.Minute + time.Second)
+			_, status3 := c.GetMembership("a") // served past TTL, which can't make the status any better
+
+			assert.Equal(t, 1, p.timesQueried("a"))
+			assert.Equal(t, status, status1)
+			assert.Equal(t, status, status2)
+			assert.Equal(t, status, status3)
+		})
+	}
+}
+
+func TestCachingBigSegmentProviderDoesNotCacheStoreError(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsStoreError)
+	c, _ := makeCachingBigSegmentProvider(p)
+
+	_, status1 := c.GetMembership("a")
+	p.setStatus(ldreason.BigSegmentsHealthy)
+	m, status2 := c.GetMembership("a")
+
+	assert.Equal(t, 2, p.timesQueried("a"))
+	assert.Equal(t, ldreason.BigSegmentsStoreError, status1)
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status2)
+	requireMembershipIncludes(t, m, "a-2")
+}
+
+func TestCachingBigSegmentProviderKeepsStaleResultIfRefreshFails(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute),
+		BigSegmentCacheOptionStaleWhileRevalidate(time.Minute))
+
+	_, _ = c.GetMembership("a")
+	awaitQuery(t, p)
+	p.setStatus(ldreason.BigSegmentsStoreError)
+	clock.advance(time.Minute + time.Second)
+	_, _ = c.GetMembership("a")
+	awaitQuery(t, p) // the failed background refresh
+
+	assert.Eventually(t, func() bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return !c.entries["a"].Value.(*bigSegmentCacheEntry).refreshing
+	}, time.Second, time.Millisecond)
+	m, status := c.GetMembership("a")
+	requireMembershipIncludes(t, m, "a-1")
+	assert.Equal(t, ldreason.BigSegmentsStale, status)
+}
+
+func TestCachingBigSegmentProviderQueriesOnceForConcurrentMisses(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	p.release = make(chan struct{})
+	c, _ := makeCachingBigSegmentProvider(p)
+
+	results := make(chan BigSegmentMembership, 5)
+	getMembership := func() {
+		m, _ := c.GetMembership("a")
+		results <- m
+	}
+	go getMembership()
+	awaitQuery(t, p) // now the first query is in progress, so the others will wait for it
+	for i := 0; i < 4; i++ {
+		go getMembership()
+	}
+	// Give the other queries time to start. If any of them queried the provider too, it would never be
+	// released, so we would time out below.
+	time.Sleep(time.Millisecond * 50)
+	p.releaseQuery(t)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case m := <-results:
+			requireMembershipIncludes(t, m, "a-1")
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for result")
+		}
+	}
+	assert.Equal(t, 1, p.timesQueried("a"))
+}
+
+func TestCachingBigSegmentProviderCloseStopsRefreshes(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, clock := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionTTL(time.Minute),
+		BigSegmentCacheOptionStaleWhileRevalidate(time.Minute))
+
+	_, _ = c.GetMembership("a")
+	awaitQuery(t, p)
+	p.release = make(chan struct{})
+	clock.advance(time.Minute + time.Second)
+	_, _ = c.GetMembership("a") // starts a background refresh, which won't finish until it's released
+	awaitQuery(t, p)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		require.Fail(t, "Close returned before the refresh finished")
+	case <-time.After(time.Millisecond * 50):
+	}
+	p.releaseQuery(t)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		require.Fail(t, "timed out waiting for Close")
+	}
+
+	// After Close, an expired result is not used, so this query waits for the provider.
+	p.release = nil
+	clock.advance(time.Minute + time.Second)
+	m, status := c.GetMembership("a")
+	awaitQuery(t, p)
+	requireMembershipIncludes(t, m, "a-3")
+	assert.Equal(t, ldreason.BigSegmentsHealthy, status)
+	assert.Equal(t, 3, p.timesQueried("a"))
+}
+
+func TestCachingBigSegmentProviderEvictsLeastRecentlyUsedKey(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	c, _ := makeCachingBigSegmentProvider(p, BigSegmentCacheOptionCapacity(2))
+
+	_, _ = c.GetMembership("a")
+	_, _ = c.GetMembership("b")
+	_, _ = c.GetMembership("a") // now "b" is the least recently used
+	_, _ = c.GetMembership("c")
+	_, _ = c.GetMembership("a")
+	_, _ = c.GetMembership("b")
+
+	assert.Equal(t, 1, p.timesQueried("a"))
+	assert.Equal(t, 2, p.timesQueried("b"))
+	assert.Equal(t, 1, p.timesQueried("c"))
+}
+
+func TestCachingBigSegmentProviderWithEvaluator(t *testing.T) {
+	p := newCountingBigSegmentProvider(ldreason.BigSegmentsHealthy)
+	segment := ldbuilders.NewSegmentBuilder("big-segment").Unbounded(true).Generation(1).Build()
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredSegments(segment),
+		EvaluatorOptionBigSegmentProvider(NewCachingBigSegmentProvider(p)))
+	flag := makeBooleanFlagToMatchAnyOfSegments(segment.Key)
+	context := ldcontext.New("a")
+
+	for i := 0; i < 3; i++ {
+		result := evaluator.Evaluate(&flag, context, nil)
+		assert.Equal(t, ldreason.BigSegmentsHealthy, result.Detail.Reason.GetBigSegmentsStatus())
+	}
+	assert.Equal(t, 1, p.timesQueried("a"))
+}
@@ -0,0 +1,165 @@
+package evaluation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// MakeBigSegmentRef returns the string that identifies a specific generation of a big segment in
+// BigSegmentMembership.CheckMembership. This is the segment key followed by ".g" and the generation
+// number, such as "my-segment.g2". See ParseBigSegmentRef.
+func MakeBigSegmentRef(segmentKey string, generation int) string {
+	return segmentKey + ".g" + strconv.Itoa(generation)
+}
+
+// ParseBigSegmentRef splits a string created by MakeBigSegmentRef back into the segment key and the
+// generation. It returns false if the string is not in that format.
+func ParseBigSegmentRef(segmentRef string) (segmentKey string, generation int, ok bool) {
+	i := strings.LastIndex(segmentRef, ".g")
+	if i < 0 {
+		return "", 0, false
+	}
+	generationStr := segmentRef[i+2:]
+	generation, err := strconv.Atoi(generationStr)
+	if err != nil || generation < 0 || strconv.Itoa(generation) != generationStr { // must be canonical
+		return "", 0, false
+	}
+	return segmentRef[:i], generation, true
+}
+
+type mapBigSegmentMembership map[string]bool
+
+// NewMapBigSegmentMembership creates a BigSegmentMembership that is backed by a map. The parameters
+// are lists of segment refs (see MakeBigSegmentRef) that the context is included in and excluded
+// from. If a ref is in both lists, the context is considered to be included.
+func NewMapBigSegmentMembership(includedRefs, excludedRefs []string) BigSegmentMembership {
+	m := make(mapBigSegmentMembership, len(includedRefs)+len(excludedRefs))
+	for _, ref := range excludedRefs {
+		m[ref] = false
+	}
+	for _, ref := range includedRefs {
+		m[ref] = true
+	}
+	return m
+}
+
+func (m mapBigSegmentMembership) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	if included, ok := m[segmentRef]; ok {
+		return ldvalue.NewOptionalBool(included)
+	}
+	return ldvalue.OptionalBool{}
+}
+
+type sortedBigSegmentMembership struct {
+	refs     []string
+	included []bool // same indices as refs
+}
+
+// NewSortedBigSegmentMembership creates a BigSegmentMembership that is backed by a sorted slice, which
+// uses less memory than NewMapBigSegmentMembership but takes O(log n) time for each query. The
+// parameters have the same meaning as for NewMapBigSegmentMembership.
+func NewSortedBigSegmentMembership(includedRefs, excludedRefs []string) BigSegmentMembership {
+	all := make(map[string]bool, len(includedRefs)+len(excludedRefs))
+	for _, ref := range excludedRefs {
+		all[ref] = false
+	}
+	for _, ref := range includedRefs {
+		all[ref] = true
+	}
+	m := sortedBigSegmentMembership{refs: make([]string, 0, len(all)), included: make([]bool, len(all))}
+	for ref := range all {
+		m.refs = append(m.refs, ref)
+	}
+	sort.Strings(m.refs)
+	for i, ref := range m.refs {
+		m.included[i] = all[ref]
+	}
+	return m
+}
+
+func (m sortedBigSegmentMembership) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	i := sort.SearchStrings(m.refs, segmentRef)
+	if i < len(m.refs) && m.refs[i] == segmentRef {
+		return ldvalue.NewOptionalBool(m.included[i])
+	}
+	return ldvalue.OptionalBool{}
+}
+
+type bloomBigSegmentMembership struct {
+	bits      []uint64
+	numBits   uint64
+	numHashes int
+}
+
+// NewBloomBigSegmentMembership creates a BigSegmentMembership that is backed by a Bloom filter. This
+// is only suitable for a very large set of inclusions, where the memory used by the other
+// implementations would be a problem, and where it is acceptable for a context to sometimes be
+// considered included in a segment that it is not really included in.
+//
+// CheckMembership returns true for every ref in includedRefs, and also for a fraction of other refs
+// that is approximately falsePositiveRate. For any other ref, it returns an undefined value; this
+// implementation cannot represent exclusions. If falsePositiveRate is not between 0 and 1, it is
+// treated as 0.01.
+func NewBloomBigSegmentMembership(includedRefs []string, falsePositiveRate float64) BigSegmentMembership {
+	if !(falsePositiveRate > 0 && falsePositiveRate < 1) {
+		falsePositiveRate = 0.01
+	}
+	// These are the standard formulas for the optimal size and number of hash functions.
+	n := math.Max(float64(len(includedRefs)), 1)
+	numBits := uint64(math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	if numBits < 64 {
+		numBits = 64
+	}
+	m := bloomBigSegmentMembership{
+		bits:      make([]uint64, (numBits+63)/64),
+		numBits:   numBits,
+		numHashes: int(math.Max(1, math.Round(float64(numBits)/n*math.Ln2))),
+	}
+	for _, ref := range includedRefs {
+		h1, h2 := bloomHashes(ref)
+		for i := 0; i < m.numHashes; i++ {
+			bit := (h1 + uint64(i)*h2) % m.numBits
+			m.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	return m
+}
+
+func (m bloomBigSegmentMembership) CheckMembership(segmentRef string) ldvalue.OptionalBool {
+	h1, h2 := bloomHashes(segmentRef)
+	for i := 0; i < m.numHashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m.numBits
+		if m.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return ldvalue.OptionalBool{}
+		}
+	}
+	return ldvalue.NewOptionalBool(true)
+}
+
+const (
+	fnv64aOffsetBasis = 14695981039346656037
+	fnv64aPrime       = 1099511628211
+)
+
+// bloomHashes returns two hashes of the string, which are combined to simulate any number of hash
+// functions (Kirsch and Mitzenmacher, "Less Hashing, Same Performance").
+//
+// The first hash is FNV-1a, computed here rather than with hash/fnv so that CheckMembership does not
+// allocate. The second is derived from the first with the SplitMix64 finalizer, which is enough to
+// spread out the probes; two refs only get the same probes if their FNV-1a hashes collide.
+func bloomHashes(s string) (uint64, uint64) {
+	h1 := uint64(fnv64aOffsetBasis)
+	for i := 0; i < len(s); i++ {
+		h1 ^= uint64(s[i])
+		h1 *= fnv64aPrime
+	}
+	h2 := h1
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h1, h2 | 1 // the step must never be zero, or every probe would be the same bit
+}
@@ -0,0 +1,133 @@
+package evaluation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeAndParseBigSegmentRef(t *testing.T) {
+	for _, p := range []struct {
+		key        string
+		generation int
+	}{
+		{"segment", 0},
+		{"segment", 12},
+		{"a.g1", 2},
+		{"", 3},
+	} {
+		ref := MakeBigSegmentRef(p.key, p.generation)
+		key, generation, ok := ParseBigSegmentRef(ref)
+		assert.True(t, ok, ref)
+		assert.Equal(t, p.key, key, ref)
+		assert.Equal(t, p.generation, generation, ref)
+	}
+	assert.Equal(t, "segment.g2", MakeBigSegmentRef("segment", 2))
+}
+
+func TestParseBigSegmentRefWithInvalidRef(t *testing.T) {
+	for _, ref := range []string{"", "x", "x.g", "x.g-1", "x.g01", "x.g+1", "a.gb", "x.g1a"} {
+		t.Run(ref, func(t *testing.T) {
+			_, _, ok := ParseBigSegmentRef(ref)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestMapAndSortedBigSegmentMembership(t *testing.T) {
+	constructors := map[string]func(includedRefs, excludedRefs []string) BigSegmentMembership{
+		"map":    NewMapBigSegmentMembership,
+		"sorted": NewSortedBigSegmentMembership,
+	}
+	for name, constructor := range constructors {
+		t.Run(name, func(t *testing.T) {
+			m := constructor([]string{"a.g1", "b.g1", "c.g1"}, []string{"c.g1", "d.g1", "e.g2"})
+
+			assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("a.g1"))
+			assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("b.g1"))
+			assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("c.g1")) // included takes priority
+			assert.Equal(t, ldvalue.NewOptionalBool(false), m.CheckMembership("d.g1"))
+			assert.Equal(t, ldvalue.NewOptionalBool(false), m.CheckMembership("e.g2"))
+			assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("a.g2"))
+			assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("f.g1"))
+			assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership(""))
+		})
+
+		t.Run(name+" empty", func(t *testing.T) {
+			m := constructor(nil, nil)
+			assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("a.g1"))
+		})
+	}
+}
+
+func TestBloomBigSegmentMembership(t *testing.T) {
+	var included []string
+	for i := 0; i < 10000; i++ {
+		included = append(included, MakeBigSegmentRef(fmt.Sprintf("included-%d", i), 1))
+	}
+	m := NewBloomBigSegmentMembership(included, 0.01)
+
+	for _, ref := range included {
+		if !assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership(ref), ref) {
+			break
+		}
+	}
+
+	falsePositives := 0
+	numChecked := 10000
+	for i := 0; i < numChecked; i++ {
+		result := m.CheckMembership(MakeBigSegmentRef(fmt.Sprintf("other-%d", i), 1))
+		if result.IsDefined() {
+			assert.Equal(t, ldvalue.NewOptionalBool(true), result) // never reports an exclusion
+			falsePositives++
+		} else {
+			assert.Equal(t, ldvalue.OptionalBool{}, result)
+		}
+	}
+	assert.Less(t, falsePositives, numChecked*3/100) // allows for some variance around the 1% target
+}
+
+func TestBloomBigSegmentMembershipDoesNotAllocate(t *testing.T) {
+	m := NewBloomBigSegmentMembership([]string{"a.g1", "b.g1"}, 0.01)
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		_ = m.CheckMembership("a.g1")
+		_ = m.CheckMembership("c.g1")
+	}))
+}
+
+func TestBloomBigSegmentMembershipWithInvalidFalsePositiveRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, 1, 2} {
+		t.Run(fmt.Sprint(rate), func(t *testing.T) {
+			m := NewBloomBigSegmentMembership([]string{"a.g1"}, rate)
+			assert.Equal(t, ldvalue.NewOptionalBool(true), m.CheckMembership("a.g1"))
+			assert.Equal(t, ldvalue.OptionalBool{}, m.CheckMembership("b.g1"))
+		})
+	}
+}
+
+func TestBigSegmentMembershipImplementationsWithEvaluator(t *testing.T) {
+	included := ldbuilders.NewSegmentBuilder("included").Unbounded(true).Generation(2).Build()
+	excluded := ldbuilders.NewSegmentBuilder("excluded").Unbounded(true).Generation(1).
+		Included(basicUserKey).Build() // the big segment exclusion overrides the regular inclusion
+	membership := NewMapBigSegmentMembership(
+		[]string{MakeBigSegmentRef(included.Key, 2)},
+		[]string{MakeBigSegmentRef(excluded.Key, 1)},
+	)
+	provider := &simpleBigSegmentProvider{
+		getMembership: func(string) BigSegmentMembership { return membership },
+	}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredSegments(included, excluded),
+		EvaluatorOptionBigSegmentProvider(provider))
+	context := ldcontext.New(basicUserKey)
+
+	flag1 := makeBooleanFlagToMatchAnyOfSegments(included.Key)
+	assert.Equal(t, ldvalue.Bool(true), evaluator.Evaluate(&flag1, context, nil).Detail.Value)
+
+	flag2 := makeBooleanFlagToMatchAnyOfSegments(excluded.Key)
+	assert.Equal(t, ldvalue.Bool(false), evaluator.Evaluate(&flag2, context, nil).Detail.Value)
+}
@@ -0,0 +1,208 @@
+package evaluation
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+)
+
+// DefaultErrorReportInterval is the interval that NewRateLimitedErrorReporter uses if the interval
+// parameter is not greater than zero.
+const DefaultErrorReportInterval = time.Minute
+
+// maxRateLimitedErrors is the number of distinct errors that a RateLimitedErrorReporter remembers.
+const maxRateLimitedErrors = 1000
+
+// loggingErrorReporter is the ErrorReporter that is used for EvaluatorOptionErrorLogger. It logs every
+// error.
+type loggingErrorReporter struct {
+	logger ldlog.BaseLogger
+}
+
+func (r loggingErrorReporter) ReportError(report ErrorReport) {
+	r.logger.Printf("Invalid flag configuration detected in flag %q: %s", report.FlagKey, report.Err)
+}
+
+// RateLimitedErrorReporter is an ErrorReporter that logs each distinct error at most once per
+// interval, and counts how many times each one has happened. Two reports are considered to be the
+// same error if they have the same flag key, flag version, rule and clause index, and error type; the
+// error message is only computed when the error is logged or counted. Use NewRateLimitedErrorReporter
+// to create one.
+//
+// The first time an error is reported, it is logged immediately. Later reports of the same error are
+// only counted, until the interval has passed since it was last logged; then the next report of it
+// is logged again, along with the number of reports that were not logged in the meantime.
+//
+// The reporter remembers up to 1000 distinct errors, so that it can provide the counts. When it needs
+// to remember a new error and is already at that limit, it forgets every error that has not been
+// reported within the interval, or if there are none, the error that was reported least recently.
+//
+// It is safe to use a RateLimitedErrorReporter concurrently from multiple goroutines.
+type RateLimitedErrorReporter struct {
+	logger   ldlog.BaseLogger
+	interval time.Duration
+	now      func() time.Time
+	lock     sync.Mutex
+	errors   map[errorReportKey]*errorReportState
+}
+
+type errorReportKey struct {
+	flagKey     string
+	flagVersion int
+	ruleIndex   int
+	clauseIndex int
+	errType     reflect.Type
+}
+
+type errorReportState struct {
+	errorKind    ldreason.EvalErrorKind
+	err          error // the first error that was reported; its message is what we log
+	count        int
+	suppressed   int
+	lastLogged   time.Time
+	lastReported time.Time
+}
+
+// ErrorReportCount is the number of times that a distinct error has been reported to a
+// RateLimitedErrorReporter. See RateLimitedErrorReporter.Counts.
+type ErrorReportCount struct {
+	FlagKey     string
+	FlagVersion int
+	RuleIndex   int
+	ClauseIndex int
+	ErrorKind   ldreason.EvalErrorKind
+	Message     string
+	Count       int
+}
+
+// NewRateLimitedErrorReporter creates a RateLimitedErrorReporter that logs errors to the specified
+// logger. If the logger is nil, the reporter only counts errors. If interval is not greater than zero,
+// DefaultErrorReportInterval is used.
+func NewRateLimitedErrorReporter(logger ldlog.BaseLogger, interval time.Duration) *RateLimitedErrorReporter {
+	if interval <= 0 {
+		interval = DefaultErrorReportInterval
+	}
+	return &RateLimitedErrorReporter{
+		logger:   logger,
+		interval: interval,
+		now:      time.Now,
+		errors:   make(map[errorReportKey]*errorReportState),
+	}
+}
+
+// ReportError is called by the evaluator for each error. See ErrorReporter.
+func (r *RateLimitedErrorReporter) ReportError(report ErrorReport) {
+	// The key uses the error's type, rather than its message, so that we don't have to format the
+	// message for every report. Errors at the same location in the same flag version have the same
+	// cause, so they are almost always equal anyway.
+	key := errorReportKey{
+		flagKey:     report.FlagKey,
+		flagVersion: report.FlagVersion,
+		ruleIndex:   report.RuleIndex,
+		clauseIndex: report.ClauseIndex,
+		errType:     reflect.TypeOf(report.Err),
+	}
+	now := r.now()
+
+	r.lock.Lock()
+	state := r.errors[key]
+	if state == nil {
+		if len(r.errors) >= maxRateLimitedErrors {
+			r.forgetOldErrors(now)
+		}
+		state = &errorReportState{errorKind: report.ErrorKind, err: report.Err}
+		r.errors[key] = state
+	}
+	state.count++
+	state.lastReported = now
+	if !state.lastLogged.IsZero() && now.Sub(state.lastLogged) < r.interval {
+		state.suppressed++
+		r.lock.Unlock()
+		return
+	}
+	suppressed := state.suppressed
+	state.suppressed = 0
+	state.lastLogged = now
+	err := state.err
+	r.lock.Unlock()
+
+	if r.logger == nil {
+		return
+	}
+	location := ""
+	if key.ruleIndex >= 0 {
+		location = fmt.Sprintf(" rule %d", key.ruleIndex)
+		if key.clauseIndex >= 0 {
+			location += fmt.Sprintf(" clause %d", key.clauseIndex)
+		}
+	}
+	message := fmt.Sprintf("Invalid flag configuration detected in flag %q (version %d)%s: %s",
+		key.flagKey, key.flagVersion, location, errorMessage(err))
+	if suppressed > 0 {
+		message += fmt.Sprintf(" (repeated %d more times since it was last logged)", suppressed)
+	}
+	r.logger.Println(message)
+}
+
+// forgetOldErrors makes room for a new error. It must be called with the lock held.
+func (r *RateLimitedErrorReporter) forgetOldErrors(now time.Time) {
+	var oldestKey errorReportKey
+	var oldest *errorReportState
+	for key, state := range r.errors {
+		if now.Sub(state.lastReported) >= r.interval {
+			delete(r.errors, key)
+		} else if oldest == nil || state.lastReported.Before(oldest.lastReported) {
+			oldestKey, oldest = key, state
+		}
+	}
+	if len(r.errors) >= maxRateLimitedErrors {
+		delete(r.errors, oldestKey)
+	}
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Counts returns the number of times that each distinct error has been reported, ordered by flag
+// key, flag version, rule and clause index, and message.
+func (r *RateLimitedErrorReporter) Counts() []ErrorReportCount {
+	r.lock.Lock()
+	ret := make([]ErrorReportCount, 0, len(r.errors))
+	for key, state := range r.errors {
+		ret = append(ret, ErrorReportCount{
+			FlagKey:     key.flagKey,
+			FlagVersion: key.flagVersion,
+			RuleIndex:   key.ruleIndex,
+			ClauseIndex: key.clauseIndex,
+			ErrorKind:   state.errorKind,
+			Message:     errorMessage(state.err),
+			Count:       state.count,
+		})
+	}
+	r.lock.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		switch {
+		case a.FlagKey != b.FlagKey:
+			return a.FlagKey < b.FlagKey
+		case a.FlagVersion != b.FlagVersion:
+			return a.FlagVersion < b.FlagVersion
+		case a.RuleIndex != b.RuleIndex:
+			return a.RuleIndex < b.RuleIndex
+		case a.ClauseIndex != b.ClauseIndex:
+			return a.ClauseIndex < b.ClauseIndex
+		default:
+			return a.Message < b.Message
+		}
+	})
+	return ret
+}
@@ -0,0 +1,156 @@
+package evaluation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errorReportSink struct {
+	reports []ErrorReport
+}
+
+func (s *errorReportSink) ReportError(report ErrorReport) {
+	s.reports = append(s.reports, report)
+}
+
+func makeRateLimitedErrorReporter(
+	interval time.Duration,
+) (*RateLimitedErrorReporter, *fakeClock, *ldlogtest.MockLog) {
+	logCapture := ldlogtest.NewMockLog()
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	r := NewRateLimitedErrorReporter(logCapture.Loggers.ForLevel(ldlog.Error), interval)
+	r.now = clock.now
+	return r, clock, logCapture
+}
+
+func TestErrorReporterReceivesStructuredReport(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").Version(3).On(true).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(
+			makeClauseToMatchAnyContextOfAnyKind(),
+			ldbuilders.ClauseRef(ldattr.NewRef("///"), ldmodel.OperatorIn, ldvalue.String("a")))).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	sink := &errorReportSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionErrorReporter(sink))
+
+	result := evaluator.Evaluate(&flag, flagTestContext, nil)
+	assert.Equal(t, ldreason.EvalErrorMalformedFlag, result.Detail.Reason.GetErrorKind())
+	assert.Equal(t, []ErrorReport{{FlagKey: "feature", FlagVersion: 3, RuleIndex: 0, ClauseIndex: 1,
+		ErrorKind: ldreason.EvalErrorMalformedFlag, Err: BadAttrRefError("///")}}, sink.reports)
+}
+
+func TestRateLimitedErrorReporterLogsEachErrorOncePerInterval(t *testing.T) {
+	r, clock, logCapture := makeRateLimitedErrorReporter(time.Minute)
+	report1 := ErrorReport{FlagKey: "f", FlagVersion: 1, RuleIndex: 2, ClauseIndex: -1,
+		ErrorKind: ldreason.EvalErrorMalformedFlag, Err: BadVariationError(5)}
+	report2 := report1
+	report2.FlagVersion = 2
+
+	r.ReportError(report1)
+	r.ReportError(report1)
+	r.ReportError(report2)
+	clock.advance(time.Second * 30)
+	r.ReportError(report1)
+	assert.Equal(t, []string{
+		`Invalid flag configuration detected in flag "f" (version 1) rule 2: ` + BadVariationError(5).Error(),
+		`Invalid flag configuration detected in flag "f" (version 2) rule 2: ` + BadVariationError(5).Error(),
+	}, logCapture.GetOutput(ldlog.Error))
+
+	clock.advance(time.Second * 30)
+	r.ReportError(report1)
+	lines := logCapture.GetOutput(ldlog.Error)
+	require.Len(t, lines, 3)
+	assert.Equal(t, `Invalid flag configuration detected in flag "f" (version 1) rule 2: `+
+		BadVariationError(5).Error()+" (repeated 2 more times since it was last logged)", lines[2])
+
+	assert.Equal(t, []ErrorReportCount{
+		{FlagKey: "f", FlagVersion: 1, RuleIndex: 2, ClauseIndex: -1, ErrorKind: ldreason.EvalErrorMalformedFlag,
+			Message: BadVariationError(5).Error(), Count: 4},
+		{FlagKey: "f", FlagVersion: 2, RuleIndex: 2, ClauseIndex: -1, ErrorKind: ldreason.EvalErrorMalformedFlag,
+			Message: BadVariationError(5).Error(), Count: 1},
+	}, r.Counts())
+}
+
+func TestRateLimitedErrorReporterDistinguishesLocationAndErrorType(t *testing.T) {
+	r, _, logCapture := makeRateLimitedErrorReporter(time.Minute)
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: 0, ClauseIndex: 0, Err: EmptyAttrRefError{}})
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: 0, ClauseIndex: 1, Err: EmptyAttrRefError{}})
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: 0, ClauseIndex: 1, Err: BadAttrRefError("x")})
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+
+	assert.Len(t, logCapture.GetOutput(ldlog.Error), 4)
+	counts := r.Counts()
+	require.Len(t, counts, 4)
+	assert.Equal(t, -1, counts[0].RuleIndex)
+	assert.Equal(t, BadAttrRefError("x").Error(), counts[2].Message)
+	assert.Equal(t, EmptyAttrRefError{}.Error(), counts[3].Message)
+}
+
+func TestRateLimitedErrorReporterDoesNotAllocateForRepeatedError(t *testing.T) {
+	r, _, _ := makeRateLimitedErrorReporter(time.Minute)
+	report := ErrorReport{FlagKey: "f", RuleIndex: 0, ClauseIndex: 1, Err: BadAttrRefError("x")}
+	r.ReportError(report)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { r.ReportError(report) }))
+}
+
+func TestRateLimitedErrorReporterForgetsOldErrorsWhenFull(t *testing.T) {
+	r, clock, _ := makeRateLimitedErrorReporter(time.Minute)
+	for i := 0; i < maxRateLimitedErrors; i++ {
+		r.ReportError(ErrorReport{FlagKey: "f", FlagVersion: i, RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+		clock.advance(time.Millisecond)
+		if i == maxRateLimitedErrors/2 {
+			clock.advance(time.Minute)
+		}
+	}
+	require.Len(t, r.Counts(), maxRateLimitedErrors)
+
+	// The errors that were reported more than a minute ago are forgotten.
+	r.ReportError(ErrorReport{FlagKey: "g", RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+	counts := r.Counts()
+	require.Len(t, counts, maxRateLimitedErrors/2)
+	assert.Equal(t, maxRateLimitedErrors/2+1, counts[0].FlagVersion)
+	assert.Equal(t, "g", counts[len(counts)-1].FlagKey)
+
+	// If none of them are that old, the one that was reported least recently is forgotten.
+	for i := 0; i < maxRateLimitedErrors/2; i++ {
+		clock.advance(time.Millisecond)
+		r.ReportError(ErrorReport{FlagKey: "h", FlagVersion: i, RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+	}
+	r.ReportError(ErrorReport{FlagKey: "i", RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+	counts = r.Counts()
+	require.Len(t, counts, maxRateLimitedErrors)
+	assert.Equal(t, maxRateLimitedErrors/2+2, counts[0].FlagVersion)
+}
+
+func TestRateLimitedErrorReporterWithoutLogger(t *testing.T) {
+	r := NewRateLimitedErrorReporter(nil, 0)
+	assert.Equal(t, DefaultErrorReportInterval, r.interval)
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+	r.ReportError(ErrorReport{FlagKey: "f", RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(1)})
+	require.Len(t, r.Counts(), 1)
+	assert.Equal(t, 2, r.Counts()[0].Count)
+}
+
+func TestRateLimitedErrorReporterWithEvaluator(t *testing.T) {
+	r, _, logCapture := makeRateLimitedErrorReporter(time.Minute)
+	flag := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(999))
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionErrorReporter(r))
+
+	for i := 0; i < 100; i++ {
+		_ = evaluator.Evaluate(&flag, flagTestContext, nil)
+	}
+	assert.Len(t, logCapture.GetOutput(ldlog.Error), 1)
+	require.Len(t, r.Counts(), 1)
+	assert.Equal(t, 100, r.Counts()[0].Count)
+}
@@ -6,105 +6,175 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
 )
 
-// These error types are used only internally to distinguish between reasons an evaluation might fail.
-// They are surfaced only in terms of the EvaluationReason/ErrorKind types.
+// These error types describe the reasons an evaluation might fail. They are surfaced in terms of the
+// EvaluationReason/ErrorKind types, and also in Result.Err, where they are wrapped in an EvaluationError
+// that says where in the flag the problem was found. Callers can use errors.As to check for a
+// specific type.
 
 // When possible, we define these types as renames of a simple type like string or int, rather than as
 // a struct. This is a minor optimization to take advantage of the fact that a simple type that implements
 // an interface does not need to be allocated on the heap.
 
+// EvalErrorStepBudgetExceeded is the ldreason.EvalErrorKind that is reported if an evaluation was
+// stopped because it exceeded the step budget; see EvaluatorOptionStepBudget.
+const EvalErrorStepBudgetExceeded ldreason.EvalErrorKind = "STEP_BUDGET_EXCEEDED"
+
 // EvalError is an internal interface for an error that should cause evaluation to fail.
 type evalError interface {
 	error
-	errorKind() ldreason.EvalErrorKind
+	ErrorKind() ldreason.EvalErrorKind
 }
 
 // ErrorKindForError returns the appropriate ldreason.EvalErrorKind value for an error.
 func errorKindForError(err error) ldreason.EvalErrorKind {
 	if e, ok := err.(evalError); ok {
-		return e.errorKind()
+		return e.ErrorKind()
 	}
 	return ldreason.EvalErrorException
 }
 
+// EvaluationError is the type of Result.Err. It describes an error that caused an evaluation to
+// fail, and where in the flag configuration the error was found.
+type EvaluationError struct {
+	// FlagKey is the key of the flag where the error was found. This may be a prerequisite of the
+	// flag that was being evaluated.
+	FlagKey string
+	// RuleIndex is the index of the flag rule where the error was found, or -1 if it was not in a rule.
+	RuleIndex int
+	// ClauseIndex is the index of the clause within that rule where the error was found, or -1 if it
+	// was not in a clause. If the clause referenced a segment, the error may have been found in the
+	// segment; see MalformedSegmentError.
+	ClauseIndex int
+	// Err is the underlying error, such as BadVariationError.
+	Err error
+}
+
+func (e EvaluationError) Error() string {
+	location := fmt.Sprintf("flag %q", e.FlagKey)
+	if e.RuleIndex >= 0 {
+		location += fmt.Sprintf(" rule %d", e.RuleIndex)
+		if e.ClauseIndex >= 0 {
+			location += fmt.Sprintf(" clause %d", e.ClauseIndex)
+		}
+	}
+	return fmt.Sprintf("invalid configuration in %s: %s", location, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e EvaluationError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns the ldreason.EvalErrorKind that describes this error.
+func (e EvaluationError) ErrorKind() ldreason.EvalErrorKind {
+	return errorKindForError(e.Err)
+}
+
 // BadVariationError means a variation index was out of range. The integer value is the index.
-type badVariationError int
+type BadVariationError int
 
-func (e badVariationError) Error() string {
+func (e BadVariationError) Error() string {
 	return fmt.Sprintf("rule, fallthrough, or target referenced a nonexistent variation index %d", int(e))
 }
 
-func (e badVariationError) errorKind() ldreason.EvalErrorKind {
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e BadVariationError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 }
 
 // EmptyAttrRefError means an attribute reference in a clause was undefined
-type emptyAttrRefError struct{}
+type EmptyAttrRefError struct{}
 
-func (e emptyAttrRefError) Error() string {
+func (e EmptyAttrRefError) Error() string {
 	return "rule clause did not specify an attribute"
 }
 
-func (e emptyAttrRefError) errorKind() ldreason.EvalErrorKind {
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e EmptyAttrRefError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 }
 
 // BadAttrRefError means an attribute reference in a clause was syntactically invalid. The string value is the
 // attribute reference.
-type badAttrRefError string
+type BadAttrRefError string
 
-func (e badAttrRefError) Error() string {
+func (e BadAttrRefError) Error() string {
 	return fmt.Sprintf("invalid attribute reference %q", string(e))
 }
 
-func (e badAttrRefError) errorKind() ldreason.EvalErrorKind {
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e BadAttrRefError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 }
 
 // EmptyRolloutError means a rollout or experiment had no variations.
-type emptyRolloutError struct{}
+type EmptyRolloutError struct{}
 
-func (e emptyRolloutError) Error() string {
+func (e EmptyRolloutError) Error() string {
 	return "rollout or experiment with no variations"
 }
 
-func (e emptyRolloutError) errorKind() ldreason.EvalErrorKind {
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e EmptyRolloutError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 }
 
 // CircularPrereqReferenceError means there was a cycle in prerequisites. The string value is the key of the
 // prerequisite.
-type circularPrereqReferenceError string
+type CircularPrereqReferenceError string
 
-func (e circularPrereqReferenceError) Error() string {
+func (e CircularPrereqReferenceError) Error() string {
 	return fmt.Sprintf("prerequisite relationship to %q caused a circular reference;"+
 		" this is probably a temporary condition due to an incomplete update", string(e))
 }
 
-func (e circularPrereqReferenceError) errorKind() ldreason.EvalErrorKind {
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e CircularPrereqReferenceError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 }
 
 // CircularSegmentReferenceError means there was a cycle in segment rules. The string value is the key of the
-// segment where we detected the cycle.
-type circularSegmentReferenceError string
+// segment where we detected the cycle. In a flag evaluation, this is always wrapped in a
+// MalformedSegmentError for the segment whose rule referenced that segment.
+type CircularSegmentReferenceError string
 
-func (e circularSegmentReferenceError) Error() string {
+func (e CircularSegmentReferenceError) Error() string {
 	return fmt.Sprintf("segment rule referencing segment %q caused a circular reference;"+
 		" this is probably a temporary condition due to an incomplete update", string(e))
 }
 
+// StepBudgetExceededError means an evaluation was stopped because it exceeded the step budget that was
+// specified with EvaluatorOptionStepBudget. The integer value is the budget. Unlike the other error
+// types, this does not mean that the flag is invalid, only that evaluating it is too expensive. In a
+// flag evaluation, this is never wrapped in a MalformedSegmentError.
+type StepBudgetExceededError int
+
+func (e StepBudgetExceededError) Error() string {
+	return fmt.Sprintf("evaluation exceeded the step budget of %d", int(e))
+}
+
+// ErrorKind returns EvalErrorStepBudgetExceeded.
+func (e StepBudgetExceededError) ErrorKind() ldreason.EvalErrorKind {
+	return EvalErrorStepBudgetExceeded
+}
+
 // MalformedSegmentError means invalid properties were found while trying to match a segment.
-type malformedSegmentError struct {
+type MalformedSegmentError struct {
 	SegmentKey string
 	Err        error
 }
 
-func (e malformedSegmentError) Error() string {
+func (e MalformedSegmentError) Error() string {
 	return fmt.Sprintf("segment %q had an invalid configuration: %s", e.SegmentKey, e.Err)
 }
 
-func (e malformedSegmentError) errorKind() ldreason.EvalErrorKind {
+// Unwrap returns the underlying error.
+func (e MalformedSegmentError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorKind returns ldreason.EvalErrorMalformedFlag.
+func (e MalformedSegmentError) ErrorKind() ldreason.EvalErrorKind {
 	return ldreason.EvalErrorMalformedFlag
 	// Technically it's not a malformed *flag*, but we don't have a better error code for this.
 }
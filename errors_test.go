@@ -5,18 +5,25 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
 	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestErrorKindForError(t *testing.T) {
 	for _, err := range []error{
-		badAttrRefError("x"),
-		badVariationError(1),
-		circularPrereqReferenceError("x"),
-		emptyRolloutError{},
-		malformedSegmentError{"x", nil},
+		BadAttrRefError("x"),
+		BadVariationError(1),
+		CircularPrereqReferenceError("x"),
+		EmptyRolloutError{},
+		MalformedSegmentError{"x", nil},
+		EvaluationError{FlagKey: "f", Err: EmptyAttrRefError{}},
 	} {
 		t.Run(fmt.Sprintf("%+v", err), func(t *testing.T) {
 			assert.Equal(t, ldreason.EvalErrorMalformedFlag, errorKindForError(err))
@@ -25,3 +32,72 @@ func TestErrorKindForError(t *testing.T) {
 
 	assert.Equal(t, ldreason.EvalErrorException, errorKindForError(errors.New("some other error")))
 }
+
+func TestEvaluationErrorString(t *testing.T) {
+	err := BadVariationError(9)
+	assert.Equal(t, `invalid configuration in flag "f": `+err.Error(),
+		EvaluationError{FlagKey: "f", RuleIndex: -1, ClauseIndex: -1, Err: err}.Error())
+	assert.Equal(t, `invalid configuration in flag "f" rule 1: `+err.Error(),
+		EvaluationError{FlagKey: "f", RuleIndex: 1, ClauseIndex: -1, Err: err}.Error())
+	assert.Equal(t, `invalid configuration in flag "f" rule 1 clause 2: `+err.Error(),
+		EvaluationError{FlagKey: "f", RuleIndex: 1, ClauseIndex: 2, Err: err}.Error())
+}
+
+func TestResultErrCanBeInspected(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("segmentkey").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+			ldbuilders.ClauseRef(ldattr.Ref{}, ldmodel.OperatorIn, ldvalue.String("a")))).
+		Build()
+	prereq := ldbuilders.NewFlagBuilder("prereq").On(true).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(0).Clauses(
+			makeClauseToMatchAnyContextOfAnyKind(),
+			ldbuilders.SegmentMatchClause(segment.Key))).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).
+		AddPrerequisite(prereq.Key, 0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredFlags(prereq).withStoredSegments(segment))
+
+	result := evaluator.Evaluate(&flag, flagTestContext, nil)
+	m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+
+	var evalErr EvaluationError
+	require.True(t, errors.As(result.Err, &evalErr))
+	assert.Equal(t, prereq.Key, evalErr.FlagKey)
+	assert.Equal(t, 0, evalErr.RuleIndex)
+	assert.Equal(t, 1, evalErr.ClauseIndex)
+	assert.Equal(t, ldreason.EvalErrorMalformedFlag, evalErr.ErrorKind())
+
+	var segmentErr MalformedSegmentError
+	require.True(t, errors.As(result.Err, &segmentErr))
+	assert.Equal(t, segment.Key, segmentErr.SegmentKey)
+	assert.True(t, errors.Is(result.Err, EmptyAttrRefError{}))
+}
+
+func TestResultErrIsNilForSuccessfulEvaluation(t *testing.T) {
+	flag := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+	assert.NoError(t, basicEvaluator().Evaluate(&flag, flagTestContext, nil).Err)
+}
+
+func TestPrerequisiteEventResultIncludesErr(t *testing.T) {
+	prereq := ldbuilders.NewFlagBuilder("prereq").On(true).FallthroughVariation(9).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).OffVariation(0).
+		AddPrerequisite(prereq.Key, 0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredFlags(prereq))
+	eventSink := prereqEventSink{}
+
+	// A prerequisite that returns an error value just fails the prerequisite check, so the flag itself
+	// does not have an error.
+	result := evaluator.Evaluate(&flag, flagTestContext, eventSink.record)
+	assert.Equal(t, ldreason.NewEvalReasonPrerequisiteFailed(prereq.Key), result.Detail.Reason)
+	assert.NoError(t, result.Err)
+
+	require.Len(t, eventSink.events, 1)
+	assert.Equal(t, EvaluationError{FlagKey: prereq.Key, RuleIndex: -1, ClauseIndex: -1, Err: BadVariationError(9)},
+		eventSink.events[0].PrerequisiteResult.Err)
+}
@@ -1,9 +1,12 @@
 package evaluation
 
 import (
+	"sync"
+	"time"
+
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
-	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
 )
@@ -34,24 +37,79 @@ type Result struct {
 	// does not say anything special. When the SDK submits evaluation information to the event
 	// processor, it should set the RequireReason field in ldevents.FlagEventProperties to this value.
 	IsExperiment bool
+
+	// Bucketing describes the percentage rollout or experiment, if any, that determined this result.
+	// See BucketingDetail.
+	Bucketing BucketingDetail
+
+	// Err is non-nil if Detail is an error result because the flag, or some prerequisite flag or
+	// segment that it references, has an invalid configuration. It is an EvaluationError, which says
+	// where the problem was found and wraps one of the error types defined in this package, such as
+	// BadVariationError. If Degradation is set, Err is the error that caused the degradation; if there
+	// was more than one, it is the last one.
+	Err error
+
+	// Degradation is set if the evaluator did not fail despite an error in a rule of this flag or of
+	// a prerequisite flag, because of the DegradationPolicy that was specified with
+	// EvaluatorOptionDegradationPolicy. It is empty otherwise.
+	Degradation DegradationPolicy
+
+	// Stats describes how much work the evaluator did. It is only set if EvaluatorOptionStats or
+	// EvaluatorOptionStepBudget was used, and only in the Result of a top-level evaluation, not in the
+	// Result of a prerequisite that is passed to PrerequisiteFlagEventRecorder or EvaluationHook. If
+	// BatchEvaluator.EvaluateAll reuses the result of evaluating a flag as a prerequisite earlier in
+	// the batch, Stats is the stats of that evaluation. See EvaluationStats.
+	Stats EvaluationStats
+
+	// EvaluationTime is the current time that was used for the evaluation, if the result depended on
+	// the current time: for instance, if a clause used ldmodel.OperatorBeforeRelative, or if
+	// DebugEventsActive had to be computed. The evaluator gets the time only once per evaluation, so
+	// every part of the evaluation uses the same time. Evaluating the same flag and context with
+	// EvaluatorOptionClock returning this time will reproduce the same result. It is zero if the result
+	// did not depend on the current time.
+	EvaluationTime time.Time
+
+	// DebugEventsActive is true if the flag's DebugEventsUntilDate property is set and is after
+	// EvaluationTime, meaning that the SDK should send debug events for this evaluation.
+	DebugEventsActive bool
 }
 
 type evaluator struct {
 	dataProvider       DataProvider
 	bigSegmentProvider BigSegmentProvider
-	errorLogger        ldlog.BaseLogger
+	errorReporter      ErrorReporter
 	enableSecondaryKey bool
+	customOperators    map[ldmodel.Operator]CustomOperatorFunc
+	// degradationPolicies is nil unless EvaluatorOptionDegradationPolicy was used.
+	degradationPolicies map[RuleErrorClass]DegradationPolicy
+	hooks               []EvaluationHook
+	stepBudget          int
+	countStats          bool             // true if EvaluatorOptionStats was used or stepBudget > 0
+	clock               func() time.Time // nil unless EvaluatorOptionClock was used; see now()
+}
+
+// now returns the current time from the evaluator's clock. Evaluations should use evaluationScope.now
+// instead, so that they only get the time once.
+func (e *evaluator) now() time.Time {
+	if e.clock != nil {
+		return e.clock()
+	}
+	return time.Now()
 }
 
 const ( // See Evaluate() regarding the use of these constants
 	preallocatedPrerequisiteChainSize = 20
 	preallocatedSegmentChainSize      = 20
+	preallocatedPrerequisiteMemoSize  = 10
 )
 
 // NewEvaluator creates an Evaluator, specifying a DataProvider that it will use if it needs to
 // query additional feature flags or user segments during an evaluation.
 //
 // To support big segments, you must use NewEvaluatorWithOptions and EvaluatorOptionBigSegmentProvider.
+//
+// The returned Evaluator also implements BatchEvaluator, ExplainingEvaluator, PreparingEvaluator,
+// SegmentEvaluator, and SegmentIndexEvaluator.
 func NewEvaluator(dataProvider DataProvider) Evaluator {
 	return NewEvaluatorWithOptions(dataProvider)
 }
@@ -59,6 +117,9 @@ func NewEvaluator(dataProvider DataProvider) Evaluator {
 // NewEvaluatorWithOptions creates an Evaluator, specifying a DataProvider that it will use if it
 // needs to query additional feature flags or user segments during an evaluation, and also
 // any number of EvaluatorOption modifiers.
+//
+// The returned Evaluator also implements BatchEvaluator, ExplainingEvaluator, PreparingEvaluator,
+// SegmentEvaluator, and SegmentIndexEvaluator.
 func NewEvaluatorWithOptions(dataProvider DataProvider, options ...EvaluatorOption) Evaluator {
 	e := &evaluator{
 		dataProvider: dataProvider,
@@ -68,6 +129,9 @@ func NewEvaluatorWithOptions(dataProvider DataProvider, options ...EvaluatorOpti
 			o.apply(e)
 		}
 	}
+	if e.stepBudget > 0 {
+		e.countStats = true // we can't enforce the budget without counting steps
+	}
 	return e
 }
 
@@ -76,18 +140,91 @@ func NewEvaluatorWithOptions(dataProvider DataProvider, options ...EvaluatorOpti
 // its fields are never modified.
 type evaluationScope struct {
 	owner                         *evaluator
-	flag                          *ldmodel.FeatureFlag
 	context                       ldcontext.Context
 	prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder
-	// These bigSegments properties start out unset. They are computed lazily if we encounter
-	// big segment references during an evaluation. See evaluator_segment.go.
+	// bigSegmentsMemberships is computed lazily if we encounter big segment references during an
+	// evaluation. See evaluator_segment.go.
 	bigSegmentsMemberships map[string]BigSegmentMembership
-	bigSegmentsStatus      ldreason.BigSegmentsStatus
+	// stats accumulates the work done in the whole evaluation, including prerequisites. It is only
+	// updated if evaluator.countStats is true. See evaluator_stats.go.
+	stats EvaluationStats
+	// evaluationTime is zero until the first time that now() is called during this evaluation.
+	evaluationTime time.Time
+	// extras is never nil; see evaluationExtras.
+	extras *evaluationExtras
+	// flagScope describes the flag that is currently being evaluated. It is replaced while we are
+	// evaluating a prerequisite flag; see evaluatePrerequisite.
+	flagScope
+}
+
+// flagScope is the part of an evaluationScope that is specific to one flag.
+type flagScope struct {
+	flag *ldmodel.FeatureFlag
+	// plan is only set if this flag was prepared with PreparingEvaluator.Prepare. See evaluator_prepare.go.
+	plan *flagPlan
+	// bigSegmentsStatus starts out unset. It is computed lazily if we encounter big segment references
+	// during an evaluation. See evaluator_segment.go.
+	bigSegmentsStatus ldreason.BigSegmentsStatus
+	// bucketing is set by variationOrRolloutResult if the flag's result was determined by a rollout.
+	bucketing BucketingDetail
+	// err is set by evaluationError if the evaluation failed due to an invalid configuration.
+	err error
+	// degradation is set if a DegradationPolicy allowed the evaluation to continue after an error.
+	degradation DegradationPolicy
 }
 
+// evaluationExtras holds the parts of an evaluationScope that are only used by some kinds of
+// evaluations, so that a plain call to Evaluate does not have to initialize them. If an evaluation
+// needs any of them, it allocates its own evaluationExtras; otherwise it uses noEvaluationExtras,
+// which must never be modified.
+type evaluationExtras struct {
+	// batchCache is only set if this evaluation is part of an EvaluateAll batch. See evaluator_all.go.
+	batchCache *batchEvaluationCache
+	// tracer is only set if this evaluation is being done by Explain. See evaluator_trace.go.
+	tracer *evaluationTracer
+	// segmentReason is only set if this is EvaluateSegment. See evaluator_segment.go.
+	segmentReason *SegmentReason
+	// hookContextKinds is only set if the evaluator has hooks, and this is not Explain. See
+	// evaluator_hooks.go.
+	hookContextKinds []ldcontext.Kind
+}
+
+var noEvaluationExtras evaluationExtras //nolint:gochecknoglobals
+
 type evaluationStack struct {
 	prerequisiteFlagChain []string
 	segmentChain          []string
+	// prerequisiteMemo is shared by all of the nested prerequisite evaluations within one evaluation.
+	// It is nil until checkPrerequisites finds that the top-level flag has prerequisites, and is taken
+	// from prerequisiteMemoPool only then, so evaluations of flags without prerequisites never touch it.
+	prerequisiteMemo *prerequisiteMemo
+}
+
+// prerequisiteMemo remembers the result of every prerequisite flag that has been evaluated so far in
+// the current evaluation, so that in a diamond-shaped dependency graph (A requires B and C, both of
+// which require D), D is only evaluated once. Results are keyed by flag key and version. The first
+// preallocatedPrerequisiteMemoSize results are stored in a slice that is reused from one evaluation
+// to the next, so that the memo does not cause any heap allocations in typical usage; we don't keep
+// it on the stack because zeroing that much space for every evaluation was measurably slower.
+type prerequisiteMemo struct {
+	entries  []prerequisiteMemoEntry
+	overflow map[prerequisiteMemoKey]*cachedFlagResult
+}
+
+var prerequisiteMemoPool = sync.Pool{ //nolint:gochecknoglobals
+	New: func() interface{} {
+		return &prerequisiteMemo{entries: make([]prerequisiteMemoEntry, 0, preallocatedPrerequisiteMemoSize)}
+	},
+}
+
+type prerequisiteMemoKey struct {
+	flagKey string
+	version int
+}
+
+type prerequisiteMemoEntry struct {
+	key    prerequisiteMemoKey
+	result cachedFlagResult
 }
 
 // Implementation of the Evaluator interface.
@@ -97,14 +234,53 @@ func (e *evaluator) Evaluate(
 	prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder,
 ) Result {
 	if context.Err() != nil {
-		return Result{Detail: ldreason.NewEvaluationDetailForError(ldreason.EvalErrorUserNotSpecified, ldvalue.Null())}
+		return e.evaluateForInvalidContext(flag)
 	}
+	return e.evaluateFlag(flag, context, prerequisiteFlagEventRecorder, nil, nil, nil)
+}
+
+// evaluateForInvalidContext is used instead of evaluateFlag by Evaluate, EvaluateAll, and
+// PreparedFlag.Evaluate if the context is invalid. The hooks are still called, with no context kinds,
+// so that every evaluation is visible to them.
+func (e *evaluator) evaluateForInvalidContext(flag *ldmodel.FeatureFlag) Result {
+	result := invalidContextResult()
+	if len(e.hooks) != 0 {
+		series := e.beforeEvaluation(flag, nil, "")
+		e.afterEvaluation(&series, result)
+	}
+	return result
+}
 
+// invalidContextResult returns the Result of evaluating any flag for an invalid context.
+func invalidContextResult() Result {
+	return Result{Detail: ldreason.NewEvaluationDetailForError(ldreason.EvalErrorUserNotSpecified, ldvalue.Null())}
+}
+
+// Shared implementation of Evaluate, EvaluateAll, Explain, and PreparedFlag.Evaluate. The batchCache
+// parameter is nil unless this is part of an EvaluateAll batch, the tracer parameter is nil unless this
+// is Explain, and the plan parameter is nil unless this is a PreparedFlag.
+func (e *evaluator) evaluateFlag(
+	flag *ldmodel.FeatureFlag,
+	context ldcontext.Context,
+	prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder,
+	batchCache *batchEvaluationCache,
+	tracer *evaluationTracer,
+	plan *flagPlan,
+) Result {
 	es := evaluationScope{
 		owner:                         e,
-		flag:                          flag,
 		context:                       context,
 		prerequisiteFlagEventRecorder: prerequisiteFlagEventRecorder,
+		extras:                        &noEvaluationExtras,
+		flagScope:                     flagScope{flag: flag, plan: plan},
+	}
+	var hooks hookSeries
+	if batchCache != nil || tracer != nil || len(e.hooks) != 0 {
+		es.extras = &evaluationExtras{batchCache: batchCache, tracer: tracer}
+		if len(e.hooks) != 0 && tracer == nil {
+			es.extras.hookContextKinds = contextKindsForHooks(&context)
+			hooks = e.beforeEvaluation(flag, es.extras.hookContextKinds, "")
+		}
 	}
 
 	// Preallocate some space for prerequisiteFlagChain and segmentChain on the stack. We can
@@ -115,12 +291,25 @@ func (e *evaluator) Evaluate(
 		segmentChain:          make([]string, 0, preallocatedSegmentChainSize),
 	}
 
-	detail, _ := es.evaluate(stack)
+	var detail ldreason.EvaluationDetail
+	if batchCache == nil && tracer == nil {
+		detail, _ = es.evaluate(stack)
+	} else {
+		// This is done in a separate method to keep the stack frame of this one small.
+		detail = es.evaluateWithBatchCacheOrTracer(stack)
+	}
 	if es.bigSegmentsStatus != "" {
 		detail.Reason = ldreason.NewEvalReasonFromReasonWithBigSegmentsStatus(detail.Reason,
 			es.bigSegmentsStatus)
 	}
-	return Result{Detail: detail, IsExperiment: isExperiment(flag, detail.Reason)}
+	debugEventsActive := es.debugEventsActive(flag)
+	result := Result{Detail: detail, IsExperiment: isExperiment(flag, detail.Reason), Bucketing: es.bucketing,
+		Err: es.err, Degradation: es.degradation, Stats: es.stats, EvaluationTime: es.evaluationTime,
+		DebugEventsActive: debugEventsActive}
+	if es.extras.hookContextKinds != nil {
+		e.afterEvaluation(&hooks, result)
+	}
+	return result
 }
 
 // Entry point for evaluating a flag which could be either the original flag or a prerequisite.
@@ -154,9 +343,32 @@ func (es *evaluationScope) evaluate(stack evaluationStack) (ldreason.EvaluationD
 
 	// Now walk through the rules and see if any match
 	for ruleIndex, rule := range es.flag.Rules {
-		match, err := es.ruleMatchesContext(&rule, stack) //nolint:gosec // see comments at top of file
+		if err := es.countStep(&es.stats.RulesChecked); err != nil {
+			es.evaluationError(err, ruleIndex, -1)
+			return ldreason.NewEvaluationDetailForError(errorKindForError(err), ldvalue.Null()), false
+		}
+		var ruleStep *TraceStep
+		if es.extras.tracer != nil {
+			ruleStep = es.extras.tracer.begin(&TraceStep{Kind: TraceStepRule, Index: ruleIndex, Key: rule.ID})
+		}
+		//nolint:gosec // see comments at top of file
+		match, clauseIndex, err := es.ruleMatchesContext(&rule, es.plan.ruleClauses(ruleIndex), stack)
+		if ruleStep != nil {
+			ruleStep.Matched, ruleStep.Err = match, err
+			es.extras.tracer.end()
+		}
 		if err != nil {
-			es.logEvaluationError(err)
+			es.evaluationError(err, ruleIndex, clauseIndex)
+			switch policy := es.owner.degradationPolicyForRuleError(err); policy {
+			case DegradationSkipRule:
+				es.degradation = policy
+				continue
+			case DegradationServeOff:
+				es.degradation = policy
+				// The reason is an error, rather than OFF, so that the application can tell that the
+				// flag was not really turned off.
+				return es.getOffValue(ldreason.NewEvalReasonError(errorKindForError(err))), true
+			}
 			return ldreason.NewEvaluationDetailForError(errorKindForError(err), ldvalue.Null()), false
 		}
 		if match {
@@ -168,25 +380,146 @@ func (es *evaluationScope) evaluate(stack evaluationStack) (ldreason.EvaluationD
 	return es.getValueForVariationOrRollout(es.flag.Fallthrough, ldreason.NewEvalReasonFallthrough()), true
 }
 
-// Do a nested evaluation for a prerequisite of the current scope's flag. The second return value is
-// normally true; it is false only in the case where we've detected a circular reference, in which
-// case we want the entire evaluation to fail with a MalformedFlag error.
+// evaluateOrTrace calls traceFlag if this evaluation is being done by Explain, or evaluate otherwise.
+func (es *evaluationScope) evaluateOrTrace(stack evaluationStack) (ldreason.EvaluationDetail, bool) {
+	if es.extras.tracer != nil {
+		return es.traceFlag(stack)
+	}
+	return es.evaluate(stack)
+}
+
+// evaluateWithBatchCacheOrTracer is used instead of evaluate for a top-level flag in EvaluateAll or
+// Explain.
+func (es *evaluationScope) evaluateWithBatchCacheOrTracer(stack evaluationStack) ldreason.EvaluationDetail {
+	if cached, ok := es.extras.batchCache.getFlagResult(es.flag); ok {
+		// This flag was already evaluated earlier in the batch, as a prerequisite of some other flag.
+		// Its stats are the ones from that evaluation, as if we had done it again here.
+		es.bigSegmentsStatus, es.bucketing, es.err, es.degradation = cached.bigSegmentsStatus, cached.bucketing,
+			cached.err, cached.degradation
+		es.stats = cached.stats
+		return cached.detail
+	}
+	detail, valid := es.evaluateOrTrace(stack)
+	if valid {
+		es.extras.batchCache.setFlagResult(es.flag, cachedFlagResult{detail: detail,
+			bigSegmentsStatus: es.bigSegmentsStatus, bucketing: es.bucketing, err: es.err,
+			degradation: es.degradation, stats: es.stats})
+	}
+	return detail
+}
+
+// Do a nested evaluation for a prerequisite of the current scope's flag, storing the outcome in result.
+// The return value is normally true; it is false only in the case where we've detected a circular
+// reference, in which case we want the entire evaluation to fail with a MalformedFlag error.
 func (es *evaluationScope) evaluatePrerequisite(
 	prereqFlag *ldmodel.FeatureFlag,
+	prereqPlan *flagPlan,
 	stack evaluationStack,
-) (ldreason.EvaluationDetail, bool) {
+	result *cachedFlagResult,
+) bool {
 	for _, p := range stack.prerequisiteFlagChain {
 		if prereqFlag.Key == p {
-			err := circularPrereqReferenceError(prereqFlag.Key)
-			es.logEvaluationError(err)
-			return ldreason.EvaluationDetail{}, false
+			err := CircularPrereqReferenceError(prereqFlag.Key)
+			es.evaluationError(err, -1, -1)
+			if es.extras.tracer != nil {
+				es.extras.tracer.add(&TraceStep{Kind: TraceStepFlag, Key: prereqFlag.Key, Version: prereqFlag.Version, Err: err})
+			}
+			return false
+		}
+	}
+	memoKey := prerequisiteMemoKey{flagKey: prereqFlag.Key, version: prereqFlag.Version}
+	cached := stack.prerequisiteMemo.get(memoKey)
+	if cached == nil && es.extras.batchCache != nil {
+		if r, ok := es.extras.batchCache.getFlagResult(prereqFlag); ok {
+			cached = &r
+		}
+	}
+	if cached != nil {
+		// This prerequisite was already evaluated, either earlier in this evaluation or earlier in the
+		// EvaluateAll batch. Its own prerequisites, if any, are not visited again.
+		es.extras.tracer.note("already evaluated")
+		es.bigSegmentsStatus = computeUpdatedBigSegmentsStatus(es.bigSegmentsStatus, cached.bigSegmentsStatus)
+		es.inheritDegradation(cached)
+		*result = *cached
+		return true
+	}
+	if es.owner.countStats && len(stack.prerequisiteFlagChain) > es.stats.PrerequisiteDepth {
+		es.stats.PrerequisiteDepth = len(stack.prerequisiteFlagChain)
+	}
+	if err := es.countStep(&es.stats.PrerequisitesEvaluated); err != nil {
+		es.evaluationError(err, -1, -1)
+		return false
+	}
+	// We evaluate the prerequisite in this same scope, rather than copying it, and then put back the
+	// state of the flag that we were evaluating. Starting with an empty bigSegmentsStatus lets us tell
+	// what status resulted from this prerequisite alone.
+	outer := es.flagScope
+	es.flagScope = flagScope{flag: prereqFlag, plan: prereqPlan}
+	var statsBefore EvaluationStats
+	if es.owner.countStats {
+		statsBefore = es.beginNestedStats(len(stack.prerequisiteFlagChain))
+	}
+	var detail ldreason.EvaluationDetail
+	var ok bool
+	if es.extras.tracer == nil {
+		detail, ok = es.evaluate(stack)
+	} else {
+		detail, ok = es.traceFlag(stack)
+	}
+	prereq := es.flagScope
+	es.flagScope = outer
+	es.bigSegmentsStatus = computeUpdatedBigSegmentsStatus(es.bigSegmentsStatus, prereq.bigSegmentsStatus)
+	*result = cachedFlagResult{detail: detail, bigSegmentsStatus: prereq.bigSegmentsStatus,
+		bucketing: prereq.bucketing, err: prereq.err, degradation: prereq.degradation}
+	if es.owner.countStats {
+		result.stats = es.endNestedStats(statsBefore, len(stack.prerequisiteFlagChain))
+	}
+	if ok {
+		// We only cache successful results. A failed result means that we found a circular reference, and
+		// we want any other evaluation that encounters the same cycle to report it in the same way.
+		stack.prerequisiteMemo.set(memoKey, result)
+		if es.extras.batchCache != nil {
+			es.extras.batchCache.setFlagResult(prereqFlag, *result)
 		}
+		es.inheritDegradation(result)
+	} else {
+		// The prerequisite's error will cause this flag's evaluation to fail too.
+		es.err = prereq.err
+	}
+	return ok
+}
+
+// setPrerequisiteResult fills in the Result that is reported for a prerequisite flag. It writes to
+// a Result provided by the caller, rather than returning one, to avoid copying it.
+func (es *evaluationScope) setPrerequisiteResult(dest *Result, r *cachedFlagResult, flag *ldmodel.FeatureFlag) {
+	dest.Detail = r.detail
+	dest.IsExperiment = isExperiment(flag, r.detail.Reason)
+	dest.Bucketing = r.bucketing
+	dest.Err = r.err
+	dest.Degradation = r.degradation
+	dest.DebugEventsActive = es.debugEventsActive(flag)
+	dest.EvaluationTime = es.evaluationTime
+}
+
+// now returns the current time. It only gets the time from the evaluator's clock the first time it is
+// called during an evaluation, so that every part of the evaluation uses the same time.
+func (es *evaluationScope) now() time.Time {
+	if es.evaluationTime.IsZero() {
+		es.evaluationTime = es.owner.now()
+	}
+	return es.evaluationTime
+}
+
+func (es *evaluationScope) debugEventsActive(flag *ldmodel.FeatureFlag) bool {
+	return flag.DebugEventsUntilDate != 0 && ldtime.UnixMillisFromTime(es.now()) < flag.DebugEventsUntilDate
+}
+
+// inheritDegradation marks this flag's result as degraded if a prerequisite's result was, since the
+// prerequisite's value may have affected it.
+func (es *evaluationScope) inheritDegradation(prereqResult *cachedFlagResult) {
+	if prereqResult.degradation != "" && es.degradation == "" {
+		es.degradation, es.err = prereqResult.degradation, prereqResult.err
 	}
-	subScope := *es
-	subScope.flag = prereqFlag
-	result, ok := subScope.evaluate(stack)
-	es.bigSegmentsStatus = computeUpdatedBigSegmentsStatus(es.bigSegmentsStatus, subScope.bigSegmentsStatus)
-	return result, ok
 }
 
 // Returns an empty reason if all prerequisites are OK, otherwise constructs an error reason that describes the failure
@@ -207,17 +540,68 @@ func (es *evaluationScope) checkPrerequisites(stack evaluationStack) (ldreason.E
 	// of the slice every time, which would be worse in more typical use cases. We do not expect
 	// the preallocated capacity to be reached in typical usage.
 
-	for _, prereq := range es.flag.Prerequisites {
-		prereqFeatureFlag := es.owner.dataProvider.GetFeatureFlag(prereq.Key)
+	if stack.prerequisiteMemo == nil {
+		// This is the top-level flag, so we're starting a new memo. See comments on evaluationStack.
+		memo := prerequisiteMemoPool.Get().(*prerequisiteMemo)
+		stack.prerequisiteMemo = memo
+		reason, ok := es.checkPrerequisiteList(stack)
+		memo.reset()
+		prerequisiteMemoPool.Put(memo)
+		return reason, ok
+	}
+	return es.checkPrerequisiteList(stack)
+}
+
+func (es *evaluationScope) checkPrerequisiteList(stack evaluationStack) (ldreason.EvaluationReason, bool) {
+	for i, prereq := range es.flag.Prerequisites {
+		var prereqStep *TraceStep
+		if es.extras.tracer != nil {
+			prereqStep = es.extras.tracer.begin(&TraceStep{Kind: TraceStepPrerequisite, Key: prereq.Key,
+				Variation: ldvalue.NewOptionalInt(prereq.Variation)})
+		}
+		var prereqFeatureFlag *ldmodel.FeatureFlag
+		var prereqPlan *flagPlan
+		if es.plan != nil {
+			if prereqPlan = es.plan.prereqs[i]; prereqPlan != nil {
+				prereqFeatureFlag = prereqPlan.flag
+			}
+		} else {
+			prereqFeatureFlag = es.owner.dataProvider.GetFeatureFlag(prereq.Key)
+		}
 		if prereqFeatureFlag == nil {
+			if prereqStep != nil {
+				prereqStep.Note = "flag not found"
+				es.extras.tracer.end()
+			}
 			return ldreason.NewEvalReasonPrerequisiteFailed(prereq.Key), false
 		}
 		prereqOK := true
 
-		prereqResultDetail, prereqValid := es.evaluatePrerequisite(prereqFeatureFlag, stack)
+		var prereqHooks hookSeries
+		if es.extras.hookContextKinds != nil {
+			prereqHooks = es.owner.beforeEvaluation(prereqFeatureFlag, es.extras.hookContextKinds, es.flag.Key)
+		}
+		var prereqResult cachedFlagResult
+		prereqValid := es.evaluatePrerequisite(prereqFeatureFlag, prereqPlan, stack, &prereqResult)
+		if es.extras.hookContextKinds != nil {
+			var hookResult Result
+			if prereqValid {
+				es.setPrerequisiteResult(&hookResult, &prereqResult, prereqFeatureFlag)
+			} else {
+				hookResult = Result{Detail: ldreason.NewEvaluationDetailForError(errorKindForError(es.err),
+					ldvalue.Null()), Err: es.err}
+			}
+			es.owner.afterEvaluation(&prereqHooks, hookResult)
+		}
+		prereqResultDetail := prereqResult.detail
+		if prereqStep != nil {
+			prereqStep.Matched = prereqValid && prereqFeatureFlag.On && !prereqResultDetail.IsDefaultValue() &&
+				prereqResultDetail.VariationIndex.IntValue() == prereq.Variation
+			es.extras.tracer.end()
+		}
 		if !prereqValid {
 			// In this case we want to immediately exit with an error and not check any more prereqs
-			return ldreason.NewEvalReasonError(ldreason.EvalErrorMalformedFlag), false
+			return ldreason.NewEvalReasonError(errorKindForError(es.err)), false
 		}
 		if !prereqFeatureFlag.On || prereqResultDetail.IsDefaultValue() ||
 			prereqResultDetail.VariationIndex.IntValue() != prereq.Variation {
@@ -227,10 +611,9 @@ func (es *evaluationScope) checkPrerequisites(stack evaluationStack) (ldreason.E
 		}
 
 		if es.prerequisiteFlagEventRecorder != nil {
-			event := PrerequisiteFlagEvent{es.flag.Key, es.context, prereqFeatureFlag, Result{
-				Detail:       prereqResultDetail,
-				IsExperiment: isExperiment(prereqFeatureFlag, prereqResultDetail.Reason),
-			}, prereqFeatureFlag.ExcludeFromSummaries}
+			event := PrerequisiteFlagEvent{TargetFlagKey: es.flag.Key, Context: es.context,
+				PrerequisiteFlag: prereqFeatureFlag, ExcludeFromSummaries: prereqFeatureFlag.ExcludeFromSummaries}
+			es.setPrerequisiteResult(&event.PrerequisiteResult, &prereqResult, prereqFeatureFlag)
 			es.prerequisiteFlagEventRecorder(event)
 		}
 
@@ -243,9 +626,9 @@ func (es *evaluationScope) checkPrerequisites(stack evaluationStack) (ldreason.E
 
 func (es *evaluationScope) getVariation(index int, reason ldreason.EvaluationReason) ldreason.EvaluationDetail {
 	if index < 0 || index >= len(es.flag.Variations) {
-		err := badVariationError(index)
-		es.logEvaluationError(err)
-		return ldreason.NewEvaluationDetailForError(err.errorKind(), ldvalue.Null())
+		err := BadVariationError(index)
+		es.evaluationError(err, ruleIndexForReason(reason), -1)
+		return ldreason.NewEvaluationDetailForError(err.ErrorKind(), ldvalue.Null())
 	}
 	return ldreason.NewEvaluationDetail(es.flag.Variations[index], index, reason)
 }
@@ -263,7 +646,7 @@ func (es *evaluationScope) getValueForVariationOrRollout(
 ) ldreason.EvaluationDetail {
 	index, inExperiment, err := es.variationOrRolloutResult(vr, es.flag.Key, es.flag.Salt)
 	if err != nil {
-		es.logEvaluationError(err)
+		es.evaluationError(err, ruleIndexForReason(reason), -1)
 		return ldreason.NewEvaluationDetailForError(errorKindForError(err), ldvalue.Null())
 	}
 	if inExperiment {
@@ -307,21 +690,45 @@ func (es *evaluationScope) anyTargetMatchVariation() ldvalue.OptionalInt {
 func (es *evaluationScope) targetMatchVariation(t *ldmodel.Target) ldvalue.OptionalInt {
 	if context := es.context.IndividualContextByKind(t.ContextKind); context.IsDefined() {
 		if ldmodel.EvaluatorAccessors.TargetFindKey(t, context.Key()) {
+			if es.extras.tracer != nil {
+				es.extras.tracer.add(&TraceStep{Kind: TraceStepTarget, ContextKind: t.ContextKind,
+					Variation: ldvalue.NewOptionalInt(t.Variation), Matched: true})
+			}
 			return ldvalue.NewOptionalInt(t.Variation)
 		}
 	}
+	if es.extras.tracer != nil {
+		es.extras.tracer.add(&TraceStep{Kind: TraceStepTarget, ContextKind: t.ContextKind,
+			Variation: ldvalue.NewOptionalInt(t.Variation)})
+	}
 	return ldvalue.OptionalInt{}
 }
 
-func (es *evaluationScope) ruleMatchesContext(rule *ldmodel.FlagRule, stack evaluationStack) (bool, error) {
+// The clausePlans parameter is nil unless the flag was prepared; see evaluator_prepare.go. If there is
+// an error, the second return value is the index of the clause where it happened.
+func (es *evaluationScope) ruleMatchesContext(
+	rule *ldmodel.FlagRule,
+	clausePlans []clausePlan,
+	stack evaluationStack,
+) (bool, int, error) {
 	// Note that rule is passed by reference only for efficiency; we do not modify it
-	for _, clause := range rule.Clauses {
-		match, err := es.clauseMatchesContext(&clause, stack) //nolint:gosec // see comments at top of file
+	for i, clause := range rule.Clauses {
+		var plan *clausePlan
+		if clausePlans != nil {
+			plan = &clausePlans[i]
+		}
+		var match bool
+		var err error
+		if es.extras.tracer != nil {
+			match, err = es.traceClause(i, &clause, plan, stack) //nolint:gosec // see comments at top of file
+		} else {
+			match, err = es.clauseMatchesContext(&clause, plan, stack) //nolint:gosec // see comments at top of file
+		}
 		if !match || err != nil {
-			return match, err
+			return match, i, err
 		}
 	}
-	return true, nil
+	return true, -1, nil
 }
 
 func (es *evaluationScope) variationOrRolloutResult(
@@ -331,23 +738,43 @@ func (es *evaluationScope) variationOrRolloutResult(
 	}
 	if len(r.Rollout.Variations) == 0 {
 		// This is an error (malformed flag); either Variation or Rollout must be non-nil.
-		return -1, false, emptyRolloutError{}
+		return -1, false, EmptyRolloutError{}
 	}
 
 	isExperiment := r.Rollout.IsExperiment()
 
 	bucketVal, problem, err := es.computeBucketValue(isExperiment, r.Rollout.Seed, r.Rollout.ContextKind,
 		key, r.Rollout.BucketBy, salt)
+	contextKind := r.Rollout.ContextKind
+	if contextKind == "" {
+		contextKind = ldcontext.DefaultKind
+	}
+	attribute := bucketingAttribute(isExperiment, r.Rollout.BucketBy)
+	es.bucketing = BucketingDetail{
+		Used:          true,
+		ContextKind:   contextKind,
+		Attribute:     attribute,
+		BucketValue:   bucketVal,
+		FailureReason: problem,
+	}
 	if err != nil {
+		if es.extras.tracer != nil {
+			es.traceBucket(contextKind, attribute, bucketVal, 0, 0, ldvalue.OptionalInt{}, problem, err)
+		}
 		return -1, false, err
 	}
 	var sum float32
 
 	for _, bucket := range r.Rollout.Variations {
+		start := sum
 		sum += float32(bucket.Weight) / 100000.0
 		if bucketVal < sum {
 			resultInExperiment := isExperiment && !bucket.Untracked &&
-				problem != bucketingFailureContextLacksDesiredKind
+				problem != BucketingFailureContextLacksDesiredKind
+			if es.extras.tracer != nil {
+				es.traceBucket(contextKind, attribute, bucketVal, start, sum, ldvalue.NewOptionalInt(bucket.Variation),
+					problem, nil)
+			}
 			return bucket.Variation, resultInExperiment, nil
 		}
 	}
@@ -358,17 +785,37 @@ func (es *evaluationScope) variationOrRolloutResult(
 	// this case (or changing the scaling, which would potentially change the results for *all* users), we
 	// will simply put the user in the last bucket.
 	lastBucket := r.Rollout.Variations[len(r.Rollout.Variations)-1]
+	if es.extras.tracer != nil {
+		es.traceBucket(contextKind, attribute, bucketVal, sum-float32(lastBucket.Weight)/100000.0, 1,
+			ldvalue.NewOptionalInt(lastBucket.Variation), problem, nil)
+	}
 	return lastBucket.Variation, isExperiment && !lastBucket.Untracked, nil
 }
 
-func (es *evaluationScope) logEvaluationError(err error) {
-	if err == nil || es.owner.errorLogger == nil {
+// evaluationError records an error that will cause the current flag's evaluation to fail, so that it
+// can be reported in Result.Err, and passes it to the ErrorReporter if any. The indices are -1 if the
+// error was not in a rule or clause.
+func (es *evaluationScope) evaluationError(err error, ruleIndex, clauseIndex int) {
+	es.err = EvaluationError{FlagKey: es.flag.Key, RuleIndex: ruleIndex, ClauseIndex: clauseIndex, Err: err}
+	if es.owner.errorReporter == nil {
 		return
 	}
-	es.owner.errorLogger.Printf("Invalid flag configuration detected in flag %q: %s",
-		es.flag.Key,
-		err,
-	)
+	es.owner.errorReporter.ReportError(ErrorReport{
+		FlagKey:     es.flag.Key,
+		FlagVersion: es.flag.Version,
+		RuleIndex:   ruleIndex,
+		ClauseIndex: clauseIndex,
+		ErrorKind:   errorKindForError(err),
+		Err:         err,
+	})
+}
+
+// ruleIndexForReason returns the rule index if the reason is a rule match, or -1 otherwise.
+func ruleIndexForReason(reason ldreason.EvaluationReason) int {
+	if reason.GetKind() == ldreason.EvalReasonRuleMatch {
+		return reason.GetRuleIndex()
+	}
+	return -1
 }
 
 func getApplicableContextKeyByKind(baseContext *ldcontext.Context, kind ldcontext.Kind) (string, bool) {
@@ -407,3 +854,38 @@ func isExperiment(flag *ldmodel.FeatureFlag, reason ldreason.EvaluationReason) b
 	}
 	return false
 }
+
+func (m *prerequisiteMemo) get(key prerequisiteMemoKey) *cachedFlagResult {
+	if m == nil {
+		return nil
+	}
+	for i := range m.entries {
+		// Comparing the version first is cheaper than comparing the key, and usually enough to rule out a match.
+		if e := &m.entries[i]; e.key.version == key.version && e.key.flagKey == key.flagKey {
+			return &e.result
+		}
+	}
+	return m.overflow[key]
+}
+
+func (m *prerequisiteMemo) set(key prerequisiteMemoKey, result *cachedFlagResult) {
+	if len(m.entries) < preallocatedPrerequisiteMemoSize {
+		m.entries = append(m.entries, prerequisiteMemoEntry{key: key, result: *result})
+		return
+	}
+	if m.overflow == nil {
+		m.overflow = make(map[prerequisiteMemoKey]*cachedFlagResult)
+	}
+	r := *result
+	m.overflow[key] = &r
+}
+
+// reset prepares the memo to be returned to prerequisiteMemoPool, without keeping any references to
+// the results of this evaluation.
+func (m *prerequisiteMemo) reset() {
+	for i := range m.entries {
+		m.entries[i] = prerequisiteMemoEntry{}
+	}
+	m.entries = m.entries[:0]
+	m.overflow = nil
+}
@@ -0,0 +1,114 @@
+package evaluation
+
+import (
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// batchEvaluationCache holds state that can be shared between all of the flag evaluations in an
+// EvaluateAll batch. Since every evaluation in the batch is for the same context, the result of
+// evaluating any given flag, and the big segment membership for any given context key, cannot
+// change during the batch.
+//
+// All of its methods are safe to call on a nil pointer, in which case nothing is cached; that is
+// what happens in a regular Evaluate call.
+type batchEvaluationCache struct {
+	flagResults            map[flagVersionKey]cachedFlagResult
+	bigSegmentsMemberships map[string]cachedBigSegmentsMembership
+}
+
+// flagVersionKey identifies a flag result that has already been computed. The version is part of the key
+// because a prerequisite flag that the DataProvider returns during the batch is not necessarily the same
+// version as the one that was returned by GetAllFeatureFlags, if the data was updated in the meantime.
+type flagVersionKey struct {
+	flagKey string
+	version int
+}
+
+type cachedFlagResult struct {
+	detail ldreason.EvaluationDetail
+	// bigSegmentsStatus is the big segments status that resulted from evaluating this flag and its
+	// prerequisites, if any. We need to remember it so that any later evaluation that uses the cached
+	// result will report the same status as if it had done the evaluation itself.
+	bigSegmentsStatus ldreason.BigSegmentsStatus
+	// bucketing is what the flag's Result.Bucketing would be.
+	bucketing BucketingDetail
+	// err and degradation are what the flag's Result.Err and Result.Degradation would be.
+	err         error
+	degradation DegradationPolicy
+	// stats is what the flag's Result.Stats would be if it were evaluated on its own. It is only set
+	// if evaluator.countStats is true.
+	stats EvaluationStats
+}
+
+type cachedBigSegmentsMembership struct {
+	membership BigSegmentMembership
+	status     ldreason.BigSegmentsStatus
+}
+
+// DataProviderNotEnumerableError is returned by BatchEvaluator.EvaluateAll if the Evaluator's DataProvider
+// does not implement EnumerableDataProvider.
+type DataProviderNotEnumerableError struct{}
+
+func (e DataProviderNotEnumerableError) Error() string {
+	return "EvaluateAll requires a DataProvider that implements EnumerableDataProvider"
+}
+
+// Implementation of the BatchEvaluator interface.
+func (e *evaluator) EvaluateAll(context ldcontext.Context, filter FlagFilter) (map[string]Result, error) {
+	provider, ok := e.dataProvider.(EnumerableDataProvider)
+	if !ok {
+		return nil, DataProviderNotEnumerableError{}
+	}
+	flags := provider.GetAllFeatureFlags()
+	results := make(map[string]Result, len(flags))
+	cache := &batchEvaluationCache{}
+	for _, flag := range flags {
+		if flag == nil || flag.Deleted || (filter != nil && !filter(flag)) {
+			continue
+		}
+		if context.Err() != nil {
+			results[flag.Key] = e.evaluateForInvalidContext(flag)
+			continue
+		}
+		results[flag.Key] = e.evaluateFlag(flag, context, nil, cache, nil, nil)
+	}
+	return results, nil
+}
+
+func (c *batchEvaluationCache) getFlagResult(flag *ldmodel.FeatureFlag) (cachedFlagResult, bool) {
+	if c == nil {
+		return cachedFlagResult{}, false
+	}
+	r, ok := c.flagResults[flagVersionKey{flagKey: flag.Key, version: flag.Version}]
+	return r, ok
+}
+
+func (c *batchEvaluationCache) setFlagResult(flag *ldmodel.FeatureFlag, result cachedFlagResult) {
+	if c == nil {
+		return
+	}
+	if c.flagResults == nil {
+		c.flagResults = make(map[flagVersionKey]cachedFlagResult)
+	}
+	c.flagResults[flagVersionKey{flagKey: flag.Key, version: flag.Version}] = result
+}
+
+func (c *batchEvaluationCache) getBigSegmentsMembership(contextKey string) (cachedBigSegmentsMembership, bool) {
+	if c == nil {
+		return cachedBigSegmentsMembership{}, false
+	}
+	m, ok := c.bigSegmentsMemberships[contextKey]
+	return m, ok
+}
+
+func (c *batchEvaluationCache) setBigSegmentsMembership(contextKey string, m cachedBigSegmentsMembership) {
+	if c == nil {
+		return
+	}
+	if c.bigSegmentsMemberships == nil {
+		c.bigSegmentsMemberships = make(map[string]cachedBigSegmentsMembership)
+	}
+	c.bigSegmentsMemberships[contextKey] = m
+}
@@ -0,0 +1,248 @@
+package evaluation
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type enumerableDataProvider struct {
+	*simpleDataProvider
+	flags        []ldmodel.FeatureFlag
+	flagsQueried []string
+}
+
+func newEnumerableDataProvider(base *simpleDataProvider, flags ...ldmodel.FeatureFlag) *enumerableDataProvider {
+	return &enumerableDataProvider{simpleDataProvider: base.withStoredFlags(flags...), flags: flags}
+}
+
+func (d *enumerableDataProvider) GetFeatureFlag(key string) *ldmodel.FeatureFlag {
+	d.flagsQueried = append(d.flagsQueried, key)
+	return d.simpleDataProvider.GetFeatureFlag(key)
+}
+
+func (d *enumerableDataProvider) GetAllFeatureFlags() []*ldmodel.FeatureFlag {
+	ret := make([]*ldmodel.FeatureFlag, 0, len(d.flags))
+	for i := range d.flags {
+		ret = append(ret, &d.flags[i])
+	}
+	return ret
+}
+
+func (d *enumerableDataProvider) timesQueried(key string) int {
+	n := 0
+	for _, k := range d.flagsQueried {
+		if k == key {
+			n++
+		}
+	}
+	return n
+}
+
+func evaluateAll(t *testing.T, evaluator Evaluator, context ldcontext.Context, filter FlagFilter) map[string]Result {
+	require.Implements(t, (*BatchEvaluator)(nil), evaluator)
+	results, err := evaluator.(BatchEvaluator).EvaluateAll(context, filter)
+	require.NoError(t, err)
+	return results
+}
+
+func TestEvaluateAllReturnsErrorIfDataProviderIsNotEnumerable(t *testing.T) {
+	evaluator := NewEvaluator(basicDataProvider())
+	results, err := evaluator.(BatchEvaluator).EvaluateAll(flagTestContext, nil)
+	assert.Nil(t, results)
+	assert.Equal(t, DataProviderNotEnumerableError{}, err)
+}
+
+func TestEvaluateAllReturnsSameResultsAsEvaluate(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).FallthroughVariation(1).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(false).OffVariation(0).
+		Variations(ldvalue.String("c"), ldvalue.String("d")).Build()
+	f2 := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+	provider := newEnumerableDataProvider(basicDataProvider(), f0, f1, f2)
+	evaluator := NewEvaluator(provider)
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	assert.Len(t, results, 3)
+	for _, f := range []ldmodel.FeatureFlag{f0, f1, f2} {
+		flag := f
+		assert.Equal(t, evaluator.Evaluate(&flag, flagTestContext, nil), results[f.Key], f.Key)
+	}
+}
+
+func TestEvaluateAllSkipsDeletedFlags(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").Deleted(true).Build()
+	evaluator := NewEvaluator(newEnumerableDataProvider(basicDataProvider(), f0, f1))
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, f0.Key)
+}
+
+func TestEvaluateAllAppliesFilter(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).
+		ClientSideUsingEnvironmentID(true).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	evaluator := NewEvaluator(newEnumerableDataProvider(basicDataProvider(), f0, f1))
+
+	results := evaluateAll(t, evaluator, flagTestContext, func(flag *ldmodel.FeatureFlag) bool {
+		return flag.ClientSideAvailability.UsingEnvironmentID
+	})
+
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, f0.Key)
+}
+
+func TestEvaluateAllWithInvalidContextReturnsErrorForEveryFlag(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	evaluator := NewEvaluator(newEnumerableDataProvider(basicDataProvider(), f0, f1))
+
+	results := evaluateAll(t, evaluator, ldcontext.New(""), nil)
+
+	assert.Len(t, results, 2)
+	for _, r := range results {
+		m.In(t).Assert(r, ResultDetailError(ldreason.EvalErrorUserNotSpecified))
+	}
+}
+
+func TestEvaluateAllEvaluatesSharedPrerequisiteOnlyOnce(t *testing.T) {
+	// feature0 and feature1 both have feature2 as a prerequisite, and feature2 has feature3 as a
+	// prerequisite. We can tell how many times feature2 was really evaluated by counting how many
+	// times the evaluator had to look up feature3.
+	f3 := ldbuilders.NewFlagBuilder("feature3").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).AddPrerequisite(f3.Key, 0).FallthroughVariation(1).
+		Variations(ldvalue.String("x"), ldvalue.String("y")).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(f2.Key, 1).FallthroughVariation(0).
+		OffVariation(1).Variations(ldvalue.String("on"), ldvalue.String("off")).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(f2.Key, 0).FallthroughVariation(0).
+		OffVariation(1).Variations(ldvalue.String("on"), ldvalue.String("off")).Build()
+	provider := newEnumerableDataProvider(basicDataProvider(), f0, f1, f2, f3)
+	evaluator := NewEvaluator(provider)
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	assert.Equal(t, 1, provider.timesQueried(f3.Key))
+	m.In(t).Assert(results[f0.Key], ResultDetailProps(1, ldvalue.String("off"),
+		ldreason.NewEvalReasonPrerequisiteFailed(f2.Key)))
+	m.In(t).Assert(results[f1.Key], ResultDetailProps(0, ldvalue.String("on"), ldreason.NewEvalReasonFallthrough()))
+	m.In(t).Assert(results[f2.Key], ResultDetailProps(1, ldvalue.String("y"), ldreason.NewEvalReasonFallthrough()))
+	m.In(t).Assert(results[f3.Key], ResultDetailProps(0, ldvalue.Bool(true), ldreason.NewEvalReasonFallthrough()))
+}
+
+func TestEvaluateAllReportsStatsOfSharedPrerequisite(t *testing.T) {
+	// feature0 is evaluated first, so feature1 and feature2 are evaluated as its prerequisites before
+	// their own top-level results are computed; those results should still have the same stats as if
+	// each flag had been evaluated on its own.
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule").Variation(1).
+			Clauses(ldbuilders.Clause("key", ldmodel.OperatorIn, ldvalue.String("other")))).
+		FallthroughVariation(0).Variations(ldvalue.Bool(true), ldvalue.Bool(false)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(f2.Key, 0).FallthroughVariation(0).
+		OffVariation(1).Variations(ldvalue.String("on"), ldvalue.String("off")).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(f1.Key, 0).FallthroughVariation(0).
+		OffVariation(1).Variations(ldvalue.String("on"), ldvalue.String("off")).Build()
+	provider := newEnumerableDataProvider(basicDataProvider(), f0, f1, f2)
+	evaluator := NewEvaluatorWithOptions(provider, EvaluatorOptionStats(true))
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	assert.Equal(t, EvaluationStats{RulesChecked: 1, ClausesEvaluated: 1, PrerequisitesEvaluated: 2,
+		PrerequisiteDepth: 2, Steps: 4}, results[f0.Key].Stats)
+	for _, f := range []ldmodel.FeatureFlag{f1, f2} {
+		flag := f
+		expected := evaluator.Evaluate(&flag, flagTestContext, nil).Stats
+		assert.NotEqual(t, EvaluationStats{}, expected, f.Key)
+		assert.Equal(t, expected, results[f.Key].Stats, f.Key)
+	}
+}
+
+func TestEvaluateAllDoesNotReuseResultOfDifferentFlagVersion(t *testing.T) {
+	// The DataProvider has a newer version of the prerequisite flag than the one that GetAllFeatureFlags
+	// returned, as if the data was updated during the batch. Each version should get its own result.
+	prereq1 := ldbuilders.NewFlagBuilder("prereq").Version(1).On(true).FallthroughVariation(0).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).Build()
+	prereq2 := ldbuilders.NewFlagBuilder("prereq").Version(2).On(true).FallthroughVariation(1).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(prereq1.Key, 1).FallthroughVariation(0).
+		OffVariation(1).Variations(ldvalue.String("on"), ldvalue.String("off")).Build()
+	provider := &enumerableDataProvider{simpleDataProvider: basicDataProvider().withStoredFlags(f0, prereq2),
+		flags: []ldmodel.FeatureFlag{f0, prereq1}}
+	evaluator := NewEvaluator(provider)
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	m.In(t).Assert(results[f0.Key], ResultDetailProps(0, ldvalue.String("on"), ldreason.NewEvalReasonFallthrough()))
+	m.In(t).Assert(results[prereq1.Key], ResultDetailProps(0, ldvalue.String("a"), ldreason.NewEvalReasonFallthrough()))
+}
+
+func TestEvaluateAllStillDetectsCircularPrerequisites(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite("feature1", 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite("feature0", 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	evaluator := NewEvaluator(newEnumerableDataProvider(basicDataProvider(), f0, f1))
+
+	results := evaluateAll(t, evaluator, flagTestContext, nil)
+
+	m.In(t).Assert(results[f0.Key], ResultDetailError(ldreason.EvalErrorMalformedFlag))
+	m.In(t).Assert(results[f1.Key], ResultDetailError(ldreason.EvalErrorMalformedFlag))
+}
+
+func TestEvaluateAllQueriesBigSegmentMembershipOnlyOnce(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("segmentkey").Unbounded(true).Generation(2).Build()
+	f0 := makeBooleanFlagToMatchAnyOfSegments(segment.Key)
+	f0.Key = "feature0"
+	f1 := makeBooleanFlagToMatchAnyOfSegments(segment.Key)
+	f1.Key = "feature1"
+	bigSegmentsProvider := basicBigSegmentsProvider().
+		withStatus(ldreason.BigSegmentsStale).
+		withMembership(basicUserKey, basicMembership().include(makeBigSegmentRef(&segment)))
+	evaluator := NewEvaluatorWithOptions(
+		newEnumerableDataProvider(basicDataProvider().withStoredSegments(segment), f0, f1),
+		EvaluatorOptionBigSegmentProvider(bigSegmentsProvider),
+	)
+
+	results := evaluateAll(t, evaluator, ldcontext.New(basicUserKey), nil)
+
+	assert.Equal(t, []string{basicUserKey}, bigSegmentsProvider.membershipKeysQueried)
+	for _, key := range []string{f0.Key, f1.Key} {
+		assert.Equal(t, ldvalue.Bool(true), results[key].Detail.Value, key)
+		assert.Equal(t, ldreason.BigSegmentsStale, results[key].Detail.Reason.GetBigSegmentsStatus(), key)
+	}
+}
+
+func TestEvaluateAllReportsBigSegmentsStatusOfCachedPrerequisite(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("segmentkey").Unbounded(true).Generation(2).Build()
+	prereq := makeBooleanFlagToMatchAnyOfSegments(segment.Key)
+	prereq.Key = "prereq"
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(prereq.Key, 1).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(prereq.Key, 1).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	bigSegmentsProvider := basicBigSegmentsProvider().
+		withStatus(ldreason.BigSegmentsStoreError).
+		withMembership(basicUserKey, basicMembership().include(makeBigSegmentRef(&segment)))
+	evaluator := NewEvaluatorWithOptions(
+		newEnumerableDataProvider(basicDataProvider().withStoredSegments(segment), f0, f1, prereq),
+		EvaluatorOptionBigSegmentProvider(bigSegmentsProvider),
+	)
+
+	results := evaluateAll(t, evaluator, ldcontext.New(basicUserKey), nil)
+
+	assert.Len(t, bigSegmentsProvider.membershipKeysQueried, 1)
+	for _, key := range []string{f0.Key, f1.Key, prereq.Key} {
+		assert.Equal(t, ldreason.BigSegmentsStoreError, results[key].Detail.Reason.GetBigSegmentsStatus(), key)
+	}
+}
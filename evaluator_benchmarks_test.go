@@ -28,6 +28,7 @@ func discardPrerequisiteEvents(params PrerequisiteFlagEvent) {}
 
 type evalBenchmarkEnv struct {
 	evaluator        Evaluator
+	preparedFlag     PreparedFlag
 	user             ldcontext.Context
 	targetFlag       *ldmodel.FeatureFlag
 	otherFlags       map[string]*ldmodel.FeatureFlag
@@ -44,6 +45,7 @@ type evalBenchmarkCase struct {
 	withSegments      bool
 	prereqsWidth      int
 	prereqsDepth      int
+	prereqsShared     bool
 	operator          ldmodel.Operator
 	shouldMatchClause bool
 }
@@ -71,6 +73,7 @@ func (env *evalBenchmarkEnv) setUp(bc evalBenchmarkCase) {
 		},
 	}
 	env.evaluator = NewEvaluator(dataProvider)
+	env.preparedFlag = env.evaluator.(PreparingEvaluator).Prepare(env.targetFlag)
 
 	env.targetUsers = make([]ldcontext.Context, bc.numTargets)
 	for i := 0; i < bc.numTargets; i++ {
@@ -129,6 +132,22 @@ func BenchmarkEvaluationFallthroughNoAlloc(b *testing.B) {
 	})
 }
 
+func BenchmarkEvaluationPrerequisitesNoAlloc(b *testing.B) {
+	cases := []evalBenchmarkCase{
+		{numRules: 1, numClauses: 1, operator: ldmodel.OperatorIn, prereqsWidth: 5, prereqsDepth: 1},
+		{numRules: 1, numClauses: 1, operator: ldmodel.OperatorIn, prereqsWidth: 1, prereqsDepth: 5},
+		// In this case, every prerequisite at one level has the same prerequisites, so that most of them
+		// are found in the prerequisite memo rather than being evaluated again.
+		{numRules: 1, numClauses: 1, operator: ldmodel.OperatorIn, prereqsWidth: 3, prereqsDepth: 3, prereqsShared: true},
+	}
+	benchmarkEval(b, cases, func(env *evalBenchmarkEnv) {
+		evalBenchmarkResult = env.evaluator.Evaluate(env.targetFlag, env.user, discardPrerequisiteEvents)
+		if evalBenchmarkResult.Detail.Value.BoolValue() { // verify that we did not get a match
+			b.FailNow()
+		}
+	})
+}
+
 func BenchmarkEvaluationRuleMatchNoAlloc(b *testing.B) {
 	benchmarkEval(b, makeEvalBenchmarkCases(true), func(env *evalBenchmarkEnv) {
 		evalBenchmarkResult = env.evaluator.Evaluate(env.targetFlag, env.user, discardPrerequisiteEvents)
@@ -198,6 +217,36 @@ func BenchmarkEvaluationUserMatchedBySegmentRuleNoAlloc(b *testing.B) {
 	})
 }
 
+// The "Prepared" benchmarks are the same as the corresponding benchmarks above, but use a PreparedFlag
+// instead of calling Evaluate directly, to show the effect of PreparingEvaluator.Prepare.
+
+func BenchmarkPreparedEvaluationFallthroughNoAlloc(b *testing.B) {
+	benchmarkEval(b, makeEvalBenchmarkCases(false), func(env *evalBenchmarkEnv) {
+		evalBenchmarkResult = env.preparedFlag.Evaluate(env.user, discardPrerequisiteEvents)
+		if evalBenchmarkResult.Detail.Value.BoolValue() {
+			b.FailNow()
+		}
+	})
+}
+
+func BenchmarkPreparedEvaluationRuleMatchNoAlloc(b *testing.B) {
+	benchmarkEval(b, makeEvalBenchmarkCases(true), func(env *evalBenchmarkEnv) {
+		evalBenchmarkResult = env.preparedFlag.Evaluate(env.user, discardPrerequisiteEvents)
+		if !evalBenchmarkResult.Detail.Value.BoolValue() {
+			b.FailNow()
+		}
+	})
+}
+
+func BenchmarkPreparedEvaluationUserMatchedBySegmentRuleNoAlloc(b *testing.B) {
+	benchmarkEval(b, makeSegmentRuleMatchBenchmarkCases(), func(env *evalBenchmarkEnv) {
+		evalBenchmarkResult := env.preparedFlag.Evaluate(env.user, discardPrerequisiteEvents)
+		if !evalBenchmarkResult.Detail.Value.BoolValue() {
+			b.FailNow()
+		}
+	})
+}
+
 func makeEvalBenchmarkCases(shouldMatch bool) []evalBenchmarkCase {
 	ret := []evalBenchmarkCase{}
 	for _, op := range []ldmodel.Operator{
@@ -427,7 +476,11 @@ func makeEvalBenchmarkPrerequisites(
 	remainingDepth int,
 ) {
 	for i := 0; i < bc.prereqsWidth; i++ {
-		prereqBuilder := ldbuilders.NewFlagBuilder(fmt.Sprintf("flag-%d", *flagCounter)).
+		key := fmt.Sprintf("flag-%d", *flagCounter)
+		if bc.prereqsShared {
+			key = fmt.Sprintf("flag-shared-%d-%d", remainingDepth, i)
+		}
+		prereqBuilder := ldbuilders.NewFlagBuilder(key).
 			Version(1).
 			On(true).
 			FallthroughVariation(1).
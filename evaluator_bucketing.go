@@ -17,15 +17,46 @@ const (
 	initialHashInputBufferSize = 100
 )
 
-type bucketingFailureReason int
+// BucketingFailureReason describes why a bucket value could not be computed for a context. In that
+// case, the bucket value is zero. See BucketingDetail.
+type BucketingFailureReason int
 
 const (
-	bucketingFailureInvalidAttrRef bucketingFailureReason = iota + 1 // 0 means no failure
-	bucketingFailureContextLacksDesiredKind
-	bucketingFailureAttributeNotFound
-	bucketingFailureAttributeValueWrongType
+	// BucketingFailureInvalidAttrRef means that the rollout's bucketBy attribute reference was invalid.
+	// This also causes the evaluation to return a MALFORMED_FLAG error.
+	BucketingFailureInvalidAttrRef BucketingFailureReason = iota + 1 // 0 means no failure
+	// BucketingFailureContextLacksDesiredKind means that the context did not have the context kind that
+	// the rollout specified.
+	BucketingFailureContextLacksDesiredKind
+	// BucketingFailureAttributeNotFound means that the context did not have the bucketBy attribute.
+	BucketingFailureAttributeNotFound
+	// BucketingFailureAttributeValueWrongType means that the bucketBy attribute was neither a string nor
+	// an integer: for instance, a non-integer number.
+	BucketingFailureAttributeValueWrongType
 )
 
+// BucketingDetail describes how the context was bucketed for a percentage rollout or experiment, if
+// the result of a flag evaluation was determined by one. It is returned in Result.Bucketing.
+//
+// This is only about the rollout in the flag rule or fallthrough that determined the result; it does
+// not include bucketing that was done for segment rules, or for prerequisite flags.
+type BucketingDetail struct {
+	// Used is true if the result was determined by a percentage rollout or experiment. If it is false,
+	// all of the other fields have their zero values.
+	Used bool
+	// ContextKind is the kind of the context whose attribute was hashed. This is never empty; if the
+	// rollout did not specify a kind, it is ldcontext.DefaultKind.
+	ContextKind ldcontext.Kind
+	// Attribute is the attribute that was hashed. This is the context key unless the rollout specified
+	// some other attribute; experiments always use the key.
+	Attribute ldattr.Ref
+	// BucketValue is the computed bucket value, in the range [0, 1).
+	BucketValue float32
+	// FailureReason is nonzero if the bucket value could not be computed, in which case BucketValue is
+	// zero.
+	FailureReason BucketingFailureReason
+}
+
 // computeBucketValue is used for rollouts and experiments in flag rules, flag fallthroughs, and segment rules--
 // anywhere a rollout/experiment can be. It implements the logic in the flag evaluation spec for computing a
 // one-way hash from some combination of inputs related to the context and the flag or segment, and converting
@@ -48,7 +79,7 @@ func (es *evaluationScope) computeBucketValue(
 	key string,
 	attr ldattr.Ref,
 	salt string,
-) (float32, bucketingFailureReason, error) {
+) (float32, BucketingFailureReason, error) {
 	hashInput := internal.LocalBuffer{Data: make([]byte, 0, initialHashInputBufferSize)}
 	// As long as the total length of the append operations below doesn't exceed the initial size,
 	// this byte slice will stay on the stack. But since some of the data we're appending comes from
@@ -64,18 +95,17 @@ func (es *evaluationScope) computeBucketValue(
 	}
 	hashInput.AppendByte('.')
 
-	if isExperiment || !attr.IsDefined() { // always bucket by key in an experiment
-		attr = ldattr.NewLiteralRef(ldattr.KeyAttr)
-	} else if attr.Err() != nil {
-		return 0, bucketingFailureInvalidAttrRef, badAttrRefError(attr.String())
+	attr = bucketingAttribute(isExperiment, attr)
+	if attr.Err() != nil {
+		return 0, BucketingFailureInvalidAttrRef, BadAttrRefError(attr.String())
 	}
 	selectedContext := es.context.IndividualContextByKind(contextKind)
 	if !selectedContext.IsDefined() {
-		return 0, bucketingFailureContextLacksDesiredKind, nil
+		return 0, BucketingFailureContextLacksDesiredKind, nil
 	}
 	uValue := selectedContext.GetValueForRef(attr)
 	if uValue.IsNull() { // attributes can't be null, so null means it doesn't exist
-		return 0, bucketingFailureAttributeNotFound, nil
+		return 0, BucketingFailureAttributeNotFound, nil
 	}
 	switch {
 	case uValue.IsString():
@@ -85,7 +115,7 @@ func (es *evaluationScope) computeBucketValue(
 	default:
 		// Non-integer numbers, and values of any other JSON type, can't be used for bucketing because they have no
 		// single reliable representation as a string.
-		return 0, bucketingFailureAttributeValueWrongType, nil
+		return 0, BucketingFailureAttributeValueWrongType, nil
 	}
 
 	if es.owner.enableSecondaryKey && !isExperiment { // secondary key is not supported in experiments
@@ -107,3 +137,11 @@ func (es *evaluationScope) computeBucketValue(
 
 	return bucket, 0, nil
 }
+
+// bucketingAttribute returns the attribute that computeBucketValue will actually use.
+func bucketingAttribute(isExperiment bool, bucketBy ldattr.Ref) ldattr.Ref {
+	if isExperiment || !bucketBy.IsDefined() { // always bucket by key in an experiment
+		return ldattr.NewLiteralRef(ldattr.KeyAttr)
+	}
+	return bucketBy
+}
@@ -23,7 +23,7 @@ var noSeed = ldvalue.OptionalInt{}
 
 func makeEvalScope(context ldcontext.Context, evalOptions ...EvaluatorOption) *evaluationScope {
 	evaluator := NewEvaluatorWithOptions(basicDataProvider(), evalOptions...).(*evaluator)
-	return &evaluationScope{context: context, owner: evaluator}
+	return &evaluationScope{owner: evaluator, context: context, extras: &noEvaluationExtras}
 }
 
 func makeUserContextWithSecondaryKey(t *testing.T, key, secondary string) ldcontext.Context {
@@ -87,7 +87,7 @@ func TestRolloutBucketing(t *testing.T) {
 				bucketValue, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed,
 					rollout.ContextKind, p.flagOrSegmentKey, rollout.BucketBy, p.salt)
 				assert.NoError(t, err)
-				assert.Equal(t, bucketingFailureReason(0), failReason)
+				assert.Equal(t, BucketingFailureReason(0), failReason)
 				assert.InEpsilon(t, p.expectedBucketValue, bucketValue, 0.0000001)
 
 				variationIndex, inExperiment, err := makeEvalScope(context).variationOrRolloutResult(
@@ -146,14 +146,14 @@ func TestRolloutBucketing(t *testing.T) {
 						bucketValue1, failReason, err := evalScope1.computeBucketValue(false, noSeed,
 							"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 						assert.NoError(t, err)
-						assert.Equal(t, bucketingFailureReason(0), failReason)
+						assert.Equal(t, BucketingFailureReason(0), failReason)
 						assert.InEpsilon(t, p.expectedBucketValue, bucketValue1, 0.0000001)
 
 						evalScope2 := makeEvalScope(context2, EvaluatorOptionEnableSecondaryKey(true))
 						bucketValue2, failReason, err := evalScope2.computeBucketValue(false, noSeed,
 							"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 						assert.NoError(t, err)
-						assert.Equal(t, bucketingFailureReason(0), failReason)
+						assert.Equal(t, BucketingFailureReason(0), failReason)
 						assert.NotEqual(t, bucketValue1, bucketValue2)
 					})
 				}
@@ -169,14 +169,14 @@ func TestRolloutBucketing(t *testing.T) {
 						bucketValue1, failReason, err := evalScope1.computeBucketValue(false, noSeed,
 							"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 						assert.NoError(t, err)
-						assert.Equal(t, bucketingFailureReason(0), failReason)
+						assert.Equal(t, BucketingFailureReason(0), failReason)
 						assert.InEpsilon(t, p.expectedBucketValue, bucketValue1, 0.0000001)
 
 						evalScope2 := makeEvalScope(context2)
 						bucketValue2, failReason, err := evalScope2.computeBucketValue(false, noSeed,
 							"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 						assert.NoError(t, err)
-						assert.Equal(t, bucketingFailureReason(0), failReason)
+						assert.Equal(t, BucketingFailureReason(0), failReason)
 						assert.Equal(t, bucketValue1, bucketValue2)
 					})
 				}
@@ -205,7 +205,7 @@ func TestExperimentBucketing(t *testing.T) {
 		bucketValue, failReason, err := evalScope.computeBucketValue(true, p.seed,
 			experiment.ContextKind, p.flagOrSegmentKey, experiment.BucketBy, p.salt)
 		assert.NoError(t, err)
-		assert.Equal(t, bucketingFailureReason(0), failReason)
+		assert.Equal(t, BucketingFailureReason(0), failReason)
 		assert.InEpsilon(t, p.expectedBucketValue, bucketValue, 0.0000001)
 
 		experiment.Seed = p.seed
@@ -256,7 +256,7 @@ func TestExperimentBucketing(t *testing.T) {
 				bucketValue1, failReason, err := makeEvalScope(context).computeBucketValue(true, p.seed,
 					"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 				assert.NoError(t, err)
-				assert.Equal(t, bucketingFailureReason(0), failReason)
+				assert.Equal(t, BucketingFailureReason(0), failReason)
 
 				var modifiedSeed ldvalue.OptionalInt
 				if p.seed.IsDefined() {
@@ -267,7 +267,7 @@ func TestExperimentBucketing(t *testing.T) {
 				bucketValue2, failReason, err := makeEvalScope(context).computeBucketValue(true, modifiedSeed,
 					"", p.flagOrSegmentKey, ldattr.Ref{}, p.salt)
 				assert.NoError(t, err)
-				assert.Equal(t, bucketingFailureReason(0), failReason)
+				assert.Equal(t, BucketingFailureReason(0), failReason)
 
 				assert.NotEqual(t, bucketValue1, bucketValue2)
 			})
@@ -351,7 +351,7 @@ func TestComputeBucketValueInvalidConditions(t *testing.T) {
 		desiredKind := ldcontext.Kind("org")
 		bucket, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed, desiredKind, flagKey, ldattr.Ref{}, "saltyA")
 		assert.NoError(t, err)
-		assert.Equal(t, bucketingFailureContextLacksDesiredKind, failReason)
+		assert.Equal(t, BucketingFailureContextLacksDesiredKind, failReason)
 		assert.Equal(t, float32(0), bucket)
 	})
 
@@ -360,7 +360,7 @@ func TestComputeBucketValueInvalidConditions(t *testing.T) {
 		desiredKind := ldcontext.Kind("org")
 		bucket, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed, desiredKind, flagKey, ldattr.Ref{}, "saltyA")
 		assert.NoError(t, err)
-		assert.Equal(t, bucketingFailureContextLacksDesiredKind, failReason)
+		assert.Equal(t, BucketingFailureContextLacksDesiredKind, failReason)
 		assert.Equal(t, float32(0), bucket)
 	})
 
@@ -368,7 +368,7 @@ func TestComputeBucketValueInvalidConditions(t *testing.T) {
 		context := ldcontext.New("key")
 		bucket, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed, "", flagKey, ldattr.NewLiteralRef("unknownAttr"), salt)
 		assert.NoError(t, err)
-		assert.Equal(t, bucketingFailureAttributeNotFound, failReason)
+		assert.Equal(t, BucketingFailureAttributeNotFound, failReason)
 		assert.Equal(t, float32(0), bucket)
 	})
 
@@ -376,7 +376,7 @@ func TestComputeBucketValueInvalidConditions(t *testing.T) {
 		context := ldcontext.NewBuilder("key").SetFloat64("floatAttr", 999.999).Build()
 		bucket, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed, "", flagKey, ldattr.NewLiteralRef("floatAttr"), salt)
 		assert.NoError(t, err)
-		assert.Equal(t, bucketingFailureAttributeValueWrongType, failReason)
+		assert.Equal(t, BucketingFailureAttributeValueWrongType, failReason)
 		assert.Equal(t, float32(0), bucket)
 	})
 
@@ -385,7 +385,7 @@ func TestComputeBucketValueInvalidConditions(t *testing.T) {
 		badAttr := ldattr.NewRef("///")
 		_, failReason, err := makeEvalScope(context).computeBucketValue(false, noSeed, "", flagKey, badAttr, salt)
 		assert.Error(t, err) // Unlike the other invalid conditions, we treat this one as a malformed flag error
-		assert.Equal(t, bucketingFailureInvalidAttrRef, failReason)
+		assert.Equal(t, BucketingFailureInvalidAttrRef, failReason)
 	})
 }
 
@@ -406,3 +406,87 @@ func TestBucketValueBeyondLastBucketIsPinnedToLastBucketForExperiment(t *testing
 	assert.Equal(t, 1, variationIndex)
 	assert.True(t, inExperiment)
 }
+
+func TestResultBucketingDetail(t *testing.T) {
+	makeFlag := func(vr ldmodel.VariationOrRollout) ldmodel.FeatureFlag {
+		return ldbuilders.NewFlagBuilder("flag").On(true).Fallthrough(vr).Salt("salt").
+			Variations(ldvalue.String("a"), ldvalue.String("b")).Build()
+	}
+	rollout := ldbuilders.Rollout(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+
+	t.Run("not set if result was not from a rollout", func(t *testing.T) {
+		f := makeFlag(ldbuilders.Variation(1))
+		result := basicEvaluator().Evaluate(&f, ldcontext.New("key"), nil)
+		assert.Equal(t, BucketingDetail{}, result.Bucketing)
+	})
+
+	t.Run("rollout by key", func(t *testing.T) {
+		f := makeFlag(rollout)
+		context := ldcontext.New("key")
+		result := basicEvaluator().Evaluate(&f, context, nil)
+		expectedValue, _, _ := makeEvalScope(context).computeBucketValue(false, noSeed, "", f.Key, ldattr.Ref{}, f.Salt)
+		assert.Equal(t, BucketingDetail{
+			Used:        true,
+			ContextKind: ldcontext.DefaultKind,
+			Attribute:   ldattr.NewLiteralRef(ldattr.KeyAttr),
+			BucketValue: expectedValue,
+		}, result.Bucketing)
+	})
+
+	t.Run("rollout by attribute of another context kind", func(t *testing.T) {
+		vr := rollout
+		vr.Rollout.ContextKind = "org"
+		vr.Rollout.BucketBy = ldattr.NewRef("group")
+		f := makeFlag(vr)
+		context := ldcontext.NewBuilder("key").Kind("org").SetString("group", "x").Build()
+		result := basicEvaluator().Evaluate(&f, context, nil)
+		expectedValue, _, _ := makeEvalScope(context).computeBucketValue(false, noSeed, "org", f.Key,
+			vr.Rollout.BucketBy, f.Salt)
+		assert.Equal(t, BucketingDetail{
+			Used:        true,
+			ContextKind: "org",
+			Attribute:   vr.Rollout.BucketBy,
+			BucketValue: expectedValue,
+		}, result.Bucketing)
+	})
+
+	t.Run("experiment always uses key", func(t *testing.T) {
+		vr := ldbuilders.Experiment(ldvalue.NewOptionalInt(42), ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+		vr.Rollout.BucketBy = ldattr.NewRef("group")
+		f := makeFlag(vr)
+		result := basicEvaluator().Evaluate(&f, ldcontext.NewBuilder("key").SetString("group", "x").Build(), nil)
+		assert.True(t, result.Bucketing.Used)
+		assert.Equal(t, ldattr.NewLiteralRef(ldattr.KeyAttr), result.Bucketing.Attribute)
+	})
+
+	t.Run("failure reason", func(t *testing.T) {
+		vr := rollout
+		vr.Rollout.ContextKind = "org"
+		f := makeFlag(vr)
+		result := basicEvaluator().Evaluate(&f, ldcontext.New("key"), nil)
+		assert.Equal(t, BucketingDetail{
+			Used:          true,
+			ContextKind:   "org",
+			Attribute:     ldattr.NewLiteralRef(ldattr.KeyAttr),
+			FailureReason: BucketingFailureContextLacksDesiredKind,
+		}, result.Bucketing)
+		assert.Equal(t, 0, result.Detail.VariationIndex.IntValue())
+	})
+
+	t.Run("prerequisite", func(t *testing.T) {
+		prereq := makeFlag(rollout)
+		prereq.Key = "prereq"
+		f := ldbuilders.NewFlagBuilder("flag").On(true).AddPrerequisite(prereq.Key, 0).FallthroughVariation(0).
+			Variations(ldvalue.Bool(true)).Build()
+		evaluator := NewEvaluator(basicDataProvider().withStoredFlags(prereq))
+		context := ldcontext.New("key")
+		eventSink := prereqEventSink{}
+
+		result := evaluator.Evaluate(&f, context, eventSink.record)
+
+		assert.False(t, result.Bucketing.Used)
+		require.Len(t, eventSink.events, 1)
+		assert.Equal(t, evaluator.Evaluate(&prereq, context, nil).Bucketing, eventSink.events[0].PrerequisiteResult.Bucketing)
+		assert.True(t, eventSink.events[0].PrerequisiteResult.Bucketing.Used)
+	})
+}
@@ -5,46 +5,66 @@ import (
 	"time"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
-	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
 )
 
-func (es *evaluationScope) clauseMatchesContext(clause *ldmodel.Clause, stack evaluationStack) (bool, error) {
+// The plan parameter is nil unless the flag was prepared; see evaluator_prepare.go.
+func (es *evaluationScope) clauseMatchesContext(
+	clause *ldmodel.Clause,
+	plan *clausePlan,
+	stack evaluationStack,
+) (bool, error) {
 	// Note that clause is passed by reference only for efficiency; we do not modify it
+	if err := es.countStep(&es.stats.ClausesEvaluated); err != nil {
+		return false, err
+	}
 	// In the case of a segment match operator, we check if the user is in any of the segments,
 	// and possibly negate
 	if clause.Op == ldmodel.OperatorSegmentMatch {
-		for _, value := range clause.Values {
-			if value.Type() == ldvalue.StringType {
-				if segment := es.owner.dataProvider.GetSegment(value.StringValue()); segment != nil {
-					match, err := es.segmentContainsContext(segment, stack)
-					if err != nil {
-						return false, err
-					}
-					if match {
-						return !clause.Negate, nil // match - true unless negated
-					}
+		for i, value := range clause.Values {
+			var segment *ldmodel.Segment
+			var segPlan *segmentPlan
+			if plan != nil {
+				if segPlan = plan.segments[i]; segPlan != nil {
+					segment = segPlan.segment
+				}
+			} else if value.Type() == ldvalue.StringType {
+				segment = es.owner.dataProvider.GetSegment(value.StringValue())
+			}
+			if segment != nil {
+				var match bool
+				var err error
+				if es.extras.tracer != nil {
+					match, err = es.traceSegment(segment, segPlan, stack)
+				} else {
+					match, err = es.segmentContainsContext(segment, segPlan, stack)
+				}
+				if err != nil {
+					return false, err
+				}
+				if match {
+					return !clause.Negate, nil // match - true unless negated
 				}
 			}
 		}
 		return clause.Negate, nil // non-match - false unless negated
 	}
 
-	return clauseMatchesContextNoSegments(clause, &es.context)
+	return es.clauseMatchesContextNoSegments(clause, plan)
 }
 
-func clauseMatchesContextNoSegments(c *ldmodel.Clause, context *ldcontext.Context) (bool, error) {
+func (es *evaluationScope) clauseMatchesContextNoSegments(c *ldmodel.Clause, plan *clausePlan) (bool, error) {
 	if !c.Attribute.IsDefined() {
-		return false, emptyAttrRefError{}
+		return false, EmptyAttrRefError{}
 	}
 	if c.Attribute.Err() != nil {
-		return false, badAttrRefError(c.Attribute.String())
+		return false, BadAttrRefError(c.Attribute.String())
 	}
 	if c.Attribute.String() == ldattr.KindAttr {
-		return maybeNegate(c.Negate, clauseMatchByKind(c, context)), nil
+		return maybeNegate(c.Negate, es.clauseMatchByKind(c, plan)), nil
 	}
-	actualContext := context.IndividualContextByKind(c.ContextKind)
+	actualContext := es.context.IndividualContextByKind(c.ContextKind)
 	if !actualContext.IsDefined() {
 		return false, nil
 	}
@@ -54,17 +74,21 @@ func clauseMatchesContextNoSegments(c *ldmodel.Clause, context *ldcontext.Contex
 		return false, nil
 	}
 
+	if testsWholeArray(c.Op) {
+		return maybeNegate(c.Negate, es.matchArray(c, uValue)), nil
+	}
+
 	// If the user value is an array, see if the intersection is non-empty. If so, this clause matches
 	if uValue.Type() == ldvalue.ArrayType {
 		for i := 0; i < uValue.Count(); i++ {
-			if matchAny(c, uValue.GetByIndex(i)) {
+			if es.matchAny(c, plan, uValue.GetByIndex(i)) {
 				return maybeNegate(c.Negate, true), nil
 			}
 		}
 		return maybeNegate(c.Negate, false), nil
 	}
 
-	return maybeNegate(c.Negate, matchAny(c, uValue)), nil
+	return maybeNegate(c.Negate, es.matchAny(c, plan, uValue)), nil
 }
 
 func maybeNegate(negate, result bool) bool {
@@ -74,22 +98,97 @@ func maybeNegate(negate, result bool) bool {
 	return result
 }
 
-func matchAny(
+// testsWholeArray returns true if the operator is applied to an array attribute as a whole, rather
+// than to each of its elements.
+func testsWholeArray(op ldmodel.Operator) bool {
+	switch op {
+	case ldmodel.OperatorContainsAll, ldmodel.OperatorContainsNone,
+		ldmodel.OperatorSizeGreaterThan, ldmodel.OperatorSizeLessThan:
+		return true
+	default:
+		return false
+	}
+}
+
+func (es *evaluationScope) matchAny(
 	c *ldmodel.Clause,
+	plan *clausePlan,
 	value ldvalue.Value,
 ) bool {
-	if c.Op == ldmodel.OperatorIn {
+	if plan != nil {
+		var matched bool
+		var tested int
+		if plan.relativeDates != nil {
+			matched, tested = es.matchRelativeDates(plan.relativeDates, value)
+		} else {
+			matched, tested = plan.match(value)
+		}
+		es.countClauseValues(c.Op, tested)
+		return matched
+	}
+	switch c.Op {
+	case ldmodel.OperatorIn, ldmodel.OperatorInIgnoreCase:
 		return ldmodel.EvaluatorAccessors.ClauseFindValue(c, value)
+	case ldmodel.OperatorIPInRange, ldmodel.OperatorIPEquals:
+		// The clause values are preprocessed into a single set of ranges, so they are not tested one
+		// at a time like the other operators.
+		return ldmodel.EvaluatorAccessors.ClauseMatchesIPAddress(c, value)
+	case ldmodel.OperatorEndsWithIgnoreCase, ldmodel.OperatorStartsWithIgnoreCase,
+		ldmodel.OperatorContainsIgnoreCase:
+		// Normalize the context value once, rather than for each clause value.
+		normalized, ok := ldmodel.TypeConversions.ValueToNormalizedString(value)
+		if !ok {
+			return false
+		}
+		value = ldvalue.String(normalized)
+	}
+	for i, v := range c.Values {
+		if es.doOp(c, value, v, i) {
+			es.countClauseValues(c.Op, i+1)
+			return true
+		}
+	}
+	es.countClauseValues(c.Op, len(c.Values))
+	return false
+}
+
+// matchArray is used instead of matchAny for operators that test an array attribute as a whole. A
+// value that is not an array is treated as an array containing only that value.
+func (es *evaluationScope) matchArray(
+	c *ldmodel.Clause,
+	value ldvalue.Value,
+) bool {
+	if len(c.Values) == 0 {
+		return false // as with all other operators, a clause with no values never matches
+	}
+	switch c.Op {
+	case ldmodel.OperatorContainsAll:
+		return ldmodel.EvaluatorAccessors.ClauseContainsAllValues(c, value)
+	case ldmodel.OperatorContainsNone:
+		return !ldmodel.EvaluatorAccessors.ClauseContainsAnyValue(c, value)
+	}
+	size := 1
+	if value.Type() == ldvalue.ArrayType {
+		size = value.Count()
 	}
 	for i, v := range c.Values {
-		if doOp(c, value, v, i) {
+		var match bool
+		switch c.Op {
+		case ldmodel.OperatorSizeGreaterThan:
+			match = v.IsNumber() && float64(size) > v.Float64Value()
+		case ldmodel.OperatorSizeLessThan:
+			match = v.IsNumber() && float64(size) < v.Float64Value()
+		}
+		if match {
+			es.countClauseValues(c.Op, i+1)
 			return true
 		}
 	}
+	es.countClauseValues(c.Op, len(c.Values))
 	return false
 }
 
-func doOp(c *ldmodel.Clause, ctxValue, clValue ldvalue.Value, index int) bool {
+func (es *evaluationScope) doOp(c *ldmodel.Clause, ctxValue, clValue ldvalue.Value, index int) bool {
 	switch c.Op {
 	case ldmodel.OperatorEndsWith:
 		return stringOperator(ctxValue, clValue, strings.HasSuffix)
@@ -99,6 +198,12 @@ func doOp(c *ldmodel.Clause, ctxValue, clValue ldvalue.Value, index int) bool {
 		return operatorMatchesFn(c, ctxValue, index)
 	case ldmodel.OperatorContains:
 		return stringOperator(ctxValue, clValue, strings.Contains)
+	case ldmodel.OperatorEndsWithIgnoreCase:
+		return normalizedStringOperator(c, ctxValue, index, strings.HasSuffix)
+	case ldmodel.OperatorStartsWithIgnoreCase:
+		return normalizedStringOperator(c, ctxValue, index, strings.HasPrefix)
+	case ldmodel.OperatorContainsIgnoreCase:
+		return normalizedStringOperator(c, ctxValue, index, strings.Contains)
 	case ldmodel.OperatorLessThan:
 		return numericOperator(ctxValue, clValue, func(a float64, b float64) bool { return a < b })
 	case ldmodel.OperatorLessThanOrEqual:
@@ -111,6 +216,10 @@ func doOp(c *ldmodel.Clause, ctxValue, clValue ldvalue.Value, index int) bool {
 		return dateOperator(c, ctxValue, index, time.Time.Before)
 	case ldmodel.OperatorAfter:
 		return dateOperator(c, ctxValue, index, time.Time.After)
+	case ldmodel.OperatorBeforeRelative:
+		return es.relativeDateOperator(c, ctxValue, index, time.Time.Before)
+	case ldmodel.OperatorAfterRelative:
+		return es.relativeDateOperator(c, ctxValue, index, time.Time.After)
 	case ldmodel.OperatorSemVerEqual:
 		return semVerOperator(c, ctxValue, index, 0)
 	case ldmodel.OperatorSemVerLessThan:
@@ -118,26 +227,41 @@ func doOp(c *ldmodel.Clause, ctxValue, clValue ldvalue.Value, index int) bool {
 	case ldmodel.OperatorSemVerGreaterThan:
 		return semVerOperator(c, ctxValue, index, 1)
 	}
+	// The built-in operators cannot be overridden, so we only need to look for a custom operator if
+	// it was none of the above.
+	if fn := es.owner.customOperators[c.Op]; fn != nil {
+		return fn(ctxValue, clValue, ldmodel.EvaluatorAccessors.ClauseGetValueAsCustom(c, index))
+	}
 	return false
 }
 
-func clauseMatchByKind(c *ldmodel.Clause, context *ldcontext.Context) bool {
+func (es *evaluationScope) clauseMatchByKind(c *ldmodel.Clause, plan *clausePlan) bool {
 	// If Attribute is "kind", then we treat Operator and Values as a match expression against a list
 	// of all individual kinds in the context. That is, for a multi-kind context with kinds of "org"
 	// and "user", it is a match if either of those strings is a match with Operator and Values.
-	if context.Multiple() {
-		for i := 0; i < context.IndividualContextCount(); i++ {
-			if individualContext := context.IndividualContextByIndex(i); individualContext.IsDefined() {
+	// Operators that test an array as a whole are applied to the list of kinds.
+	if testsWholeArray(c.Op) {
+		kinds := ldvalue.ArrayBuildWithCapacity(es.context.IndividualContextCount())
+		for i := 0; i < es.context.IndividualContextCount(); i++ {
+			if individualContext := es.context.IndividualContextByIndex(i); individualContext.IsDefined() {
+				kinds.Add(ldvalue.String(string(individualContext.Kind())))
+			}
+		}
+		return es.matchArray(c, kinds.Build())
+	}
+	if es.context.Multiple() {
+		for i := 0; i < es.context.IndividualContextCount(); i++ {
+			if individualContext := es.context.IndividualContextByIndex(i); individualContext.IsDefined() {
 				ctxValue := ldvalue.String(string(individualContext.Kind()))
-				if matchAny(c, ctxValue) {
+				if es.matchAny(c, plan, ctxValue) {
 					return true
 				}
 			}
 		}
 		return false
 	}
-	ctxValue := ldvalue.String(string(context.Kind()))
-	return matchAny(c, ctxValue)
+	ctxValue := ldvalue.String(string(es.context.Kind()))
+	return es.matchAny(c, plan, ctxValue)
 }
 
 func stringOperator(
@@ -150,6 +274,19 @@ func stringOperator(
 	return false
 }
 
+// normalizedStringOperator expects ctxValue to have already been normalized by matchAny.
+func normalizedStringOperator(
+	c *ldmodel.Clause,
+	ctxValue ldvalue.Value,
+	clValueIndex int,
+	stringTestFn func(string, string) bool,
+) bool {
+	if clValueStr, ok := ldmodel.EvaluatorAccessors.ClauseGetValueAsNormalizedString(c, clValueIndex); ok {
+		return stringTestFn(ctxValue.StringValue(), clValueStr)
+	}
+	return false
+}
+
 func operatorMatchesFn(c *ldmodel.Clause, ctxValue ldvalue.Value, clValueIndex int) bool {
 	if ctxValue.IsString() {
 		r := ldmodel.EvaluatorAccessors.ClauseGetValueAsRegexp(c, clValueIndex)
@@ -181,6 +318,20 @@ func dateOperator(
 	return false
 }
 
+func (es *evaluationScope) relativeDateOperator(
+	c *ldmodel.Clause,
+	ctxValue ldvalue.Value,
+	clValueIndex int,
+	fn func(time.Time, time.Time) bool,
+) bool {
+	if clValueDuration, ok := ldmodel.EvaluatorAccessors.ClauseGetValueAsDuration(c, clValueIndex); ok {
+		if ctxValueTime, ok := ldmodel.TypeConversions.ValueToTimestamp(ctxValue); ok {
+			return fn(ctxValueTime, es.now().Add(clValueDuration))
+		}
+	}
+	return false
+}
+
 func semVerOperator(
 	c *ldmodel.Clause,
 	ctxValue ldvalue.Value,
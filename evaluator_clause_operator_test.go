@@ -3,6 +3,7 @@ package evaluation
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
@@ -52,6 +53,23 @@ var operatorTests = []opTestInfo{
 	{"contains", "xyz", "y", nil, true},
 	{"contains", "y", "xyz", nil, false},
 
+	// case-insensitive string operators
+	{"inIgnoreCase", "Ada@Example.COM", "ada@example.com", nil, true},
+	{"inIgnoreCase", "x", "Y", []interface{}{"a", "X"}, true},
+	{"inIgnoreCase", "x", "xyz", []interface{}{"a"}, false},
+	{"inIgnoreCase", "Straße", "STRASSE", nil, true},
+	{"inIgnoreCase", "Cafe\u0301", "CAFÉ", []interface{}{"tea"}, true},
+	{"inIgnoreCase", int(99), int(99), nil, false},
+	{"inIgnoreCase", "99", int(99), []interface{}{"a"}, false},
+	{"startsWithIgnoreCase", "XYZ", "x", nil, true},
+	{"startsWithIgnoreCase", "x", "XYZ", nil, false},
+	{"endsWithIgnoreCase", "user@EXAMPLE.com", "@example.COM", nil, true},
+	{"endsWithIgnoreCase", "user@example.com", "@example.org", []interface{}{"@Example.com"}, true},
+	{"containsIgnoreCase", "ÉCOLE", "co", nil, true},
+	{"containsIgnoreCase", "Cafe\u0301 noir", "É", nil, true},
+	{"containsIgnoreCase", "y", "XYZ", nil, false},
+	{"containsIgnoreCase", "99", int(99), nil, false},
+
 	// mixed strings and numbers
 	{"in", "99", int(99), nil, false},
 	{"in", int(99), "99", nil, false},
@@ -115,6 +133,47 @@ var operatorTests = []opTestInfo{
 	{"semVerGreaterThan", "2.0.1", "xbad%ver", nil, false},
 	{"semVerGreaterThan", "2.0.0-rc.1", "2.0.0-rc.0", nil, true},
 
+	// IP address operators
+	{"ipInRange", "10.1.2.3", "10.0.0.0/8", nil, true},
+	{"ipInRange", "11.1.2.3", "10.0.0.0/8", nil, false},
+	{"ipInRange", "10.1.2.3", "10.1.2.3", nil, true},
+	{"ipInRange", "10.1.2.3", "10.0.0.0/8", []interface{}{"192.168.0.0/16", "2001:db8::/32"}, true},
+	{"ipInRange", "::ffff:10.1.2.3", "10.0.0.0/8", nil, true},
+	{"ipInRange", "2001:db8:1::5", "2001:db8::/32", nil, true},
+	{"ipInRange", "2001:db9::5", "2001:db8::/32", nil, false},
+	{"ipInRange", "fe80::1%eth0", "fe80::/10", nil, true},
+	{"ipInRange", "0.0.0.0", "::/0", nil, false},
+	{"ipInRange", "10.1.2.3", "not an address", nil, false},
+	{"ipInRange", "not an address", "10.0.0.0/8", nil, false},
+	{"ipInRange", int(10), "10.0.0.0/8", nil, false},
+	{"ipEquals", "2001:db8::1", "2001:0db8:0:0:0:0:0:1", nil, true},
+	{"ipEquals", "10.1.2.3", "::ffff:10.1.2.3", nil, true},
+	{"ipEquals", "10.1.2.3", "10.1.2.4", []interface{}{"10.1.2.3"}, true},
+	{"ipEquals", "10.1.2.3", "10.0.0.0/8", nil, false},
+
+	// array operators
+	{"containsAll", []interface{}{"a", "b", "c"}, "a", []interface{}{"b"}, true},
+	{"containsAll", []interface{}{"a", "c"}, "a", []interface{}{"b"}, false},
+	{"containsAll", []interface{}{"a", "a"}, "a", []interface{}{"b"}, false},
+	{"containsAll", []interface{}{"b", "a"}, "a", []interface{}{"b", "a"}, true},
+	{"containsAll", []interface{}{int(1), "a", true}, int(1), []interface{}{true}, true},
+	{"containsAll", []interface{}{"1"}, int(1), nil, false},
+	{"containsAll", []interface{}{}, "a", nil, false},
+	{"containsAll", "a", "a", nil, true},
+	{"containsAll", []interface{}{"a", "b"}, "a", []interface{}{[]interface{}{"b"}}, false},
+	{"containsNone", []interface{}{"a", "b"}, "c", []interface{}{"d"}, true},
+	{"containsNone", []interface{}{"a", "b"}, "c", []interface{}{"b"}, false},
+	{"containsNone", []interface{}{}, "a", nil, true},
+	{"containsNone", "a", "a", nil, false},
+	{"sizeGreaterThan", []interface{}{"a", "b"}, int(1), nil, true},
+	{"sizeGreaterThan", []interface{}{"a", "b"}, int(2), nil, false},
+	{"sizeGreaterThan", []interface{}{"a", "b"}, int(5), []interface{}{int(1)}, true},
+	{"sizeGreaterThan", "a", int(0), nil, true},
+	{"sizeGreaterThan", []interface{}{"a", "b"}, "1", nil, false},
+	{"sizeLessThan", []interface{}{}, int(1), nil, true},
+	{"sizeLessThan", []interface{}{"a", "b"}, int(2), nil, false},
+	{"sizeLessThan", []interface{}{"a", "b"}, float64(2.5), nil, true},
+
 	// invalid operator
 	{"whatever", "x", "x", nil, false},
 }
@@ -143,7 +202,7 @@ func TestAllOperators(t *testing.T) {
 						c = flag.Rules[0].Clauses[0]
 					}
 					context := ldcontext.NewBuilder("key").SetValue(userAttr, uValue).Build()
-					isMatch, err := makeEvalScope(context).clauseMatchesContext(&c, evaluationStack{})
+					isMatch, err := makeEvalScope(context).clauseMatchesContext(&c, nil, evaluationStack{})
 					assert.NoError(t, err)
 					assert.Equal(t, ti.expected, isMatch)
 				},
@@ -151,3 +210,119 @@ func TestAllOperators(t *testing.T) {
 		}
 	}
 }
+
+func TestRelativeDateOperators(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	createdAt := ldvalue.String("2024-02-10T12:00:00Z") // 20 days earlier
+	createdAtMillis := ldvalue.Float64(float64(now.Add(-20 * 24 * time.Hour).UnixMilli()))
+	for _, p := range []struct {
+		op          ldmodel.Operator
+		userValue   ldvalue.Value
+		clauseValue ldvalue.Value
+		expected    bool
+	}{
+		{ldmodel.OperatorAfterRelative, createdAt, ldvalue.String("-P30D"), true},
+		{ldmodel.OperatorAfterRelative, createdAt, ldvalue.String("-P10D"), false},
+		{ldmodel.OperatorAfterRelative, createdAtMillis, ldvalue.String("-720h"), true},
+		{ldmodel.OperatorBeforeRelative, createdAt, ldvalue.String("-P10D"), true},
+		{ldmodel.OperatorBeforeRelative, createdAt, ldvalue.String("-P30D"), false},
+		{ldmodel.OperatorBeforeRelative, ldvalue.String("2024-03-05T00:00:00Z"), ldvalue.String("P7D"), true},
+		{ldmodel.OperatorBeforeRelative, ldvalue.String("2024-03-09T00:00:00Z"), ldvalue.String("P7D"), false},
+		{ldmodel.OperatorAfterRelative, ldvalue.String("2024-03-05T00:00:00Z"), ldvalue.String("0s"), true},
+		{ldmodel.OperatorAfterRelative, createdAt, ldvalue.String("30 days"), false},
+		{ldmodel.OperatorAfterRelative, ldvalue.String(invalidDate), ldvalue.String("-P30D"), false},
+	} {
+		for _, withPreprocessing := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s %s %s should be %t (preprocess: %t)", p.userValue, p.op, p.clauseValue,
+				p.expected, withPreprocessing), func(t *testing.T) {
+				c := ldbuilders.Clause("attr", p.op, p.clauseValue)
+				if withPreprocessing {
+					flag := ldmodel.FeatureFlag{Rules: []ldmodel.FlagRule{{Clauses: []ldmodel.Clause{c}}}}
+					ldmodel.PreprocessFlag(&flag)
+					c = flag.Rules[0].Clauses[0]
+				}
+				context := ldcontext.NewBuilder("key").SetValue("attr", p.userValue).Build()
+				scope := makeEvalScope(context, EvaluatorOptionClock(func() time.Time { return now }))
+				isMatch, err := scope.clauseMatchesContext(&c, nil, evaluationStack{})
+				assert.NoError(t, err)
+				assert.Equal(t, p.expected, isMatch)
+			})
+		}
+	}
+}
+
+func TestRelativeDateOperatorsUseCurrentTimeByDefault(t *testing.T) {
+	c := ldbuilders.Clause("attr", ldmodel.OperatorAfterRelative, ldvalue.String("-PT1H"))
+	context := ldcontext.NewBuilder("key").
+		SetFloat64("attr", float64(time.Now().Add(-time.Minute).UnixMilli())).Build()
+	isMatch, err := makeEvalScope(context).clauseMatchesContext(&c, nil, evaluationStack{})
+	assert.NoError(t, err)
+	assert.True(t, isMatch)
+}
+
+func TestCustomOperator(t *testing.T) {
+	tierOp := ldmodel.Operator("tierAtLeast")
+	tiers := map[string]int{"free": 0, "pro": 1, "enterprise": 2}
+	tierAtLeast := func(contextValue, clauseValue ldvalue.Value, preprocessed interface{}) bool {
+		minTier, ok := preprocessed.(int)
+		if !ok {
+			minTier, ok = tiers[clauseValue.StringValue()]
+			if !ok {
+				return false
+			}
+		}
+		tier, ok := tiers[contextValue.StringValue()]
+		return ok && tier >= minTier
+	}
+	clause := ldbuilders.Clause("tier", tierOp, ldvalue.String("bad"), ldvalue.String("pro"))
+
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocess: %t", withPreprocessing), func(t *testing.T) {
+			c := clause
+			if withPreprocessing {
+				flag := ldmodel.FeatureFlag{Rules: []ldmodel.FlagRule{{Clauses: []ldmodel.Clause{c}}}}
+				ldmodel.PreprocessFlagWithCustomOperators(&flag, ldmodel.CustomOperatorPreprocessors{
+					tierOp: func(v ldvalue.Value) interface{} {
+						if tier, ok := tiers[v.StringValue()]; ok {
+							return tier
+						}
+						return nil
+					},
+				})
+				c = flag.Rules[0].Clauses[0]
+			}
+			for _, p := range []struct {
+				tier     ldvalue.Value
+				expected bool
+			}{
+				{ldvalue.String("free"), false},
+				{ldvalue.String("pro"), true},
+				{ldvalue.String("enterprise"), true},
+				{ldvalue.ArrayOf(ldvalue.String("free"), ldvalue.String("enterprise")), true},
+				{ldvalue.String("unknown"), false},
+			} {
+				context := ldcontext.NewBuilder("key").SetValue("tier", p.tier).Build()
+
+				isMatch, err := makeEvalScope(context, EvaluatorOptionCustomOperator(tierOp, tierAtLeast)).
+					clauseMatchesContext(&c, nil, evaluationStack{})
+				assert.NoError(t, err)
+				assert.Equal(t, p.expected, isMatch, p.tier.JSONString())
+
+				isMatch, err = makeEvalScope(context).clauseMatchesContext(&c, nil, evaluationStack{})
+				assert.NoError(t, err)
+				assert.False(t, isMatch, "operator should not match if not defined for the evaluator")
+			}
+		})
+	}
+}
+
+func TestCustomOperatorCannotOverrideBuiltInOperator(t *testing.T) {
+	clause := ldbuilders.Clause("attr", ldmodel.OperatorIn, ldvalue.String("a"))
+	context := ldcontext.NewBuilder("key").SetString("attr", "b").Build()
+	alwaysTrue := func(ldvalue.Value, ldvalue.Value, interface{}) bool { return true }
+
+	isMatch, err := makeEvalScope(context, EvaluatorOptionCustomOperator(ldmodel.OperatorIn, alwaysTrue)).
+		clauseMatchesContext(&clause, nil, evaluationStack{})
+	assert.NoError(t, err)
+	assert.False(t, isMatch)
+}
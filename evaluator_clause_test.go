@@ -20,7 +20,7 @@ import (
 // affect the other aspects of clause behavior.
 
 func assertClauseMatch(t *testing.T, shouldMatch bool, clause ldmodel.Clause, context ldcontext.Context) {
-	match, err := makeEvalScope(context).clauseMatchesContext(&clause, evaluationStack{})
+	match, err := makeEvalScope(context).clauseMatchesContext(&clause, nil, evaluationStack{})
 	assert.NoError(t, err)
 	assert.Equal(t, shouldMatch, match)
 }
@@ -38,7 +38,7 @@ func doClauseMatchTest(t *testing.T, p clauseMatchParams) {
 		desc = "should match"
 	}
 	t.Run(fmt.Sprintf("%s, %s", p.name, desc), func(t *testing.T) {
-		match, err := makeEvalScope(p.context).clauseMatchesContext(&p.clause, evaluationStack{})
+		match, err := makeEvalScope(p.context).clauseMatchesContext(&p.clause, nil, evaluationStack{})
 		require.NoError(t, err)
 		assert.Equal(t, p.shouldMatch, match)
 	})
@@ -354,20 +354,62 @@ func TestClauseMatchOnKindAttribute(t *testing.T) {
 	})
 }
 
+func TestClauseMatchOnKindAttributeWithArrayOperators(t *testing.T) {
+	// Operators that test an array as a whole are applied to the list of all individual kinds.
+	multiContext := ldcontext.NewMulti(ldcontext.NewWithKind("org", "b"), ldcontext.New("a"))
+	singleContext := ldcontext.NewWithKind("org", "b")
+	kindValues := []ldvalue.Value{ldvalue.String("org"), ldvalue.String(string(ldcontext.DefaultKind))}
+
+	for _, p := range []clauseMatchParams{
+		{
+			name:        "containsAll, multi-kind context has all kinds",
+			clause:      ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorContainsAll, kindValues...),
+			context:     multiContext,
+			shouldMatch: true,
+		},
+		{
+			name:        "containsAll, single-kind context does not have all kinds",
+			clause:      ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorContainsAll, kindValues...),
+			context:     singleContext,
+			shouldMatch: false,
+		},
+		{
+			name:        "containsNone, multi-kind context has one of the kinds",
+			clause:      ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorContainsNone, ldvalue.String("org")),
+			context:     multiContext,
+			shouldMatch: false,
+		},
+		{
+			name:        "sizeGreaterThan, multi-kind context",
+			clause:      ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorSizeGreaterThan, ldvalue.Int(1)),
+			context:     multiContext,
+			shouldMatch: true,
+		},
+		{
+			name:        "sizeGreaterThan, single-kind context",
+			clause:      ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorSizeGreaterThan, ldvalue.Int(1)),
+			context:     singleContext,
+			shouldMatch: false,
+		},
+	} {
+		doClauseMatchTest(t, p)
+	}
+}
+
 func TestClauseMatchErrorConditions(t *testing.T) {
 	t.Run("unspecified attribute", func(t *testing.T) {
 		clause := ldbuilders.ClauseRef(ldattr.Ref{}, ldmodel.OperatorIn, ldvalue.Int(4))
 		context := ldcontext.New("key")
-		match, err := makeEvalScope(context).clauseMatchesContext(&clause, evaluationStack{})
-		assert.Equal(t, emptyAttrRefError{}, err)
+		match, err := makeEvalScope(context).clauseMatchesContext(&clause, nil, evaluationStack{})
+		assert.Equal(t, EmptyAttrRefError{}, err)
 		assert.False(t, match)
 	})
 
 	t.Run("invalid attribute reference", func(t *testing.T) {
 		clause := ldbuilders.ClauseRef(ldattr.NewRef("///"), ldmodel.OperatorIn, ldvalue.Int(4))
 		context := ldcontext.New("key")
-		match, err := makeEvalScope(context).clauseMatchesContext(&clause, evaluationStack{})
-		assert.Equal(t, badAttrRefError("///"), err)
+		match, err := makeEvalScope(context).clauseMatchesContext(&clause, nil, evaluationStack{})
+		assert.Equal(t, BadAttrRefError("///"), err)
 		assert.False(t, match)
 	})
 }
@@ -2,6 +2,7 @@ package evaluation
 
 import (
 	"testing"
+	"time"
 
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
@@ -10,6 +11,7 @@ import (
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
 	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldtime"
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
 
@@ -216,3 +218,85 @@ func TestUserNotSpecifiedErrorForInvalidContext(t *testing.T) {
 	result := basicEvaluator().Evaluate(&f, badContext, FailOnAnyPrereqEvent(t))
 	assertResultDetail(t, ldreason.NewEvaluationDetailForError(ldreason.EvalErrorUserNotSpecified, ldvalue.Null()), result)
 }
+
+func makeSteppingClock(start time.Time) (func() time.Time, *int) {
+	calls := 0
+	return func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * time.Hour)
+	}, &calls
+}
+
+func TestEvaluationTimeIsReadOncePerEvaluation(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clause := ldbuilders.Clause("createdAt", ldmodel.OperatorAfterRelative, ldvalue.String("-PT90M"))
+	prereq := ldbuilders.NewFlagBuilder("prereq").On(true).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(clause)).
+		FallthroughVariation(0).Variations(offValue, onValue).Build()
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).AddPrerequisite(prereq.Key, 1).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(clause)).
+		FallthroughVariation(0).Variations(fallthroughValue, onValue).Build()
+	// The context only matches the rules if both of them use the time of the first call to the clock.
+	context := ldcontext.NewBuilder("key").SetString("createdAt", "2024-03-01T11:00:00Z").Build()
+	clock, calls := makeSteppingClock(start)
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredFlags(prereq), EvaluatorOptionClock(clock))
+
+	eventSink := prereqEventSink{}
+	result := evaluator.Evaluate(&flag, context, eventSink.record)
+
+	m.In(t).Assert(result, ResultDetailProps(1, onValue, ldreason.NewEvalReasonRuleMatch(0, "")))
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, start, result.EvaluationTime)
+	require.Len(t, eventSink.events, 1)
+	assert.Equal(t, start, eventSink.events[0].PrerequisiteResult.EvaluationTime)
+}
+
+func TestEvaluationTimeIsZeroIfNotUsed(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).FallthroughVariation(0).
+		Variations(fallthroughValue).Build()
+	clock, calls := makeSteppingClock(time.Now())
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionClock(clock))
+
+	result := evaluator.Evaluate(&flag, flagTestContext, nil)
+	assert.Equal(t, 0, *calls)
+	assert.True(t, result.EvaluationTime.IsZero())
+	assert.False(t, result.DebugEventsActive)
+}
+
+func TestDebugEventsActive(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionClock(func() time.Time { return now }))
+
+	for _, p := range []struct {
+		name     string
+		until    time.Time
+		expected bool
+	}{
+		{"before date", now.Add(time.Minute), true},
+		{"after date", now.Add(-time.Minute), false},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			flag := ldbuilders.NewFlagBuilder("feature").On(true).FallthroughVariation(0).
+				DebugEventsUntilDate(ldtime.UnixMillisFromTime(p.until)).Variations(fallthroughValue).Build()
+			result := evaluator.Evaluate(&flag, flagTestContext, nil)
+			assert.Equal(t, p.expected, result.DebugEventsActive)
+			assert.Equal(t, now, result.EvaluationTime)
+		})
+	}
+
+	t.Run("for prerequisite", func(t *testing.T) {
+		prereq := ldbuilders.NewFlagBuilder("prereq").On(true).FallthroughVariation(0).
+			DebugEventsUntilDate(ldtime.UnixMillisFromTime(now.Add(time.Minute))).Variations(onValue).Build()
+		flag := ldbuilders.NewFlagBuilder("feature").On(true).AddPrerequisite(prereq.Key, 0).
+			FallthroughVariation(0).Variations(fallthroughValue).Build()
+		evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredFlags(prereq),
+			EvaluatorOptionClock(func() time.Time { return now }))
+
+		eventSink := prereqEventSink{}
+		result := evaluator.Evaluate(&flag, flagTestContext, eventSink.record)
+		assert.False(t, result.DebugEventsActive)
+		require.Len(t, eventSink.events, 1)
+		assert.True(t, eventSink.events[0].PrerequisiteResult.DebugEventsActive)
+		assert.Equal(t, now, eventSink.events[0].PrerequisiteResult.EvaluationTime)
+	})
+}
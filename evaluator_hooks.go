@@ -0,0 +1,60 @@
+package evaluation
+
+import (
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// hookSeries holds the state of the hooks for one flag evaluation, between the BeforeEvaluation and
+// AfterEvaluation calls. It is only used if the evaluator has hooks; otherwise it is always a zero
+// value, so that evaluations without hooks do not cause any heap allocations.
+type hookSeries struct {
+	info  EvaluationHookInfo
+	data  []interface{}
+	start time.Time
+}
+
+// beforeEvaluation calls BeforeEvaluation for every hook, in the order that they were specified.
+func (e *evaluator) beforeEvaluation(
+	flag *ldmodel.FeatureFlag,
+	contextKinds []ldcontext.Kind,
+	prerequisiteOf string,
+) hookSeries {
+	series := hookSeries{
+		info: EvaluationHookInfo{
+			FlagKey:        flag.Key,
+			FlagVersion:    flag.Version,
+			ContextKinds:   contextKinds,
+			PrerequisiteOf: prerequisiteOf,
+		},
+		data: make([]interface{}, len(e.hooks)),
+	}
+	for i, h := range e.hooks {
+		series.data[i] = h.BeforeEvaluation(series.info)
+	}
+	series.start = time.Now()
+	return series
+}
+
+// afterEvaluation calls AfterEvaluation for every hook, in the reverse of the order that they were
+// specified, so that the hooks are nested like deferred function calls.
+func (e *evaluator) afterEvaluation(series *hookSeries, result Result) {
+	duration := time.Since(series.start)
+	for i := len(e.hooks) - 1; i >= 0; i-- {
+		e.hooks[i].AfterEvaluation(series.info, series.data[i], result, duration)
+	}
+}
+
+// contextKindsForHooks returns the kinds of a context, for EvaluationHookInfo.
+func contextKindsForHooks(context *ldcontext.Context) []ldcontext.Kind {
+	if context.Err() != nil {
+		return nil
+	}
+	ret := make([]ldcontext.Kind, 0, context.IndividualContextCount())
+	for i := 0; i < context.IndividualContextCount(); i++ {
+		ret = append(ret, context.IndividualContextByIndex(i).Kind())
+	}
+	return ret
+}
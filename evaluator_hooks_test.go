@@ -0,0 +1,199 @@
+package evaluation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hookCall struct {
+	hook     string
+	stage    string
+	info     EvaluationHookInfo
+	data     interface{}
+	result   Result
+	duration time.Duration
+}
+
+type hookCallSink struct {
+	calls []hookCall
+}
+
+type recordingHook struct {
+	name string
+	sink *hookCallSink
+}
+
+func (h recordingHook) BeforeEvaluation(info EvaluationHookInfo) interface{} {
+	h.sink.calls = append(h.sink.calls, hookCall{hook: h.name, stage: "before", info: info})
+	return fmt.Sprintf("%s data for %s", h.name, info.FlagKey)
+}
+
+func (h recordingHook) AfterEvaluation(
+	info EvaluationHookInfo,
+	data interface{},
+	result Result,
+	duration time.Duration,
+) {
+	h.sink.calls = append(h.sink.calls, hookCall{hook: h.name, stage: "after", info: info, data: data,
+		result: result, duration: duration})
+}
+
+func (s *hookCallSink) summary() []string {
+	ret := make([]string, 0, len(s.calls))
+	for _, c := range s.calls {
+		ret = append(ret, fmt.Sprintf("%s %s %s", c.hook, c.stage, c.info.FlagKey))
+	}
+	return ret
+}
+
+func TestHooksAreCalledForEvaluation(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").Version(2).On(true).FallthroughVariation(1).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(),
+		EvaluatorOptionHooks(recordingHook{"a", sink}, nil, recordingHook{"b", sink}))
+
+	result := evaluator.Evaluate(&flag, flagTestContext, nil)
+
+	assert.Equal(t, []string{"a before feature", "b before feature", "b after feature", "a after feature"},
+		sink.summary())
+	require.Len(t, sink.calls, 4)
+	expectedInfo := EvaluationHookInfo{FlagKey: "feature", FlagVersion: 2,
+		ContextKinds: []ldcontext.Kind{ldcontext.DefaultKind}}
+	for _, c := range sink.calls {
+		assert.Equal(t, expectedInfo, c.info)
+	}
+	assert.Equal(t, "b data for feature", sink.calls[2].data)
+	assert.Equal(t, result, sink.calls[2].result)
+	assert.Equal(t, "a data for feature", sink.calls[3].data)
+	assert.Equal(t, result, sink.calls[3].result)
+	assert.GreaterOrEqual(t, sink.calls[3].duration, sink.calls[2].duration)
+}
+
+func TestHooksAreCalledForPrerequisites(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).OffVariation(1).
+		AddPrerequisite("feature1", 1).AddPrerequisite("feature2", 1).
+		FallthroughVariation(0).Variations(fallthroughValue, offValue, onValue).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite("feature2", 1).
+		FallthroughVariation(1).Variations(fallthroughValue, onValue).Build()
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).
+		FallthroughVariation(1).Variations(fallthroughValue, onValue).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredFlags(f1, f2),
+		EvaluatorOptionHooks(recordingHook{"a", sink}))
+	context := ldcontext.NewMulti(ldcontext.New("user-key"), ldcontext.NewWithKind("org", "org-key"))
+
+	eventSink := prereqEventSink{}
+	_ = evaluator.Evaluate(&f0, context, eventSink.record)
+
+	assert.Equal(t, []string{
+		"a before feature0",
+		"a before feature1",
+		"a before feature2",
+		"a after feature2",
+		"a after feature1",
+		"a before feature2",
+		"a after feature2",
+		"a after feature0",
+	}, sink.summary())
+	require.Len(t, sink.calls, 8)
+	assert.Equal(t, "", sink.calls[0].info.PrerequisiteOf)
+	assert.Equal(t, "feature0", sink.calls[1].info.PrerequisiteOf)
+	assert.Equal(t, "feature1", sink.calls[2].info.PrerequisiteOf)
+	assert.Equal(t, "feature0", sink.calls[5].info.PrerequisiteOf)
+	assert.Equal(t, []ldcontext.Kind{"org", ldcontext.DefaultKind}, sink.calls[2].info.ContextKinds)
+
+	// The prerequisite results are the same as the ones that were reported to the event recorder.
+	require.Len(t, eventSink.events, 3)
+	assert.Equal(t, eventSink.events[0].PrerequisiteResult, sink.calls[3].result)
+	assert.Equal(t, eventSink.events[1].PrerequisiteResult, sink.calls[4].result)
+	assert.Equal(t, eventSink.events[2].PrerequisiteResult, sink.calls[6].result)
+}
+
+func TestHooksAreCalledForFailedPrerequisite(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite("feature1", 0).
+		Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite("feature0", 0).
+		Variations(ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredFlags(f0, f1),
+		EvaluatorOptionHooks(recordingHook{"a", sink}))
+
+	result := evaluator.Evaluate(&f0, flagTestContext, nil)
+
+	assert.Equal(t, []string{"a before feature0", "a before feature1", "a before feature0", "a after feature0",
+		"a after feature1", "a after feature0"}, sink.summary())
+	require.Len(t, sink.calls, 6)
+	for _, i := range []int{3, 4, 5} {
+		assert.Equal(t, ldreason.NewEvalReasonError(ldreason.EvalErrorMalformedFlag), sink.calls[i].result.Detail.Reason)
+		assert.Equal(t, result.Err, sink.calls[i].result.Err)
+	}
+}
+
+func TestHooksAreCalledForInvalidContext(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).Variations(ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionHooks(recordingHook{"a", sink}))
+
+	result := evaluator.Evaluate(&flag, ldcontext.New(""), nil)
+
+	assert.Equal(t, []string{"a before feature", "a after feature"}, sink.summary())
+	require.Len(t, sink.calls, 2)
+	assert.Nil(t, sink.calls[0].info.ContextKinds)
+	assert.Equal(t, result, sink.calls[1].result)
+}
+
+func TestHooksAreCalledForInvalidContextInEvaluateAllAndPreparedFlag(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).Variations(ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(newEnumerableDataProvider(basicDataProvider(), flag),
+		EvaluatorOptionHooks(recordingHook{"a", sink}))
+	context := ldcontext.New("")
+
+	results, err := evaluator.(BatchEvaluator).EvaluateAll(context, nil)
+	require.NoError(t, err)
+	result := evaluator.(PreparingEvaluator).Prepare(&flag).Evaluate(context, nil)
+
+	assert.Equal(t, []string{"a before feature", "a after feature", "a before feature", "a after feature"},
+		sink.summary())
+	require.Len(t, sink.calls, 4)
+	for _, call := range sink.calls {
+		assert.Nil(t, call.info.ContextKinds)
+	}
+	assert.Equal(t, results["feature"], sink.calls[1].result)
+	assert.Equal(t, result, sink.calls[3].result)
+}
+
+func TestHooksAreCalledForEvaluateAllAndPreparedFlag(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).Variations(ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(newEnumerableDataProvider(basicDataProvider(), flag),
+		EvaluatorOptionHooks(recordingHook{"a", sink}))
+
+	_ = evaluateAll(t, evaluator, flagTestContext, nil)
+	assert.Equal(t, []string{"a before feature", "a after feature"}, sink.summary())
+
+	sink.calls = nil
+	_ = evaluator.(PreparingEvaluator).Prepare(&flag).Evaluate(flagTestContext, nil)
+	assert.Equal(t, []string{"a before feature", "a after feature"}, sink.summary())
+}
+
+func TestHooksAreNotCalledForExplain(t *testing.T) {
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).Variations(ldvalue.Bool(true)).Build()
+	sink := &hookCallSink{}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionHooks(recordingHook{"a", sink}))
+
+	_, _ = evaluator.(ExplainingEvaluator).Explain(&flag, flagTestContext, nil)
+	_, _ = evaluator.(ExplainingEvaluator).Explain(&flag, ldcontext.New(""), nil)
+	assert.Len(t, sink.calls, 0)
+}
@@ -1,6 +1,12 @@
 package evaluation
 
-import "github.com/launchdarkly/go-sdk-common/v3/ldlog"
+import (
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
 
 // EvaluatorOption is an optional parameter for NewEvaluator.
 type EvaluatorOption interface {
@@ -43,10 +49,227 @@ type evaluatorOptionErrorLogger struct{ errorLogger ldlog.BaseLogger }
 // error reporting. The Evaluator will only log errors for conditions that should not be
 // possible and require investigation, such as a malformed flag or a code path that should
 // not have been reached. If the parameter is nil, no logging is done.
+//
+// Every evaluation that fails is logged, so a frequently evaluated flag with an error can
+// produce a very large amount of output. To avoid this, use EvaluatorOptionErrorReporter with
+// NewRateLimitedErrorReporter instead. This option and EvaluatorOptionErrorReporter replace
+// each other; if both are specified, the last one wins.
 func EvaluatorOptionErrorLogger(errorLogger ldlog.BaseLogger) EvaluatorOption {
 	return evaluatorOptionErrorLogger{errorLogger: errorLogger}
 }
 
 func (o evaluatorOptionErrorLogger) apply(e *evaluator) {
-	e.errorLogger = o.errorLogger
+	if o.errorLogger == nil {
+		e.errorReporter = nil
+	} else {
+		e.errorReporter = loggingErrorReporter{logger: o.errorLogger}
+	}
+}
+
+type evaluatorOptionHooks struct{ hooks []EvaluationHook }
+
+// EvaluatorOptionHooks is an option for NewEvaluator that adds hooks to be called before and after
+// each flag evaluation; see EvaluationHook. The BeforeEvaluation methods are called in the order that
+// the hooks were specified, and the AfterEvaluation methods in the reverse order. If this option is
+// used more than once, the hooks from each are added to the list. Nil hooks are ignored.
+func EvaluatorOptionHooks(hooks ...EvaluationHook) EvaluatorOption {
+	return evaluatorOptionHooks{hooks: hooks}
+}
+
+func (o evaluatorOptionHooks) apply(e *evaluator) {
+	for _, h := range o.hooks {
+		if h != nil {
+			e.hooks = append(e.hooks, h)
+		}
+	}
+}
+
+type evaluatorOptionStepBudget struct{ maxSteps int }
+
+// EvaluatorOptionStepBudget is an option for NewEvaluator that limits how much work the evaluator
+// can do for a single evaluation, so that a pathological flag (for instance, one with a very large
+// number of rules or deeply nested segments) cannot make evaluations unexpectedly slow. See
+// EvaluationStats.Steps for what counts as a step. If the parameter is zero or negative, there is no
+// limit; this is the default. Setting a budget also enables Result.Stats, as EvaluatorOptionStats does.
+//
+// If an evaluation exceeds the budget, it stops immediately, and the result is an error with the kind
+// EvalErrorStepBudgetExceeded. Result.Err is an EvaluationError wrapping a StepBudgetExceededError,
+// and the error is also passed to the ErrorReporter, if any. EvaluatorOptionDegradationPolicy does not
+// apply to this error. For SegmentIndexEvaluator.SegmentsForContext, the budget applies to each segment separately.
+func EvaluatorOptionStepBudget(maxSteps int) EvaluatorOption {
+	return evaluatorOptionStepBudget{maxSteps: maxSteps}
+}
+
+func (o evaluatorOptionStepBudget) apply(e *evaluator) {
+	e.stepBudget = o.maxSteps
+}
+
+type evaluatorOptionStats struct{ enable bool }
+
+// EvaluatorOptionStats is an option for NewEvaluator that specifies whether the evaluator should
+// count the work it does for each evaluation and report it in Result.Stats. By default, this is not
+// enabled, and Result.Stats is always zero, unless EvaluatorOptionStepBudget was used: the evaluator
+// has to count steps in order to enforce the budget, so in that case Result.Stats is always set.
+func EvaluatorOptionStats(enable bool) EvaluatorOption {
+	return evaluatorOptionStats{enable: enable}
+}
+
+func (o evaluatorOptionStats) apply(e *evaluator) {
+	e.countStats = o.enable
+}
+
+type evaluatorOptionClock struct{ clock func() time.Time }
+
+// EvaluatorOptionClock is an option for NewEvaluator that specifies a function for getting the
+// current time. If the parameter is nil, time.Now is used; this is the default.
+//
+// The time is used by operators such as ldmodel.OperatorBeforeRelative that compare timestamps to the
+// current time, and to compute Result.DebugEventsActive. The evaluator calls the function at most
+// once per evaluation, only if the result depends on the time, and reports the time that it used in
+// Result.EvaluationTime. This is mainly useful in testing, for evaluating flags as of some other time,
+// or for replaying a recorded evaluation.
+func EvaluatorOptionClock(clock func() time.Time) EvaluatorOption {
+	return evaluatorOptionClock{clock: clock}
+}
+
+func (o evaluatorOptionClock) apply(e *evaluator) {
+	e.clock = o.clock
+}
+
+type evaluatorOptionErrorReporter struct{ errorReporter ErrorReporter }
+
+// EvaluatorOptionErrorReporter is an option for NewEvaluator that specifies an ErrorReporter to be
+// notified of every error that causes an evaluation to fail. If the parameter is nil, errors are not
+// reported.
+//
+// This option and EvaluatorOptionErrorLogger replace each other; if both are specified, the last
+// one wins.
+func EvaluatorOptionErrorReporter(errorReporter ErrorReporter) EvaluatorOption {
+	return evaluatorOptionErrorReporter{errorReporter: errorReporter}
+}
+
+func (o evaluatorOptionErrorReporter) apply(e *evaluator) {
+	e.errorReporter = o.errorReporter
+}
+
+// CustomOperatorFunc is the signature of a custom clause operator. See EvaluatorOptionCustomOperator.
+//
+// The function is called with an attribute value from the evaluation context and one of the clause's
+// values, and returns true if they match. If the context attribute is an array, the function is called
+// separately for each element of the array. If the flag or segment was preprocessed with
+// ldmodel.PreprocessFlagWithCustomOperators or ldmodel.PreprocessSegmentWithCustomOperators, with a
+// preprocessor for this operator, the preprocessed parameter is the value it returned for this clause
+// value; otherwise it is nil, and the function must use clauseValue instead.
+//
+// The function is called synchronously during evaluations, so it should be fast, and it must be safe
+// to call concurrently from multiple goroutines.
+type CustomOperatorFunc func(contextValue, clauseValue ldvalue.Value, preprocessed interface{}) bool
+
+type evaluatorOptionCustomOperator struct {
+	op ldmodel.Operator
+	fn CustomOperatorFunc
+}
+
+// EvaluatorOptionCustomOperator is an option for NewEvaluator that defines the behavior of a clause
+// operator that is not one of the operators built into the evaluator. Normally, a clause with an
+// unrecognized operator never matches; with this option, the evaluator will call the specified function
+// instead.
+//
+// The built-in operators, such as ldmodel.OperatorIn, cannot be overridden; an option that specifies one
+// of them is ignored. If the same operator is specified more than once, the last one wins, and if fn is
+// nil, the operator is treated as unrecognized.
+//
+// To parse clause values only once rather than on every evaluation, preprocess the flag data with
+// ldmodel.PreprocessFlagWithCustomOperators and ldmodel.PreprocessSegmentWithCustomOperators, with a
+// preprocessor for the same operator.
+func EvaluatorOptionCustomOperator(op ldmodel.Operator, fn CustomOperatorFunc) EvaluatorOption {
+	return evaluatorOptionCustomOperator{op: op, fn: fn}
+}
+
+func (o evaluatorOptionCustomOperator) apply(e *evaluator) {
+	if ldmodel.IsBuiltInOperator(o.op) {
+		return
+	}
+	if o.fn == nil {
+		delete(e.customOperators, o.op)
+		return
+	}
+	if e.customOperators == nil {
+		e.customOperators = make(map[ldmodel.Operator]CustomOperatorFunc)
+	}
+	e.customOperators[o.op] = o.fn
+}
+
+// DegradationPolicy defines what the evaluator does when it cannot evaluate a flag rule because of an
+// error in the rule's configuration. See EvaluatorOptionDegradationPolicy.
+type DegradationPolicy string
+
+const (
+	// DegradationFail means that the evaluation fails with an error result, so the application gets
+	// its default value. This is the default behavior.
+	DegradationFail DegradationPolicy = "FAIL"
+	// DegradationSkipRule means that the rule is treated as if it did not match, and the evaluation
+	// continues with the next rule or the fallthrough.
+	DegradationSkipRule DegradationPolicy = "SKIP_RULE"
+	// DegradationServeOff means that the evaluation returns the flag's off variation, with an error
+	// reason describing the rule's error. Unlike a failed evaluation, the result still has the off
+	// variation's value and index.
+	DegradationServeOff DegradationPolicy = "SERVE_OFF"
+)
+
+// RuleErrorClass is a category of errors in flag rules that can have its own DegradationPolicy. See
+// EvaluatorOptionDegradationPolicy.
+type RuleErrorClass string
+
+const (
+	// RuleErrorInvalidAttribute is the class of errors where a clause does not specify a valid
+	// attribute reference: EmptyAttrRefError and BadAttrRefError.
+	RuleErrorInvalidAttribute RuleErrorClass = "INVALID_ATTRIBUTE"
+	// RuleErrorMalformedSegment is the class of errors where a clause references a segment that could
+	// not be evaluated: MalformedSegmentError. This includes circular references between segments.
+	RuleErrorMalformedSegment RuleErrorClass = "MALFORMED_SEGMENT"
+)
+
+type evaluatorOptionDegradationPolicy struct {
+	errorClass RuleErrorClass
+	policy     DegradationPolicy
+}
+
+// EvaluatorOptionDegradationPolicy is an option for NewEvaluator that specifies what the evaluator
+// should do when a flag rule has an error of the specified class. By default, for every class, the
+// evaluation fails with an ldreason.EvalErrorMalformedFlag error (DegradationFail). Errors that are
+// not in a rule, such as an invalid variation index, always cause the evaluation to fail.
+//
+// If a policy other than DegradationFail is applied, the evaluation does not fail, but Result.Degradation
+// is set to the policy and Result.Err describes the error, so that the degradation can be observed. The
+// error is also passed to the ErrorReporter, if any, as usual.
+func EvaluatorOptionDegradationPolicy(errorClass RuleErrorClass, policy DegradationPolicy) EvaluatorOption {
+	return evaluatorOptionDegradationPolicy{errorClass: errorClass, policy: policy}
+}
+
+func (o evaluatorOptionDegradationPolicy) apply(e *evaluator) {
+	if e.degradationPolicies == nil {
+		e.degradationPolicies = make(map[RuleErrorClass]DegradationPolicy)
+	}
+	e.degradationPolicies[o.errorClass] = o.policy
+}
+
+// degradationPolicyForRuleError returns the policy for an error that was returned by ruleMatchesContext.
+func (e *evaluator) degradationPolicyForRuleError(err error) DegradationPolicy {
+	if e.degradationPolicies == nil {
+		return DegradationFail
+	}
+	var errorClass RuleErrorClass
+	switch err.(type) {
+	case EmptyAttrRefError, BadAttrRefError:
+		errorClass = RuleErrorInvalidAttribute
+	case MalformedSegmentError:
+		errorClass = RuleErrorMalformedSegment
+	default:
+		return DegradationFail // COVERAGE: no other errors can happen in a rule
+	}
+	if policy, ok := e.degradationPolicies[errorClass]; ok {
+		return policy
+	}
+	return DegradationFail
 }
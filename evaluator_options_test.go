@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -13,12 +16,12 @@ func TestEvaluatorDefaultOptions(t *testing.T) {
 	e1 := NewEvaluator(d).(*evaluator)
 	assert.Equal(t, d, e1.dataProvider)
 	assert.Nil(t, e1.bigSegmentProvider)
-	assert.Nil(t, e1.errorLogger)
+	assert.Nil(t, e1.errorReporter)
 
 	e2 := NewEvaluatorWithOptions(d).(*evaluator)
 	assert.Equal(t, d, e2.dataProvider)
 	assert.Nil(t, e2.bigSegmentProvider)
-	assert.Nil(t, e2.errorLogger)
+	assert.Nil(t, e2.errorReporter)
 }
 
 func TestEvaluatorOptionBigSegmentProvider(t *testing.T) {
@@ -27,7 +30,7 @@ func TestEvaluatorOptionBigSegmentProvider(t *testing.T) {
 	e := NewEvaluatorWithOptions(d, EvaluatorOptionBigSegmentProvider(b)).(*evaluator)
 	assert.Equal(t, d, e.dataProvider)
 	assert.Equal(t, b, e.bigSegmentProvider)
-	assert.Nil(t, e.errorLogger)
+	assert.Nil(t, e.errorReporter)
 }
 
 func TestEvaluatorOptionErrorLogger(t *testing.T) {
@@ -36,5 +39,39 @@ func TestEvaluatorOptionErrorLogger(t *testing.T) {
 	e := NewEvaluatorWithOptions(d, EvaluatorOptionErrorLogger(logger)).(*evaluator)
 	assert.Equal(t, d, e.dataProvider)
 	assert.Nil(t, e.bigSegmentProvider)
-	assert.Equal(t, logger, e.errorLogger)
+	assert.Equal(t, loggingErrorReporter{logger: logger}, e.errorReporter)
+
+	e = NewEvaluatorWithOptions(d, EvaluatorOptionErrorLogger(logger), EvaluatorOptionErrorLogger(nil)).(*evaluator)
+	assert.Nil(t, e.errorReporter)
+}
+
+func TestEvaluatorOptionErrorReporter(t *testing.T) {
+	logger := ldlog.NewDefaultLoggers().ForLevel(ldlog.Error)
+	reporter := NewRateLimitedErrorReporter(nil, 0)
+
+	e := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionErrorReporter(reporter)).(*evaluator)
+	assert.Equal(t, reporter, e.errorReporter)
+
+	e = NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionErrorLogger(logger),
+		EvaluatorOptionErrorReporter(reporter)).(*evaluator)
+	assert.Equal(t, reporter, e.errorReporter)
+
+	e = NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionErrorReporter(reporter),
+		EvaluatorOptionErrorLogger(logger)).(*evaluator)
+	assert.Equal(t, loggingErrorReporter{logger: logger}, e.errorReporter)
+}
+
+func TestEvaluatorOptionCustomOperator(t *testing.T) {
+	op1, op2 := ldmodel.Operator("op1"), ldmodel.Operator("op2")
+	fn := func(ldvalue.Value, ldvalue.Value, interface{}) bool { return true }
+
+	e := NewEvaluatorWithOptions(basicDataProvider(),
+		EvaluatorOptionCustomOperator(op1, fn),
+		EvaluatorOptionCustomOperator(op2, fn),
+		EvaluatorOptionCustomOperator(ldmodel.OperatorIn, fn),
+		EvaluatorOptionCustomOperator(op2, nil),
+	).(*evaluator)
+
+	assert.Len(t, e.customOperators, 1)
+	assert.Contains(t, e.customOperators, op1)
 }
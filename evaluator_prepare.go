@@ -0,0 +1,201 @@
+package evaluation
+
+import (
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// The types in this file implement PreparingEvaluator.Prepare. A prepared flag is evaluated by the
+// same code as any other flag; the difference is that evaluationScope.plan is set, and wherever the
+// evaluator would otherwise query the DataProvider, or test a context value against a clause's values,
+// it uses the corresponding information from the plan instead. The plan mirrors the structure of the
+// flag: for instance, flagPlan.rules[i][j] describes es.flag.Rules[i].Clauses[j].
+
+type flagPlan struct {
+	flag    *ldmodel.FeatureFlag
+	rules   [][]clausePlan
+	prereqs []*flagPlan // same indices as flag.Prerequisites; nil if the prerequisite flag was not found
+}
+
+type segmentPlan struct {
+	segment *ldmodel.Segment
+	rules   [][]clausePlan
+}
+
+type clausePlan struct {
+	// match is used by evaluationScope.matchAny instead of its usual logic. It is nil for operators that
+	// never use matchAny: segmentMatch, and the operators that test an array as a whole. It is also nil
+	// for the relative date operators, which use relativeDates instead.
+	match         clauseMatchFunc
+	relativeDates *relativeDateMatcher
+	// segments is only set for a segmentMatch clause. It has the same indices as the clause's values;
+	// an element is nil if there was no such segment.
+	segments []*segmentPlan
+}
+
+// clauseMatchFunc tests a context value against all of a clause's values. It is created by
+// compileClause. It returns whether there was a match, and how many clause values it tested; both
+// must be exactly the same as if evaluationScope.matchAny had done the test without a plan.
+type clauseMatchFunc func(value ldvalue.Value) (bool, int)
+
+// relativeDateMatcher is the equivalent of clauseMatchFunc for the beforeRelative and afterRelative
+// operators; see compileRelativeDateClause.
+type relativeDateMatcher struct {
+	durations []time.Duration
+	valid     []bool
+	fn        func(time.Time, time.Time) bool
+}
+
+type preparedFlag struct {
+	owner *evaluator
+	plan  *flagPlan
+	// These map each flag or segment key that was queried to the version we got, or to -1 if it was
+	// not found.
+	flagVersions    map[string]int
+	segmentVersions map[string]int
+}
+
+// planBuilder resolves all of the flags and segments that are referenced by a flag. Each flag or
+// segment is only planned once, no matter how many times it is referenced; this also means that a
+// circular reference produces a circular plan rather than infinite recursion. Such a plan will still
+// be detected as an error at evaluation time in the same way as it would be without the plan.
+type planBuilder struct {
+	owner           *evaluator
+	flagPlans       map[string]*flagPlan
+	segmentPlans    map[string]*segmentPlan
+	flagVersions    map[string]int
+	segmentVersions map[string]int
+}
+
+// Implementation of the PreparingEvaluator interface.
+func (e *evaluator) Prepare(flag *ldmodel.FeatureFlag) PreparedFlag {
+	b := planBuilder{
+		owner:           e,
+		flagPlans:       make(map[string]*flagPlan),
+		segmentPlans:    make(map[string]*segmentPlan),
+		flagVersions:    make(map[string]int),
+		segmentVersions: make(map[string]int),
+	}
+	plan := b.planFlag(flag)
+	return &preparedFlag{
+		owner:           e,
+		plan:            plan,
+		flagVersions:    b.flagVersions,
+		segmentVersions: b.segmentVersions,
+	}
+}
+
+func (p *preparedFlag) Flag() *ldmodel.FeatureFlag {
+	return p.plan.flag
+}
+
+func (p *preparedFlag) Evaluate(
+	context ldcontext.Context,
+	prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder,
+) Result {
+	if context.Err() != nil {
+		return p.owner.evaluateForInvalidContext(p.plan.flag)
+	}
+	return p.owner.evaluateFlag(p.plan.flag, context, prerequisiteFlagEventRecorder, nil, nil, p.plan)
+}
+
+func (p *preparedFlag) IsStale() bool {
+	for key, version := range p.flagVersions {
+		current := -1
+		if f := p.owner.dataProvider.GetFeatureFlag(key); f != nil {
+			current = f.Version
+		}
+		if current != version {
+			return true
+		}
+	}
+	for key, version := range p.segmentVersions {
+		current := -1
+		if s := p.owner.dataProvider.GetSegment(key); s != nil {
+			current = s.Version
+		}
+		if current != version {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *planBuilder) planFlag(flag *ldmodel.FeatureFlag) *flagPlan {
+	if p, ok := b.flagPlans[flag.Key]; ok {
+		return p
+	}
+	p := &flagPlan{flag: flag}
+	b.flagPlans[flag.Key] = p
+	b.flagVersions[flag.Key] = flag.Version
+	p.rules = make([][]clausePlan, len(flag.Rules))
+	for i := range flag.Rules {
+		p.rules[i] = b.planClauses(flag.Rules[i].Clauses)
+	}
+	p.prereqs = make([]*flagPlan, len(flag.Prerequisites))
+	for i, prereq := range flag.Prerequisites {
+		if prereqFlag := b.owner.dataProvider.GetFeatureFlag(prereq.Key); prereqFlag != nil {
+			p.prereqs[i] = b.planFlag(prereqFlag)
+		} else {
+			b.flagVersions[prereq.Key] = -1
+		}
+	}
+	return p
+}
+
+func (b *planBuilder) planSegment(segment *ldmodel.Segment) *segmentPlan {
+	if p, ok := b.segmentPlans[segment.Key]; ok {
+		return p
+	}
+	p := &segmentPlan{segment: segment}
+	b.segmentPlans[segment.Key] = p
+	b.segmentVersions[segment.Key] = segment.Version
+	p.rules = make([][]clausePlan, len(segment.Rules))
+	for i := range segment.Rules {
+		p.rules[i] = b.planClauses(segment.Rules[i].Clauses)
+	}
+	return p
+}
+
+func (b *planBuilder) planClauses(clauses []ldmodel.Clause) []clausePlan {
+	ret := make([]clausePlan, len(clauses))
+	for i := range clauses {
+		c := &clauses[i]
+		if c.Op != ldmodel.OperatorSegmentMatch {
+			ret[i].match = b.owner.compileClause(c)
+			ret[i].relativeDates = compileRelativeDateClause(c)
+			continue
+		}
+		ret[i].segments = make([]*segmentPlan, len(c.Values))
+		for j, value := range c.Values {
+			if value.Type() != ldvalue.StringType {
+				continue
+			}
+			if segment := b.owner.dataProvider.GetSegment(value.StringValue()); segment != nil {
+				ret[i].segments[j] = b.planSegment(segment)
+			} else {
+				b.segmentVersions[value.StringValue()] = -1
+			}
+		}
+	}
+	return ret
+}
+
+// ruleClauses returns the clause plans for a flag rule, or nil if this flag was not prepared.
+func (p *flagPlan) ruleClauses(ruleIndex int) []clausePlan {
+	if p == nil {
+		return nil
+	}
+	return p.rules[ruleIndex]
+}
+
+// ruleClauses returns the clause plans for a segment rule, or nil if this segment was not prepared.
+func (p *segmentPlan) ruleClauses(ruleIndex int) []clausePlan {
+	if p == nil {
+		return nil
+	}
+	return p.rules[ruleIndex]
+}
@@ -0,0 +1,244 @@
+package evaluation
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly/go-semver"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// compileClause creates the clauseMatchFunc for a clause in a prepared flag. Rather than deciding what
+// to do based on the operator every time a clause value is tested, as matchAny and doOp do, this is
+// done once here. Also, any clause values that need parsing are copied out of the clause's
+// preprocessed data ahead of time, and the context value is only converted once per test rather than
+// once per clause value.
+//
+// The matcher does not touch the evaluationScope, since that would force the scope to be allocated
+// on the heap for every evaluation; instead, it returns how many clause values it tested, which
+// matchAny adds to Result.Stats just as if it had tested them itself. The relative date operators
+// need the evaluation time, which is only computed on demand, so they are compiled by
+// compileRelativeDateClause instead.
+func (e *evaluator) compileClause(c *ldmodel.Clause) clauseMatchFunc {
+	switch c.Op {
+	case ldmodel.OperatorIn, ldmodel.OperatorInIgnoreCase:
+		return func(value ldvalue.Value) (bool, int) {
+			return ldmodel.EvaluatorAccessors.ClauseFindValue(c, value), 0
+		}
+	case ldmodel.OperatorIPInRange, ldmodel.OperatorIPEquals:
+		return func(value ldvalue.Value) (bool, int) {
+			return ldmodel.EvaluatorAccessors.ClauseMatchesIPAddress(c, value), 0
+		}
+	case ldmodel.OperatorEndsWith:
+		return compileStringOperator(c, strings.HasSuffix)
+	case ldmodel.OperatorStartsWith:
+		return compileStringOperator(c, strings.HasPrefix)
+	case ldmodel.OperatorContains:
+		return compileStringOperator(c, strings.Contains)
+	case ldmodel.OperatorEndsWithIgnoreCase:
+		return compileNormalizedStringOperator(c, strings.HasSuffix)
+	case ldmodel.OperatorStartsWithIgnoreCase:
+		return compileNormalizedStringOperator(c, strings.HasPrefix)
+	case ldmodel.OperatorContainsIgnoreCase:
+		return compileNormalizedStringOperator(c, strings.Contains)
+	case ldmodel.OperatorMatches:
+		return compileMatchesOperator(c)
+	case ldmodel.OperatorLessThan:
+		return compileNumericOperator(c, func(a float64, b float64) bool { return a < b })
+	case ldmodel.OperatorLessThanOrEqual:
+		return compileNumericOperator(c, func(a float64, b float64) bool { return a <= b })
+	case ldmodel.OperatorGreaterThan:
+		return compileNumericOperator(c, func(a float64, b float64) bool { return a > b })
+	case ldmodel.OperatorGreaterThanOrEqual:
+		return compileNumericOperator(c, func(a float64, b float64) bool { return a >= b })
+	case ldmodel.OperatorBefore:
+		return compileDateOperator(c, time.Time.Before)
+	case ldmodel.OperatorAfter:
+		return compileDateOperator(c, time.Time.After)
+	case ldmodel.OperatorBeforeRelative, ldmodel.OperatorAfterRelative:
+		return nil // see compileRelativeDateClause
+	case ldmodel.OperatorSemVerEqual:
+		return compileSemVerOperator(c, 0)
+	case ldmodel.OperatorSemVerLessThan:
+		return compileSemVerOperator(c, -1)
+	case ldmodel.OperatorSemVerGreaterThan:
+		return compileSemVerOperator(c, 1)
+	}
+	if testsWholeArray(c.Op) {
+		return nil // these never use matchAny
+	}
+	if fn := e.customOperators[c.Op]; fn != nil {
+		return compileCustomOperator(c, fn)
+	}
+	numValues := len(c.Values)
+	return func(value ldvalue.Value) (bool, int) {
+		return false, numValues // an unknown operator never matches
+	}
+}
+
+func compileStringOperator(c *ldmodel.Clause, fn func(string, string) bool) clauseMatchFunc {
+	clValues := make([]string, len(c.Values))
+	valid := make([]bool, len(c.Values))
+	for i, v := range c.Values {
+		clValues[i], valid[i] = v.StringValue(), v.IsString()
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		if value.IsString() {
+			ctxValue := value.StringValue()
+			for i, clValue := range clValues {
+				if valid[i] && fn(ctxValue, clValue) {
+					return true, i + 1
+				}
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+func compileNormalizedStringOperator(c *ldmodel.Clause, fn func(string, string) bool) clauseMatchFunc {
+	clValues := make([]string, len(c.Values))
+	valid := make([]bool, len(c.Values))
+	for i := range c.Values {
+		clValues[i], valid[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsNormalizedString(c, i)
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		ctxValue, ok := ldmodel.TypeConversions.ValueToNormalizedString(value)
+		if !ok {
+			return false, 0 // matchAny does not count any steps in this case
+		}
+		for i, clValue := range clValues {
+			if valid[i] && fn(ctxValue, clValue) {
+				return true, i + 1
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+func compileMatchesOperator(c *ldmodel.Clause) clauseMatchFunc {
+	clValues := make([]*regexp.Regexp, len(c.Values))
+	for i := range c.Values {
+		clValues[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsRegexp(c, i)
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		if value.IsString() {
+			ctxValue := value.StringValue()
+			for i, r := range clValues {
+				if r != nil && r.MatchString(ctxValue) {
+					return true, i + 1
+				}
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+func compileNumericOperator(c *ldmodel.Clause, fn func(float64, float64) bool) clauseMatchFunc {
+	clValues := make([]float64, len(c.Values))
+	valid := make([]bool, len(c.Values))
+	for i, v := range c.Values {
+		clValues[i], valid[i] = v.Float64Value(), v.IsNumber()
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		if value.IsNumber() {
+			ctxValue := value.Float64Value()
+			for i, clValue := range clValues {
+				if valid[i] && fn(ctxValue, clValue) {
+					return true, i + 1
+				}
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+func compileDateOperator(c *ldmodel.Clause, fn func(time.Time, time.Time) bool) clauseMatchFunc {
+	clValues := make([]time.Time, len(c.Values))
+	valid := make([]bool, len(c.Values))
+	for i := range c.Values {
+		clValues[i], valid[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsTimestamp(c, i)
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		if ctxValue, ok := ldmodel.TypeConversions.ValueToTimestamp(value); ok {
+			for i, clValue := range clValues {
+				if valid[i] && fn(ctxValue, clValue) {
+					return true, i + 1
+				}
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+// compileRelativeDateClause creates the relativeDateMatcher for a beforeRelative or afterRelative
+// clause, or returns nil for any other operator.
+func compileRelativeDateClause(c *ldmodel.Clause) *relativeDateMatcher {
+	var fn func(time.Time, time.Time) bool
+	switch c.Op {
+	case ldmodel.OperatorBeforeRelative:
+		fn = time.Time.Before
+	case ldmodel.OperatorAfterRelative:
+		fn = time.Time.After
+	default:
+		return nil
+	}
+	m := &relativeDateMatcher{
+		durations: make([]time.Duration, len(c.Values)),
+		valid:     make([]bool, len(c.Values)),
+		fn:        fn,
+	}
+	for i := range c.Values {
+		m.durations[i], m.valid[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsDuration(c, i)
+	}
+	return m
+}
+
+// matchRelativeDates returns the same results as a clauseMatchFunc for a relative date clause.
+func (es *evaluationScope) matchRelativeDates(m *relativeDateMatcher, value ldvalue.Value) (bool, int) {
+	if ctxValue, ok := ldmodel.TypeConversions.ValueToTimestamp(value); ok {
+		for i, d := range m.durations {
+			// As in relativeDateOperator, we only get the time if there is a valid value to compare
+			// it to, so that Result.EvaluationTime is only set if the result depended on it.
+			if m.valid[i] && m.fn(ctxValue, es.now().Add(d)) {
+				return true, i + 1
+			}
+		}
+	}
+	return false, len(m.durations)
+}
+
+func compileSemVerOperator(c *ldmodel.Clause, expectedComparisonResult int) clauseMatchFunc {
+	clValues := make([]semver.Version, len(c.Values))
+	valid := make([]bool, len(c.Values))
+	for i := range c.Values {
+		clValues[i], valid[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsSemanticVersion(c, i)
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		if ctxValue, ok := ldmodel.TypeConversions.ValueToSemanticVersion(value); ok {
+			for i, clValue := range clValues {
+				if valid[i] && ctxValue.ComparePrecedence(clValue) == expectedComparisonResult {
+					return true, i + 1
+				}
+			}
+		}
+		return false, len(clValues)
+	}
+}
+
+func compileCustomOperator(c *ldmodel.Clause, fn CustomOperatorFunc) clauseMatchFunc {
+	preprocessed := make([]interface{}, len(c.Values))
+	for i := range c.Values {
+		preprocessed[i] = ldmodel.EvaluatorAccessors.ClauseGetValueAsCustom(c, i)
+	}
+	return func(value ldvalue.Value) (bool, int) {
+		for i, clValue := range c.Values {
+			if fn(value, clValue, preprocessed[i]) {
+				return true, i + 1
+			}
+		}
+		return false, len(c.Values)
+	}
+}
@@ -0,0 +1,249 @@
+package evaluation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type preparedFlagTestParams struct {
+	name     string
+	flag     ldmodel.FeatureFlag
+	flags    []ldmodel.FeatureFlag
+	segments []ldmodel.Segment
+}
+
+func makePreparedFlagTestParams() []preparedFlagTestParams {
+	boolVariations := []ldvalue.Value{ldvalue.Bool(false), ldvalue.Bool(true)}
+	segmentWithRule := ldbuilders.NewSegmentBuilder("segment-with-rule").Version(2).
+		AddRule(ldbuilders.NewSegmentRuleBuilder().
+			Clauses(ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@example.com")))).
+		Build()
+	segmentWithNestedSegment := ldbuilders.NewSegmentBuilder("segment-with-nested-segment").Version(3).
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause(segmentWithRule.Key))).
+		Build()
+	circularSegment0 := ldbuilders.NewSegmentBuilder("circular0").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("circular1"))).
+		Build()
+	circularSegment1 := ldbuilders.NewSegmentBuilder("circular1").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("circular0"))).
+		Build()
+	prereq0 := ldbuilders.NewFlagBuilder("prereq0").Version(4).On(true).FallthroughVariation(1).
+		Variations(boolVariations...).Build()
+	prereq1 := ldbuilders.NewFlagBuilder("prereq1").Version(5).On(true).AddPrerequisite(prereq0.Key, 1).
+		AddRule(ldbuilders.NewRuleBuilder().ID("r").Variation(1).
+			Clauses(ldbuilders.Clause("email", ldmodel.OperatorMatches, ldvalue.String("^a@")))).
+		FallthroughVariation(0).OffVariation(0).Variations(boolVariations...).Build()
+
+	return []preparedFlagTestParams{
+		{
+			name: "rules with various operators",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).
+				AddRule(ldbuilders.NewRuleBuilder().ID("r0").Variation(0).
+					Clauses(ldbuilders.Clause("email", ldmodel.OperatorStartsWith, ldvalue.String("b@")))).
+				AddRule(ldbuilders.NewRuleBuilder().ID("r1").Variation(0).
+					Clauses(ldbuilders.Clause("email", ldmodel.Operator("unknown"), ldvalue.String("a@example.com")))).
+				AddRule(ldbuilders.NewRuleBuilder().ID("r2").Variation(1).
+					Clauses(
+						ldbuilders.Clause("email", ldmodel.OperatorIn, ldvalue.String("x"), ldvalue.String("a@example.com")),
+						ldbuilders.Clause("kind", ldmodel.OperatorIn, ldvalue.String("user")),
+						ldbuilders.Negate(ldbuilders.Clause("version", ldmodel.OperatorSemVerLessThan, ldvalue.String("1.0.0"))),
+					)).
+				FallthroughVariation(0).Variations(boolVariations...).Build(),
+		},
+		{
+			name: "segments",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).
+				AddRule(ldbuilders.NewRuleBuilder().ID("r0").Variation(1).
+					Clauses(ldbuilders.SegmentMatchClause("missing-segment", segmentWithNestedSegment.Key))).
+				FallthroughVariation(0).Variations(boolVariations...).Build(),
+			segments: []ldmodel.Segment{segmentWithRule, segmentWithNestedSegment},
+		},
+		{
+			name: "circular segments",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).
+				AddRule(ldbuilders.NewRuleBuilder().ID("r0").Variation(1).
+					Clauses(ldbuilders.SegmentMatchClause(circularSegment0.Key))).
+				FallthroughVariation(0).Variations(boolVariations...).Build(),
+			segments: []ldmodel.Segment{circularSegment0, circularSegment1},
+		},
+		{
+			name: "prerequisites",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).
+				AddPrerequisite(prereq0.Key, 1).AddPrerequisite(prereq1.Key, 1).
+				FallthroughVariation(1).OffVariation(0).Variations(boolVariations...).Build(),
+			flags: []ldmodel.FeatureFlag{prereq0, prereq1},
+		},
+		{
+			name: "missing prerequisite",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).AddPrerequisite("missing-flag", 1).
+				FallthroughVariation(1).OffVariation(0).Variations(boolVariations...).Build(),
+		},
+		{
+			name: "circular prerequisites",
+			flag: ldbuilders.NewFlagBuilder("flag").On(true).AddPrerequisite("circular-prereq", 0).
+				FallthroughVariation(1).Variations(boolVariations...).Build(),
+			flags: []ldmodel.FeatureFlag{
+				ldbuilders.NewFlagBuilder("circular-prereq").On(true).AddPrerequisite("flag", 1).
+					FallthroughVariation(0).Variations(boolVariations...).Build(),
+			},
+		},
+	}
+}
+
+func (p preparedFlagTestParams) dataProvider() *simpleDataProvider {
+	return basicDataProvider().
+		withStoredFlags(append(p.flags, p.flag)...).
+		withStoredSegments(p.segments...).
+		withNonexistentFlag("missing-flag").
+		withNonexistentSegment("missing-segment")
+}
+
+func TestPreparedFlagReturnsSameResultsAsEvaluate(t *testing.T) {
+	contexts := []ldcontext.Context{
+		ldcontext.NewBuilder("a").SetString("email", "a@example.com").SetString("version", "2.0.0").Build(),
+		ldcontext.NewBuilder("b").SetString("email", "b@example.com").Build(),
+		ldcontext.NewBuilder("c").Kind("org").SetString("email", "a@example.com").Build(),
+		ldcontext.New(""),
+	}
+	for _, p := range makePreparedFlagTestParams() {
+		t.Run(p.name, func(t *testing.T) {
+			evaluator := NewEvaluatorWithOptions(p.dataProvider(), EvaluatorOptionStats(true))
+			prepared := evaluator.(PreparingEvaluator).Prepare(&p.flag)
+
+			for _, context := range contexts {
+				expectedEvents, actualEvents := prereqEventSink{}, prereqEventSink{}
+				expected := evaluator.Evaluate(&p.flag, context, expectedEvents.record)
+				actual := prepared.Evaluate(context, actualEvents.record)
+				assert.Equal(t, expected, actual, context.Key())
+				assert.Equal(t, expectedEvents.events, actualEvents.events, context.Key())
+			}
+		})
+	}
+}
+
+func TestPreparedFlagReturnsSameResultsAsEvaluateForEveryOperator(t *testing.T) {
+	// A prepared flag uses a compiled matcher for each clause, which must behave exactly the same as the
+	// unprepared logic, including how it counts steps in Result.Stats.
+	customOp := ldmodel.Operator("customOp")
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(),
+		EvaluatorOptionStats(true),
+		EvaluatorOptionClock(func() time.Time { return now }),
+		EvaluatorOptionCustomOperator(customOp, func(contextValue, clauseValue ldvalue.Value, _ interface{}) bool {
+			return contextValue.Equal(clauseValue)
+		}))
+	clauseValues := []ldvalue.Value{ldvalue.Bool(true), ldvalue.Int(2), ldvalue.String("1.0.0"), ldvalue.String("^a"),
+		ldvalue.String("2010-01-01T00:00:00Z"), ldvalue.String("-P1D"), ldvalue.String("10.0.0.0/8"),
+		ldvalue.String("ABC")}
+	contextValues := []ldvalue.Value{ldvalue.Bool(true), ldvalue.Int(1), ldvalue.Int(3), ldvalue.String("abc"),
+		ldvalue.String("xabc"), ldvalue.String("2.0.0"), ldvalue.String("0.0.1"), ldvalue.String("2000-01-01T00:00:00Z"),
+		ldvalue.String("2019-12-31T12:00:00Z"), ldvalue.Float64(float64(now.UnixMilli())), ldvalue.String("10.1.2.3"),
+		ldvalue.ArrayOf(ldvalue.String("x"), ldvalue.String("ABC"))}
+	for _, op := range []ldmodel.Operator{
+		ldmodel.OperatorIn, ldmodel.OperatorEndsWith, ldmodel.OperatorStartsWith, ldmodel.OperatorMatches,
+		ldmodel.OperatorContains, ldmodel.OperatorInIgnoreCase, ldmodel.OperatorEndsWithIgnoreCase,
+		ldmodel.OperatorStartsWithIgnoreCase, ldmodel.OperatorContainsIgnoreCase, ldmodel.OperatorLessThan,
+		ldmodel.OperatorLessThanOrEqual, ldmodel.OperatorGreaterThan, ldmodel.OperatorGreaterThanOrEqual,
+		ldmodel.OperatorBefore, ldmodel.OperatorAfter, ldmodel.OperatorBeforeRelative, ldmodel.OperatorAfterRelative,
+		ldmodel.OperatorSemVerEqual, ldmodel.OperatorSemVerLessThan, ldmodel.OperatorSemVerGreaterThan,
+		ldmodel.OperatorIPInRange, ldmodel.OperatorIPEquals, ldmodel.OperatorContainsAll, ldmodel.OperatorContainsNone,
+		ldmodel.OperatorSizeGreaterThan, ldmodel.OperatorSizeLessThan, customOp, ldmodel.Operator("unknown"),
+	} {
+		t.Run(string(op), func(t *testing.T) {
+			for _, attr := range []string{"attr", "kind"} {
+				f := makeBooleanFlagWithClauses(ldbuilders.Clause(attr, op, clauseValues...))
+				prepared := evaluator.(PreparingEvaluator).Prepare(&f)
+				for _, value := range contextValues {
+					context := ldcontext.NewBuilder("key").SetValue("attr", value).Build()
+					assert.Equal(t, evaluator.Evaluate(&f, context, nil), prepared.Evaluate(context, nil),
+						"%s %s", attr, value.JSONString())
+				}
+			}
+		})
+	}
+}
+
+func TestPreparedFlagDoesNotQueryDataProvider(t *testing.T) {
+	for _, p := range makePreparedFlagTestParams() {
+		t.Run(p.name, func(t *testing.T) {
+			dataProvider := p.dataProvider()
+			evaluator := NewEvaluator(dataProvider)
+			prepared := evaluator.(PreparingEvaluator).Prepare(&p.flag)
+
+			*dataProvider = *basicDataProvider() // this will panic if it is queried
+			context := ldcontext.NewBuilder("a").SetString("email", "a@example.com").Build()
+			assert.NotPanics(t, func() { _ = prepared.Evaluate(context, nil) })
+		})
+	}
+}
+
+func TestPreparedFlagWithCustomOperator(t *testing.T) {
+	op := ldmodel.Operator("lengthIs")
+	f := makeBooleanFlagWithClauses(ldbuilders.Clause("name", op, ldvalue.Int(3)))
+	evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionCustomOperator(op,
+		func(contextValue, clauseValue ldvalue.Value, preprocessed interface{}) bool {
+			return len(contextValue.StringValue()) == clauseValue.IntValue()
+		}))
+
+	result := evaluator.(PreparingEvaluator).Prepare(&f).Evaluate(ldcontext.NewBuilder("x").Name("Bob").Build(), nil)
+
+	assert.Equal(t, ldvalue.Bool(true), result.Detail.Value)
+}
+
+func TestPreparedFlagReturnsFlag(t *testing.T) {
+	f := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+	assert.Equal(t, &f, basicEvaluator().(PreparingEvaluator).Prepare(&f).Flag())
+}
+
+func TestPreparedFlagIsStale(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("segment").Version(1).Build()
+	prereq := ldbuilders.NewFlagBuilder("prereq").Version(1).On(true).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f := ldbuilders.NewFlagBuilder("flag").Version(1).On(true).
+		AddPrerequisite(prereq.Key, 0).AddPrerequisite("missing-flag", 0).
+		AddRule(ldbuilders.NewRuleBuilder().ID("r").Variation(0).
+			Clauses(ldbuilders.SegmentMatchClause(segment.Key, "missing-segment"))).
+		FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	baseDataProvider := basicDataProvider().withNonexistentFlag("missing-flag").withNonexistentSegment("missing-segment")
+	dataProvider := baseDataProvider.withStoredFlags(f, prereq).withStoredSegments(segment)
+	prepared := NewEvaluator(dataProvider).(PreparingEvaluator).Prepare(&f)
+
+	assert.False(t, prepared.IsStale())
+
+	newFlag := ldbuilders.NewFlagBuilder(f.Key).Version(2).Build()
+	newPrereq := ldbuilders.NewFlagBuilder(prereq.Key).Version(2).Build()
+	newSegment := ldbuilders.NewSegmentBuilder(segment.Key).Version(2).Build()
+	for _, p := range []struct {
+		name          string
+		updatedSource *simpleDataProvider
+	}{
+		{"flag updated", baseDataProvider.withStoredFlags(newFlag, prereq).withStoredSegments(segment)},
+		{"prerequisite updated", baseDataProvider.withStoredFlags(f, newPrereq).withStoredSegments(segment)},
+		{"prerequisite deleted", baseDataProvider.withStoredFlags(f).withNonexistentFlag(prereq.Key).
+			withStoredSegments(segment)},
+		{"prerequisite added", baseDataProvider.withStoredFlags(f, prereq,
+			ldbuilders.NewFlagBuilder("missing-flag").Build()).withStoredSegments(segment)},
+		{"segment updated", baseDataProvider.withStoredFlags(f, prereq).withStoredSegments(newSegment)},
+		{"segment added", baseDataProvider.withStoredFlags(f, prereq).withStoredSegments(segment,
+			ldbuilders.NewSegmentBuilder("missing-segment").Build())},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			*dataProvider = *p.updatedSource
+			assert.True(t, prepared.IsStale())
+		})
+	}
+}
+
+func TestPreparedFlagWithInvalidContext(t *testing.T) {
+	f := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+	result := basicEvaluator().(PreparingEvaluator).Prepare(&f).Evaluate(ldcontext.New(""), nil)
+	assert.Equal(t, ldreason.EvalErrorUserNotSpecified, result.Detail.Reason.GetErrorKind())
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldlog"
 	"github.com/launchdarkly/go-sdk-common/v3/ldlogtest"
@@ -215,6 +216,116 @@ func TestMultipleLevelsOfPrerequisiteProduceMultipleEvents(t *testing.T) {
 	m.In(t).Assert(e1.PrerequisiteResult, ResultDetailProps(1, ldvalue.String("go"), ldreason.NewEvalReasonFallthrough()))
 }
 
+func TestSharedPrerequisiteIsEvaluatedOnlyOnce(t *testing.T) {
+	// feature0 requires feature1 and feature2, both of which require feature3, which requires feature4.
+	// We can tell how many times feature3 was really evaluated by counting how many times the evaluator
+	// had to look up feature4.
+	f4 := ldbuilders.NewFlagBuilder("feature4").On(true).FallthroughVariation(1).
+		Variations(ldvalue.String("nogo"), ldvalue.String("go")).Build()
+	f3 := ldbuilders.NewFlagBuilder("feature3").On(true).AddPrerequisite(f4.Key, 1).FallthroughVariation(1).
+		Variations(ldvalue.String("nogo"), ldvalue.String("go")).Build()
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).AddPrerequisite(f3.Key, 1).FallthroughVariation(1).
+		Variations(ldvalue.String("nogo"), ldvalue.String("go")).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(f3.Key, 1).FallthroughVariation(1).
+		Variations(ldvalue.String("nogo"), ldvalue.String("go")).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).OffVariation(1).
+		AddPrerequisite(f1.Key, 1).AddPrerequisite(f2.Key, 1).FallthroughVariation(0).
+		Variations(fallthroughValue, offValue, onValue).Build()
+	provider := newEnumerableDataProvider(basicDataProvider(), f1, f2, f3, f4)
+	evaluator := NewEvaluator(provider)
+
+	eventSink := prereqEventSink{}
+	result := evaluator.Evaluate(&f0, flagTestContext, eventSink.record)
+	m.In(t).Assert(result, ResultDetailProps(0, fallthroughValue, ldreason.NewEvalReasonFallthrough()))
+
+	assert.Equal(t, 1, provider.timesQueried(f4.Key))
+
+	type edge struct{ target, prereq string }
+	var edges []edge
+	for _, e := range eventSink.events {
+		edges = append(edges, edge{e.TargetFlagKey, e.PrerequisiteFlag.Key})
+		m.In(t).Assert(e.PrerequisiteResult, ResultDetailProps(1, ldvalue.String("go"), ldreason.NewEvalReasonFallthrough()))
+	}
+	assert.Equal(t, []edge{
+		{f3.Key, f4.Key},
+		{f1.Key, f3.Key},
+		{f0.Key, f1.Key},
+		{f2.Key, f3.Key}, // feature3 is reported again for feature2, but its own prerequisite is not
+		{f0.Key, f2.Key},
+	}, edges)
+}
+
+func TestPrerequisiteMemoizationWithManySharedPrerequisites(t *testing.T) {
+	// This verifies that memoization still works once we've exceeded preallocatedPrerequisiteMemoSize.
+	leaf := ldbuilders.NewFlagBuilder("leaf").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	common := ldbuilders.NewFlagBuilder("common").On(true).AddPrerequisite(leaf.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	flags := []ldmodel.FeatureFlag{leaf, common}
+	topBuilder := ldbuilders.NewFlagBuilder("top").On(true).FallthroughVariation(0).OffVariation(1).
+		Variations(ldvalue.Bool(true), ldvalue.Bool(false))
+	for i := 0; i < preallocatedPrerequisiteMemoSize*2; i++ {
+		f := ldbuilders.NewFlagBuilder(fmt.Sprintf("middle%d", i)).On(true).AddPrerequisite(common.Key, 0).
+			FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+		flags = append(flags, f)
+		topBuilder.AddPrerequisite(f.Key, 0)
+	}
+	top := topBuilder.Build()
+	provider := newEnumerableDataProvider(basicDataProvider(), flags...)
+
+	eventSink := prereqEventSink{}
+	result := NewEvaluator(provider).Evaluate(&top, flagTestContext, eventSink.record)
+
+	m.In(t).Assert(result, ResultDetailProps(0, ldvalue.Bool(true), ldreason.NewEvalReasonFallthrough()))
+	assert.Equal(t, 1, provider.timesQueried(leaf.Key))
+	assert.Len(t, eventSink.events, 1+preallocatedPrerequisiteMemoSize*2*2)
+}
+
+func TestPrerequisiteIsEvaluatedAgainIfVersionChanges(t *testing.T) {
+	// This can only happen if the data store is updated while an evaluation is in progress.
+	leaf := ldbuilders.NewFlagBuilder("leaf").On(true).FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	common := ldbuilders.NewFlagBuilder("common").On(true).AddPrerequisite(leaf.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(common.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).AddPrerequisite(common.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(f1.Key, 0).AddPrerequisite(f2.Key, 0).
+		FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	provider := newEnumerableDataProvider(basicDataProvider(), leaf, f1, f2)
+	commonVersion := 0
+	baseGetFlag := provider.simpleDataProvider.getFlag
+	provider.simpleDataProvider.getFlag = func(key string) *ldmodel.FeatureFlag {
+		if key == common.Key {
+			commonVersion++
+			c := common
+			c.Version = commonVersion
+			return &c
+		}
+		return baseGetFlag(key)
+	}
+
+	_ = NewEvaluator(provider).Evaluate(&f0, flagTestContext, nil)
+
+	assert.Equal(t, 2, provider.timesQueried(leaf.Key))
+}
+
+func TestPrerequisiteCycleDetectionWithSharedPrerequisite(t *testing.T) {
+	// feature0 requires feature1 and feature2, both of which require feature3; feature3 requires feature2.
+	f3 := ldbuilders.NewFlagBuilder("feature3").On(true).AddPrerequisite("feature2", 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f2 := ldbuilders.NewFlagBuilder("feature2").On(true).AddPrerequisite(f3.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite(f3.Key, 0).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite(f1.Key, 0).AddPrerequisite(f2.Key, 0).
+		FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredFlags(f1, f2, f3))
+
+	result := evaluator.Evaluate(&f0, flagTestContext, nil)
+
+	m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+}
+
 func TestPrerequisiteCycleDetection(t *testing.T) {
 	for _, cycleGoesToOriginalFlag := range []bool{true, false} {
 		t.Run(fmt.Sprintf("cycleGoesToOriginalFlag=%t", cycleGoesToOriginalFlag), func(t *testing.T) {
@@ -0,0 +1,139 @@
+package evaluation
+
+import (
+	"strconv"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// RolloutSpec describes a percentage rollout or experiment, along with the other properties of the
+// flag that determine how contexts are bucketed for it. See EstimateRolloutImpact.
+type RolloutSpec struct {
+	// FlagKey is the key of the flag that contains the rollout.
+	FlagKey string
+	// Salt is the flag's Salt property.
+	Salt string
+	// Rollout is the rollout in the flag rule or fallthrough.
+	Rollout ldmodel.Rollout
+}
+
+// RolloutTransition identifies a pair of variation indexes in a RolloutImpact.
+type RolloutTransition struct {
+	From int
+	To   int
+}
+
+// RolloutImpact is the result of EstimateRolloutImpact.
+type RolloutImpact struct {
+	// Moved is the fraction of all contexts that would move from one variation to another, keyed by the
+	// pair of variation indexes. Pairs with no movement, and contexts that stay in the same variation,
+	// are not included.
+	Moved map[RolloutTransition]float64
+	// TotalMoved is the sum of all the fractions in Moved.
+	TotalMoved float64
+	// Reshuffled is true if the two rollouts compute bucket values differently, because they have a
+	// different flag key, salt, seed, bucketBy attribute, or context kind. In that case, every
+	// context gets a new bucket value that has no relation to its old one, so Moved is an estimate
+	// that is only accurate for a large enough number of contexts.
+	Reshuffled bool
+}
+
+// rolloutInterval is the range of bucket values, scaled to [0, 100000], that are assigned to a variation.
+type rolloutInterval struct {
+	variation  int
+	start, end int
+}
+
+// EstimateRolloutImpact computes how many contexts would receive a different variation if a rollout
+// were changed from one configuration to another.
+//
+// If the bucketing inputs are the same, a context's bucket value does not change, and the result is
+// exact: it is the fraction of the bucket value range that is assigned to a different variation. If
+// they are different (see RolloutImpact.Reshuffled), the result assumes that old and new bucket values
+// are statistically independent. Either way, it describes contexts that can be bucketed; a context
+// that lacks the rollout's context kind or bucketBy attribute always gets the first variation.
+//
+// This does not take EvaluatorOptionEnableSecondaryKey into account. To measure the impact on a
+// specific set of contexts, use ldsimulation.CompareRollouts. If either rollout has no buckets, the
+// result is empty.
+func EstimateRolloutImpact(before, after RolloutSpec) RolloutImpact {
+	beforeIntervals, afterIntervals := makeRolloutIntervals(before.Rollout), makeRolloutIntervals(after.Rollout)
+	ret := RolloutImpact{Moved: make(map[RolloutTransition]float64)}
+	if len(beforeIntervals) == 0 || len(afterIntervals) == 0 {
+		return ret
+	}
+	ret.Reshuffled = rolloutHashIdentity(before) != rolloutHashIdentity(after)
+	for _, b := range beforeIntervals {
+		for _, a := range afterIntervals {
+			if a.variation == b.variation {
+				continue
+			}
+			var fraction float64
+			if ret.Reshuffled {
+				fraction = float64(b.end-b.start) / 100000 * float64(a.end-a.start) / 100000
+			} else if overlap := minInt(a.end, b.end) - maxInt(a.start, b.start); overlap > 0 {
+				fraction = float64(overlap) / 100000
+			}
+			if fraction > 0 {
+				ret.Moved[RolloutTransition{From: b.variation, To: a.variation}] += fraction
+				ret.TotalMoved += fraction
+			}
+		}
+	}
+	return ret
+}
+
+// makeRolloutIntervals reproduces the logic of variationOrRolloutResult: buckets are consecutive
+// ranges of bucket values, any value beyond the end of the last bucket goes into the last bucket,
+// and any bucket that starts at or after the end of the range is unreachable.
+func makeRolloutIntervals(r ldmodel.Rollout) []rolloutInterval {
+	ret := make([]rolloutInterval, 0, len(r.Variations))
+	sum := 0
+	for i, wv := range r.Variations {
+		start := sum
+		sum += wv.Weight
+		end := sum
+		if i == len(r.Variations)-1 || end > 100000 {
+			end = 100000
+		}
+		if end > start {
+			ret = append(ret, rolloutInterval{variation: wv.Variation, start: start, end: end})
+		}
+		if end == 100000 {
+			break
+		}
+	}
+	return ret
+}
+
+// rolloutHashIdentity returns a string that is the same for two rollouts if and only if
+// computeBucketValue would produce the same bucket value for every context.
+func rolloutHashIdentity(spec RolloutSpec) string {
+	var prefix string
+	if spec.Rollout.Seed.IsDefined() {
+		prefix = strconv.Itoa(spec.Rollout.Seed.IntValue())
+	} else {
+		prefix = spec.FlagKey + "." + spec.Salt
+	}
+	contextKind := spec.Rollout.ContextKind
+	if contextKind == "" {
+		contextKind = ldcontext.DefaultKind
+	}
+	attr := bucketingAttribute(spec.Rollout.IsExperiment(), spec.Rollout.BucketBy)
+	return strconv.Quote(prefix) + " " + strconv.Quote(string(contextKind)) + " " + strconv.Quote(attr.String())
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
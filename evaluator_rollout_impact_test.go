@@ -0,0 +1,91 @@
+package evaluation
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeRolloutSpec(buckets ...ldmodel.WeightedVariation) RolloutSpec {
+	return RolloutSpec{FlagKey: "flag", Salt: "salt", Rollout: ldbuilders.Rollout(buckets...).Rollout}
+}
+
+func TestEstimateRolloutImpactWithSameBucketing(t *testing.T) {
+	t.Run("no change", func(t *testing.T) {
+		spec := makeRolloutSpec(ldbuilders.Bucket(0, 30000), ldbuilders.Bucket(1, 70000))
+		impact := EstimateRolloutImpact(spec, spec)
+		assert.Equal(t, RolloutImpact{Moved: map[RolloutTransition]float64{}}, impact)
+	})
+
+	t.Run("weights shifted", func(t *testing.T) {
+		before := makeRolloutSpec(ldbuilders.Bucket(0, 30000), ldbuilders.Bucket(1, 70000))
+		after := makeRolloutSpec(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+		impact := EstimateRolloutImpact(before, after)
+		assert.False(t, impact.Reshuffled)
+		assert.Equal(t, map[RolloutTransition]float64{{From: 1, To: 0}: 0.2}, impact.Moved)
+		assert.InDelta(t, 0.2, impact.TotalMoved, 0.000001)
+	})
+
+	t.Run("buckets reordered", func(t *testing.T) {
+		before := makeRolloutSpec(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+		after := makeRolloutSpec(ldbuilders.Bucket(1, 50000), ldbuilders.Bucket(0, 50000))
+		impact := EstimateRolloutImpact(before, after)
+		assert.Equal(t, map[RolloutTransition]float64{{From: 0, To: 1}: 0.5, {From: 1, To: 0}: 0.5}, impact.Moved)
+		assert.InDelta(t, 1, impact.TotalMoved, 0.000001)
+	})
+
+	t.Run("weights that do not add up are pinned to last bucket", func(t *testing.T) {
+		before := makeRolloutSpec(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 40000))
+		after := makeRolloutSpec(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+		impact := EstimateRolloutImpact(before, after)
+		assert.Len(t, impact.Moved, 0)
+	})
+}
+
+func TestEstimateRolloutImpactWithDifferentBucketing(t *testing.T) {
+	before := makeRolloutSpec(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+	for _, p := range []struct {
+		name   string
+		modify func(*RolloutSpec)
+	}{
+		{"salt", func(s *RolloutSpec) { s.Salt = "other" }},
+		{"flag key", func(s *RolloutSpec) { s.FlagKey = "other" }},
+		{"seed", func(s *RolloutSpec) { s.Rollout.Seed = ldvalue.NewOptionalInt(1) }},
+		{"bucketBy", func(s *RolloutSpec) { s.Rollout.BucketBy = ldattr.NewRef("email") }},
+		{"context kind", func(s *RolloutSpec) { s.Rollout.ContextKind = "org" }},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			after := before
+			p.modify(&after)
+			impact := EstimateRolloutImpact(before, after)
+			assert.True(t, impact.Reshuffled)
+			assert.Equal(t, map[RolloutTransition]float64{{From: 0, To: 1}: 0.25, {From: 1, To: 0}: 0.25}, impact.Moved)
+			assert.InDelta(t, 0.5, impact.TotalMoved, 0.000001)
+		})
+	}
+
+	t.Run("bucketBy is ignored in experiments", func(t *testing.T) {
+		experiment := before
+		experiment.Rollout.Kind = ldmodel.RolloutKindExperiment
+		after := experiment
+		after.Rollout.BucketBy = ldattr.NewRef("email")
+		assert.False(t, EstimateRolloutImpact(experiment, after).Reshuffled)
+	})
+
+	t.Run("default context kind", func(t *testing.T) {
+		after := before
+		after.Rollout.ContextKind = "user"
+		assert.False(t, EstimateRolloutImpact(before, after).Reshuffled)
+	})
+}
+
+func TestEstimateRolloutImpactWithEmptyRollout(t *testing.T) {
+	impact := EstimateRolloutImpact(makeRolloutSpec(), makeRolloutSpec(ldbuilders.Bucket(0, 100000)))
+	assert.Len(t, impact.Moved, 0)
+	assert.Equal(t, float64(0), impact.TotalMoved)
+}
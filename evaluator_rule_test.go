@@ -25,6 +25,7 @@ func TestMalformedFlagErrorForBadRuleProperties(t *testing.T) {
 		context ldcontext.Context
 		flag    ldmodel.FeatureFlag
 		message string
+		err     error
 	}
 
 	for _, p := range []testCaseParams{
@@ -33,24 +34,29 @@ func TestMalformedFlagErrorForBadRuleProperties(t *testing.T) {
 			context: basicContext,
 			flag:    makeFlagToMatchContext(basicContext, ldbuilders.Variation(999)),
 			message: "nonexistent variation index 999",
+			err:     BadVariationError(999),
 		},
 		{
 			name:    "negative variation index",
 			context: basicContext,
 			flag:    makeFlagToMatchContext(basicContext, ldbuilders.Variation(-1)),
 			message: "nonexistent variation index -1",
+			err:     BadVariationError(-1),
 		},
 		{
 			name:    "no variation or rollout",
 			context: basicContext,
 			flag:    makeFlagToMatchContext(basicContext, ldbuilders.Rollout()),
 			message: "rollout or experiment with no variations",
+			err:     EmptyRolloutError{},
 		},
 	} {
 		t.Run(p.name, func(t *testing.T) {
 			t.Run("returns error", func(t *testing.T) {
 				result := basicEvaluator().Evaluate(&p.flag, p.context, FailOnAnyPrereqEvent(t))
 				m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+				assert.Equal(t, EvaluationError{FlagKey: p.flag.Key, RuleIndex: 0, ClauseIndex: -1, Err: p.err},
+					result.Err)
 			})
 
 			t.Run("logs error", func(t *testing.T) {
@@ -76,6 +82,7 @@ func TestMalformedFlagErrorForBadClauseProperties(t *testing.T) {
 		context ldcontext.Context
 		clause  ldmodel.Clause
 		message string
+		err     error
 	}
 
 	for _, p := range []testCaseParams{
@@ -84,12 +91,14 @@ func TestMalformedFlagErrorForBadClauseProperties(t *testing.T) {
 			context: basicContext,
 			clause:  ldbuilders.ClauseRef(ldattr.Ref{}, ldmodel.OperatorIn, ldvalue.String("a")),
 			message: "rule clause did not specify an attribute",
+			err:     EmptyAttrRefError{},
 		},
 		{
 			name:    "invalid attribute reference",
 			context: basicContext,
 			clause:  ldbuilders.ClauseRef(ldattr.NewRef("///"), ldmodel.OperatorIn, ldvalue.String("a")),
 			message: "invalid attribute reference",
+			err:     BadAttrRefError("///"),
 		},
 	} {
 		t.Run(p.name, func(t *testing.T) {
@@ -98,12 +107,16 @@ func TestMalformedFlagErrorForBadClauseProperties(t *testing.T) {
 				On(true).
 				AddRule(ldbuilders.NewRuleBuilder().ID("bad").Variation(1).Clauses(p.clause)).
 				AddRule(ldbuilders.NewRuleBuilder().ID("good").Variation(1).Clauses(goodClause)).
+				OffVariation(0).
 				Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
 				Build()
+			expectedErr := EvaluationError{FlagKey: flag.Key, RuleIndex: 0, ClauseIndex: 0, Err: p.err}
 
 			t.Run("returns error", func(t *testing.T) {
 				result := basicEvaluator().Evaluate(&flag, p.context, FailOnAnyPrereqEvent(t))
 				m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+				assert.Equal(t, EvaluationError{FlagKey: flag.Key, RuleIndex: 0, ClauseIndex: 0, Err: p.err},
+					result.Err)
 			})
 
 			t.Run("logs error", func(t *testing.T) {
@@ -117,10 +130,81 @@ func TestMalformedFlagErrorForBadClauseProperties(t *testing.T) {
 					assert.Regexp(t, p.message, errorLines[0])
 				}
 			})
+
+			t.Run("skips rule with degradation policy", func(t *testing.T) {
+				e := NewEvaluatorWithOptions(basicDataProvider(),
+					EvaluatorOptionDegradationPolicy(RuleErrorInvalidAttribute, DegradationSkipRule))
+				result := e.Evaluate(&flag, p.context, FailOnAnyPrereqEvent(t))
+				m.In(t).Assert(result, ResultDetailProps(1, ldvalue.Bool(true), ldreason.NewEvalReasonRuleMatch(1, "good")))
+				assert.Equal(t, DegradationSkipRule, result.Degradation)
+				assert.Equal(t, expectedErr, result.Err)
+			})
+
+			t.Run("serves off variation with degradation policy", func(t *testing.T) {
+				e := NewEvaluatorWithOptions(basicDataProvider(),
+					EvaluatorOptionDegradationPolicy(RuleErrorInvalidAttribute, DegradationServeOff))
+				result := e.Evaluate(&flag, p.context, FailOnAnyPrereqEvent(t))
+				m.In(t).Assert(result, ResultDetailProps(0, ldvalue.Bool(false),
+					ldreason.NewEvalReasonError(ldreason.EvalErrorMalformedFlag)))
+				assert.Equal(t, DegradationServeOff, result.Degradation)
+				assert.Equal(t, expectedErr, result.Err)
+			})
+
+			t.Run("policy for another error class does not apply", func(t *testing.T) {
+				e := NewEvaluatorWithOptions(basicDataProvider(),
+					EvaluatorOptionDegradationPolicy(RuleErrorMalformedSegment, DegradationSkipRule))
+				result := e.Evaluate(&flag, p.context, FailOnAnyPrereqEvent(t))
+				m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+				assert.Equal(t, DegradationPolicy(""), result.Degradation)
+			})
 		})
 	}
 }
 
+func TestDegradationPolicyForMalformedSegment(t *testing.T) {
+	segment0 := ldbuilders.NewSegmentBuilder("segmentkey0").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey1"))).
+		Build()
+	segment1 := ldbuilders.NewSegmentBuilder("segmentkey1").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey0"))).
+		Build()
+	prereq := ldbuilders.NewFlagBuilder("prereq").On(true).
+		AddRule(ldbuilders.NewRuleBuilder().ID("bad").Variation(0).Clauses(ldbuilders.SegmentMatchClause("segmentkey0"))).
+		FallthroughVariation(1).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).
+		AddPrerequisite(prereq.Key, 1).
+		OffVariation(0).FallthroughVariation(1).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	provider := basicDataProvider().withStoredSegments(segment0, segment1).withStoredFlags(prereq)
+
+	t.Run("fail", func(t *testing.T) {
+		result := NewEvaluator(provider).Evaluate(&flag, flagTestContext, nil)
+		m.In(t).Assert(result, ResultDetailError(ldreason.EvalErrorMalformedFlag))
+		assert.Equal(t, DegradationPolicy(""), result.Degradation)
+	})
+
+	t.Run("skip rule", func(t *testing.T) {
+		e := NewEvaluatorWithOptions(provider,
+			EvaluatorOptionDegradationPolicy(RuleErrorMalformedSegment, DegradationSkipRule))
+		eventSink := prereqEventSink{}
+		result := e.Evaluate(&flag, flagTestContext, eventSink.record)
+		m.In(t).Assert(result, ResultDetailProps(1, ldvalue.Bool(true), ldreason.NewEvalReasonFallthrough()))
+		assert.Equal(t, DegradationSkipRule, result.Degradation)
+		var segmentErr MalformedSegmentError
+		if assert.ErrorAs(t, result.Err, &segmentErr) {
+			assert.Equal(t, "segmentkey0", segmentErr.SegmentKey)
+		}
+
+		if assert.Len(t, eventSink.events, 1) {
+			assert.Equal(t, DegradationSkipRule, eventSink.events[0].PrerequisiteResult.Degradation)
+			assert.Equal(t, ldreason.NewEvalReasonFallthrough(), eventSink.events[0].PrerequisiteResult.Detail.Reason)
+		}
+	})
+}
+
 func TestClauseWithUnknownOperatorDoesNotStopSubsequentRuleFromMatching(t *testing.T) {
 	context := ldcontext.New("key")
 	badClause := ldbuilders.Clause(ldattr.NameAttr, "doesSomethingUnsupported", ldvalue.String("Bob"))
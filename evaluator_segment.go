@@ -1,8 +1,6 @@
 package evaluation
 
 import (
-	"fmt"
-
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
 	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
@@ -13,21 +11,132 @@ func makeBigSegmentRef(s *ldmodel.Segment) string {
 	// The format of big segment references is independent of what store implementation is being
 	// used; the store implementation receives only this string and does not know the details of
 	// the data model. The Relay Proxy will use the same format when writing to the store.
-	return fmt.Sprintf("%s.g%d", s.Key, s.Generation.IntValue())
+	return MakeBigSegmentRef(s.Key, s.Generation.IntValue())
+}
+
+// SegmentResult is the result returned by SegmentEvaluator.EvaluateSegment.
+type SegmentResult struct {
+	// Included is true if the context is a member of the segment.
+	Included bool
+
+	// Reason describes how the evaluator determined whether the context is a member.
+	Reason SegmentReason
+
+	// BigSegmentsStatus describes the state of the big segments query, if any, that was done while
+	// evaluating the segment or any segment that it references. It is empty if there was no query.
+	BigSegmentsStatus ldreason.BigSegmentsStatus
+
+	// Err is non-nil if the segment could not be evaluated, because the context was invalid or
+	// because the segment, or some segment that it references, is malformed or part of a circular
+	// reference. In that case, Included is false and Reason.Kind is SegmentReasonError.
+	Err error
+}
+
+// SegmentReasonKind defines the possible values of SegmentReason.Kind.
+type SegmentReasonKind string
+
+const (
+	// SegmentReasonIncluded means that the context's key was in the segment's Included list.
+	SegmentReasonIncluded SegmentReasonKind = "INCLUDED"
+	// SegmentReasonIncludedContext means that the context matched one of the segment's IncludedContexts.
+	SegmentReasonIncludedContext SegmentReasonKind = "INCLUDED_CONTEXT"
+	// SegmentReasonExcluded means that the context's key was in the segment's Excluded list.
+	SegmentReasonExcluded SegmentReasonKind = "EXCLUDED"
+	// SegmentReasonExcludedContext means that the context matched one of the segment's ExcludedContexts.
+	SegmentReasonExcludedContext SegmentReasonKind = "EXCLUDED_CONTEXT"
+	// SegmentReasonRuleMatch means that the context matched one of the segment's rules.
+	SegmentReasonRuleMatch SegmentReasonKind = "RULE_MATCH"
+	// SegmentReasonBigSegment means that the membership was determined by a big segments query. The
+	// context may be either included or excluded.
+	SegmentReasonBigSegment SegmentReasonKind = "BIG_SEGMENT"
+	// SegmentReasonNoMatch means that the context was not in any target list and did not match any rule.
+	SegmentReasonNoMatch SegmentReasonKind = "NO_MATCH"
+	// SegmentReasonError means that the segment could not be evaluated; see SegmentResult.Err.
+	SegmentReasonError SegmentReasonKind = "ERROR"
+)
+
+// SegmentReason describes how SegmentEvaluator.EvaluateSegment determined whether a context is a
+// member of a segment. Only the properties that are relevant to the Kind are set.
+type SegmentReason struct {
+	// Kind describes the general category of the reason.
+	Kind SegmentReasonKind
+
+	// ContextKind is the kind of the context that matched a target list or was used in a big segments
+	// query.
+	ContextKind ldcontext.Kind
+
+	// TargetIndex is the index of the matched target within IncludedContexts or ExcludedContexts, if
+	// Kind is SegmentReasonIncludedContext or SegmentReasonExcludedContext.
+	TargetIndex int
+
+	// RuleIndex and RuleID identify the matched rule, if Kind is SegmentReasonRuleMatch.
+	RuleIndex int
+	RuleID    string
+
+	// Weight is the weight of the matched rule, if Kind is SegmentReasonRuleMatch and the rule has a
+	// weight. In that case, BucketValue is the context's bucket value, which was less than the weight
+	// divided by 100000.
+	Weight      ldvalue.OptionalInt
+	BucketValue float32
+}
+
+func (r *SegmentReason) set(reason SegmentReason) {
+	if r != nil {
+		*r = reason
+	}
+}
+
+// Implementation of the SegmentEvaluator interface.
+func (e *evaluator) EvaluateSegment(segment *ldmodel.Segment, context ldcontext.Context) SegmentResult {
+	if context.Err() != nil {
+		return SegmentResult{Reason: SegmentReason{Kind: SegmentReasonError}, Err: context.Err()}
+	}
+	reason := SegmentReason{Kind: SegmentReasonNoMatch}
+	es := evaluationScope{
+		owner:   e,
+		context: context,
+		extras:  &evaluationExtras{segmentReason: &reason},
+	}
+	stack := evaluationStack{
+		prerequisiteFlagChain: make([]string, 0, preallocatedPrerequisiteChainSize),
+		segmentChain:          make([]string, 0, preallocatedSegmentChainSize),
+	}
+	included, err := es.segmentContainsContext(segment, nil, stack)
+	if err != nil {
+		return SegmentResult{Reason: SegmentReason{Kind: SegmentReasonError},
+			BigSegmentsStatus: es.bigSegmentsStatus, Err: err}
+	}
+	return SegmentResult{Included: included, Reason: reason, BigSegmentsStatus: es.bigSegmentsStatus}
 }
 
-func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack evaluationStack) (bool, error) {
+// The plan parameter is nil unless the flag was prepared; see evaluator_prepare.go.
+func (es *evaluationScope) segmentContainsContext(
+	s *ldmodel.Segment,
+	plan *segmentPlan,
+	stack evaluationStack,
+) (bool, error) {
 	// Have we already visited this segment recursively?
 	for _, visitedKey := range stack.segmentChain {
 		if visitedKey == s.Key {
-			return false, circularSegmentReferenceError(s.Key)
+			return false, CircularSegmentReferenceError(s.Key)
 		}
 	}
 
+	if err := es.countStep(&es.stats.SegmentsVisited); err != nil {
+		return false, err
+	}
+
 	// Add this segment key to the visited list. Since stack is passed by value, this change does not
 	// persist after we return from this method. See comments in evaluationScope.checkPrerequisites().
 	stack.segmentChain = append(stack.segmentChain, s.Key)
 
+	// If this is EvaluateSegment, we only want to report the reason for the segment that it was called
+	// with, not for any segments that are referenced by that segment's rules.
+	reason := es.extras.segmentReason
+	if len(stack.segmentChain) != 1 {
+		reason = nil
+	}
+
 	// Check if the user is specifically included in or excluded from the segment by key
 	if s.Unbounded {
 		if !s.Generation.IsDefined() {
@@ -36,12 +145,14 @@ func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack eval
 			// about the Generation property and therefore dropped it from the JSON data. We'll treat
 			// that as a "not configured" condition.
 			es.bigSegmentsStatus = ldreason.BigSegmentsNotConfigured
+			es.extras.tracer.note("big segment generation is unknown")
 			return false, nil
 		}
 		// A big segment can only apply to one context kind, so if we don't have a key for that kind,
 		// we don't need to bother querying the data.
 		key, ok := getApplicableContextKeyByKind(&es.context, s.UnboundedContextKind)
 		if !ok {
+			es.extras.tracer.note("context has no key for the big segment's context kind")
 			return false, nil
 		}
 		// Even if multiple big segments are referenced within a single flag evaluation, we only need
@@ -49,28 +160,19 @@ func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack eval
 		// memberships.
 		membership, wasCached := es.bigSegmentsMemberships[key]
 		if !wasCached {
-			if es.owner.bigSegmentProvider == nil {
-				// If the provider is nil, that means the SDK hasn't been configured to be able to
-				// use big segments.
-				es.bigSegmentsStatus = ldreason.BigSegmentsNotConfigured
-			} else {
-				var thisQueryStatus ldreason.BigSegmentsStatus
-				membership, thisQueryStatus = es.owner.bigSegmentProvider.GetMembership(key)
-				// Note that this query is just by key; the context kind doesn't matter because any given
-				// Big Segment can only reference one context kind. So if segment A for the "user" kind
-				// includes a "user" context with key X, and segment B for the "org" kind includes an "org"
-				// context with the same key X, it is fine to say that the membership for key X is
-				// segment A and segment B-- there is no ambiguity.
-				if es.bigSegmentsMemberships == nil {
-					es.bigSegmentsMemberships = make(map[string]BigSegmentMembership)
-				}
-				es.bigSegmentsMemberships[key] = membership
-				es.bigSegmentsStatus = computeUpdatedBigSegmentsStatus(es.bigSegmentsStatus, thisQueryStatus)
-			}
+			membership = es.queryBigSegmentsMembership(key)
 		}
 		if membership != nil {
 			included := membership.CheckMembership(makeBigSegmentRef(s))
 			if included.IsDefined() {
+				es.extras.tracer.note("big segment membership")
+				if reason != nil {
+					contextKind := s.UnboundedContextKind
+					if contextKind == "" {
+						contextKind = ldcontext.DefaultKind
+					}
+					reason.set(SegmentReason{Kind: SegmentReasonBigSegment, ContextKind: contextKind})
+				}
 				return included.BoolValue(), nil
 			}
 		}
@@ -79,21 +181,31 @@ func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack eval
 		defaultKindKey, hasDefaultKindKey := getApplicableContextKeyByKind(&es.context, ldcontext.DefaultKind)
 		isOnlyDefaultKind := es.context.Kind() == ldcontext.DefaultKind
 		if hasDefaultKindKey && ldmodel.EvaluatorAccessors.SegmentFindKeyInIncluded(s, defaultKindKey) {
+			es.extras.tracer.note("included")
+			reason.set(SegmentReason{Kind: SegmentReasonIncluded, ContextKind: ldcontext.DefaultKind})
 			return true, nil
 		}
 		if !isOnlyDefaultKind {
 			for i := range s.IncludedContexts {
 				if es.segmentTargetMatchesContext(&s.IncludedContexts[i]) {
+					es.extras.tracer.note("included")
+					reason.set(SegmentReason{Kind: SegmentReasonIncludedContext, TargetIndex: i,
+						ContextKind: s.IncludedContexts[i].ContextKind})
 					return true, nil
 				}
 			}
 		}
 		if hasDefaultKindKey && ldmodel.EvaluatorAccessors.SegmentFindKeyInExcluded(s, defaultKindKey) {
+			es.extras.tracer.note("excluded")
+			reason.set(SegmentReason{Kind: SegmentReasonExcluded, ContextKind: ldcontext.DefaultKind})
 			return false, nil
 		}
 		if !isOnlyDefaultKind {
 			for i := range s.ExcludedContexts {
 				if es.segmentTargetMatchesContext(&s.ExcludedContexts[i]) {
+					es.extras.tracer.note("excluded")
+					reason.set(SegmentReason{Kind: SegmentReasonExcludedContext, TargetIndex: i,
+						ContextKind: s.ExcludedContexts[i].ContextKind})
 					return false, nil
 				}
 			}
@@ -101,13 +213,30 @@ func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack eval
 	}
 
 	// Check if any of the segment rules match
-	for _, rule := range s.Rules {
+	for ruleIndex, rule := range s.Rules {
+		if err := es.countStep(&es.stats.RulesChecked); err != nil {
+			return false, err
+		}
+		var ruleStep *TraceStep
+		if es.extras.tracer != nil {
+			ruleStep = es.extras.tracer.begin(&TraceStep{Kind: TraceStepRule, Index: ruleIndex, Key: rule.ID})
+		}
 		// Note, taking address of range variable here is OK because it's not used outside the loop
-		match, err := es.segmentRuleMatchesContext(&rule, stack, s.Key, s.Salt) //nolint:gosec // see comment above
+		//nolint:gosec // see comment above
+		match, bucket, err := es.segmentRuleMatchesContext(&rule, plan.ruleClauses(ruleIndex), stack, s.Key, s.Salt)
+		if ruleStep != nil {
+			ruleStep.Matched, ruleStep.Err = match, err
+			es.extras.tracer.end()
+		}
 		if err != nil {
-			return false, malformedSegmentError{SegmentKey: s.Key, Err: err}
+			if _, ok := err.(StepBudgetExceededError); ok {
+				return false, err // this is not a problem with the segment, and should never be degraded
+			}
+			return false, MalformedSegmentError{SegmentKey: s.Key, Err: err}
 		}
 		if match {
+			reason.set(SegmentReason{Kind: SegmentReasonRuleMatch, RuleIndex: ruleIndex, RuleID: rule.ID,
+				Weight: rule.Weight, BucketValue: bucket})
 			return true, nil
 		}
 	}
@@ -115,6 +244,36 @@ func (es *evaluationScope) segmentContainsContext(s *ldmodel.Segment, stack eval
 	return false, nil
 }
 
+func (es *evaluationScope) queryBigSegmentsMembership(key string) BigSegmentMembership {
+	if es.owner.bigSegmentProvider == nil {
+		// If the provider is nil, that means the SDK hasn't been configured to be able to
+		// use big segments.
+		es.bigSegmentsStatus = ldreason.BigSegmentsNotConfigured
+		return nil
+	}
+	// If this is part of an EvaluateAll batch, some other flag may already have caused us to query
+	// the same key.
+	cached, wasCached := es.extras.batchCache.getBigSegmentsMembership(key)
+	if !wasCached {
+		// Note that this query is just by key; the context kind doesn't matter because any given
+		// Big Segment can only reference one context kind. So if segment A for the "user" kind
+		// includes a "user" context with key X, and segment B for the "org" kind includes an "org"
+		// context with the same key X, it is fine to say that the membership for key X is
+		// segment A and segment B-- there is no ambiguity.
+		cached.membership, cached.status = es.owner.bigSegmentProvider.GetMembership(key)
+		if es.owner.countStats {
+			es.stats.BigSegmentQueries++
+		}
+		es.extras.batchCache.setBigSegmentsMembership(key, cached)
+	}
+	if es.bigSegmentsMemberships == nil {
+		es.bigSegmentsMemberships = make(map[string]BigSegmentMembership)
+	}
+	es.bigSegmentsMemberships[key] = cached.membership
+	es.bigSegmentsStatus = computeUpdatedBigSegmentsStatus(es.bigSegmentsStatus, cached.status)
+	return cached.membership
+}
+
 func (es *evaluationScope) segmentTargetMatchesContext(t *ldmodel.SegmentTarget) bool {
 	if key, ok := getApplicableContextKeyByKind(&es.context, t.ContextKind); ok {
 		return ldmodel.EvaluatorAccessors.SegmentTargetFindKey(t, key)
@@ -124,20 +283,31 @@ func (es *evaluationScope) segmentTargetMatchesContext(t *ldmodel.SegmentTarget)
 
 func (es *evaluationScope) segmentRuleMatchesContext(
 	r *ldmodel.SegmentRule,
+	clausePlans []clausePlan,
 	stack evaluationStack,
 	key, salt string,
-) (bool, error) {
+) (bool, float32, error) {
 	for i := range r.Clauses {
+		var plan *clausePlan
+		if clausePlans != nil {
+			plan = &clausePlans[i]
+		}
 		// Note that the clause is passed by address only for efficiency; we do not modify it
-		match, err := es.clauseMatchesContext(&r.Clauses[i], stack)
+		var match bool
+		var err error
+		if es.extras.tracer != nil {
+			match, err = es.traceClause(i, &r.Clauses[i], plan, stack)
+		} else {
+			match, err = es.clauseMatchesContext(&r.Clauses[i], plan, stack)
+		}
 		if !match || err != nil {
-			return false, err
+			return false, 0, err
 		}
 	}
 
 	// If the Weight is absent, this rule matches
 	if !r.Weight.IsDefined() {
-		return true, nil
+		return true, 0, nil
 	}
 
 	// All of the clauses are met. Check to see if the user buckets in
@@ -153,26 +323,33 @@ func (es *evaluationScope) segmentRuleMatchesContext(
 		r.BucketBy,
 		salt,
 	)
+	weight := float32(r.Weight.IntValue()) / 100000.0
+	if es.extras.tracer != nil {
+		es.traceBucket(contextKindOrDefault(r.RolloutContextKind), bucketingAttribute(false, r.BucketBy), bucket, 0,
+			weight, ldvalue.OptionalInt{}, failReason, err)
+	}
 	if err != nil {
 		// err is only non-nil for problems serious enough to indicate a malformed segment configuration
-		return false, err
+		return false, 0, err
 	}
-	if failReason == bucketingFailureContextLacksDesiredKind {
+	if failReason == BucketingFailureContextLacksDesiredKind {
 		// This particular bucketing failure condition is specified to cause an automatic non-match for the rule.
 		// Other kinds of bucketing failures (such as an unknown bucketBy attribute) do not cause a non-match;
 		// they just cause the bucket value to be zero, which in this code path will result in a match. The latter
 		// behavior isn't logically consistent, but is preserved for historical reasons since changing it would
 		// change existing evaluation results.
-		return false, nil
+		return false, 0, nil
 	}
-	weight := float32(r.Weight.IntValue()) / 100000.0
-	return bucket < weight, nil
+	return bucket < weight, bucket, nil
 }
 
 func computeUpdatedBigSegmentsStatus(old, new ldreason.BigSegmentsStatus) ldreason.BigSegmentsStatus {
 	// A single evaluation could end up doing more than one big segments query if there are two different
 	// context keys involved. If those queries don't return the same status, we want to make sure we
 	// report whichever status is most problematic.
+	if new == "" {
+		return old
+	}
 	if old != "" && getBigSegmentsStatusPriority(old) > getBigSegmentsStatusPriority(new) {
 		return old
 	}
@@ -0,0 +1,263 @@
+package evaluation
+
+import (
+	"sort"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// SegmentSource is an abstraction for listing all of the segments in a data store. The caller
+// provides an implementation of this interface to NewSegmentIndex.
+type SegmentSource interface {
+	// GetAllSegments returns all of the segments in the data store, in any order.
+	//
+	// The SegmentSource may either omit deleted segments or return their placeholders; the index
+	// skips any segment whose Deleted property is true.
+	GetAllSegments() []*ldmodel.Segment
+}
+
+// SegmentIndex is an inverted index of segments, which allows SegmentIndexEvaluator.SegmentsForContext to
+// find all of the segments that a context belongs to without evaluating every segment.
+//
+// The index maps each context kind and key in a segment's Included list or IncludedContexts, and each
+// value of a rule clause that uses the "in" operator, to the segments that might match a context with
+// that key or attribute value. Only those segments, plus any segments whose rules cannot be indexed in
+// this way (for instance, because a rule uses some other operator, or has no clauses) and any big
+// segments for the context's kinds, are then evaluated in full.
+//
+// A SegmentIndex is a snapshot of the segments at the time it was created; if the segments change, the
+// caller should create a new one. It is safe to use a SegmentIndex concurrently from multiple goroutines.
+type SegmentIndex struct {
+	segments    []*ldmodel.Segment // sorted by key; the indices below refer to this slice
+	targets     map[segmentIndexTarget][]int
+	attributes  map[segmentIndexAttributeKey]*segmentIndexAttribute
+	bigSegments map[ldcontext.Kind][]int
+	unindexed   []int
+}
+
+type segmentIndexTarget struct {
+	contextKind ldcontext.Kind
+	key         string
+}
+
+type segmentIndexAttributeKey struct {
+	contextKind ldcontext.Kind
+	attribute   string
+}
+
+type segmentIndexAttribute struct {
+	contextKind ldcontext.Kind
+	attribute   ldattr.Ref
+	values      map[string][]int
+}
+
+// ContextSegments is the result returned by SegmentIndexEvaluator.SegmentsForContext.
+type ContextSegments struct {
+	// SegmentKeys are the keys of all the segments that the context is a member of, in alphabetical
+	// order.
+	SegmentKeys []string
+
+	// BigSegmentsStatus describes the state of the big segments queries, if any, that were needed. If
+	// there was more than one, this is the most serious problem that any of them reported. It is
+	// empty if there were no queries.
+	BigSegmentsStatus ldreason.BigSegmentsStatus
+
+	// BigSegmentLookups are the context keys, in alphabetical order, for which the evaluator queried
+	// the BigSegmentProvider. Each key is queried at most once, no matter how many big segments
+	// reference it.
+	BigSegmentLookups []string
+
+	// Errors contains an error for each segment that could not be evaluated, keyed by segment key.
+	// Those segments are not included in SegmentKeys. It is nil if there were no errors.
+	Errors map[string]error
+
+	// Err is non-nil if the context was invalid, in which case no segments were evaluated and all of
+	// the other properties are empty.
+	Err error
+}
+
+// NewSegmentIndex creates a SegmentIndex containing all of the segments from a SegmentSource.
+func NewSegmentIndex(source SegmentSource) *SegmentIndex {
+	index := &SegmentIndex{
+		targets:     make(map[segmentIndexTarget][]int),
+		attributes:  make(map[segmentIndexAttributeKey]*segmentIndexAttribute),
+		bigSegments: make(map[ldcontext.Kind][]int),
+	}
+	for _, s := range source.GetAllSegments() {
+		if s != nil && !s.Deleted {
+			index.segments = append(index.segments, s)
+		}
+	}
+	sort.Slice(index.segments, func(i, j int) bool { return index.segments[i].Key < index.segments[j].Key })
+	for i, s := range index.segments {
+		index.addSegment(i, s)
+	}
+	return index
+}
+
+func (index *SegmentIndex) addSegment(i int, s *ldmodel.Segment) {
+	if s.Unbounded {
+		// Membership in a big segment can only be determined by querying the BigSegmentProvider, so
+		// it is a candidate for any context that has the right kind. Its Included and IncludedContexts
+		// properties are not used in evaluations.
+		kind := s.UnboundedContextKind
+		if kind == "" {
+			kind = ldcontext.DefaultKind
+		}
+		index.bigSegments[kind] = append(index.bigSegments[kind], i)
+	} else {
+		for _, key := range s.Included {
+			index.addTarget(ldcontext.DefaultKind, key, i)
+		}
+		for _, t := range s.IncludedContexts {
+			for _, key := range t.Values {
+				index.addTarget(t.ContextKind, key, i)
+			}
+		}
+	}
+	for j := range s.Rules {
+		if !index.addRule(&s.Rules[j], i) {
+			index.unindexed = append(index.unindexed, i)
+			return
+		}
+	}
+}
+
+func (index *SegmentIndex) addTarget(kind ldcontext.Kind, key string, i int) {
+	if kind == "" {
+		kind = ldcontext.DefaultKind
+	}
+	target := segmentIndexTarget{contextKind: kind, key: key}
+	index.targets[target] = appendSegmentIndex(index.targets[target], i)
+}
+
+// addRule indexes a segment rule by the values of one of its clauses, and returns false if none of the
+// clauses can be indexed. Since a rule only matches if every clause matches, a context can only match
+// the rule if it has one of the values of that clause.
+func (index *SegmentIndex) addRule(r *ldmodel.SegmentRule, i int) bool {
+	for j := range r.Clauses {
+		c := &r.Clauses[j]
+		if !isIndexableClause(c) {
+			continue
+		}
+		kind := c.ContextKind
+		if kind == "" {
+			kind = ldcontext.DefaultKind
+		}
+		key := segmentIndexAttributeKey{contextKind: kind, attribute: c.Attribute.String()}
+		attr := index.attributes[key]
+		if attr == nil {
+			attr = &segmentIndexAttribute{contextKind: kind, attribute: c.Attribute, values: make(map[string][]int)}
+			index.attributes[key] = attr
+		}
+		for _, v := range c.Values {
+			attr.values[v.StringValue()] = appendSegmentIndex(attr.values[v.StringValue()], i)
+		}
+		return true
+	}
+	return false
+}
+
+// appendSegmentIndex adds a segment index to a list, unless it is already the last one. Segments are
+// added in order, so this is enough to avoid duplicates.
+func appendSegmentIndex(indices []int, i int) []int {
+	if n := len(indices); n != 0 && indices[n-1] == i {
+		return indices
+	}
+	return append(indices, i)
+}
+
+func isIndexableClause(c *ldmodel.Clause) bool {
+	if c.Op != ldmodel.OperatorIn || c.Negate || !c.Attribute.IsDefined() || c.Attribute.Err() != nil ||
+		c.Attribute.String() == ldattr.KindAttr {
+		return false
+	}
+	for _, v := range c.Values {
+		if v.Type() != ldvalue.StringType {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the indices of all the segments that the context might be a member of.
+func (index *SegmentIndex) candidates(context *ldcontext.Context) []int {
+	found := make([]bool, len(index.segments))
+	add := func(indices []int) {
+		for _, i := range indices {
+			found[i] = true
+		}
+	}
+	add(index.unindexed)
+	for i := 0; i < context.IndividualContextCount(); i++ {
+		c := context.IndividualContextByIndex(i)
+		add(index.targets[segmentIndexTarget{contextKind: c.Kind(), key: c.Key()}])
+		add(index.bigSegments[c.Kind()])
+	}
+	for _, attr := range index.attributes {
+		c := context.IndividualContextByKind(attr.contextKind)
+		if !c.IsDefined() {
+			continue
+		}
+		value := c.GetValueForRef(attr.attribute)
+		if value.Type() == ldvalue.ArrayType {
+			for j := 0; j < value.Count(); j++ {
+				if element := value.GetByIndex(j); element.Type() == ldvalue.StringType {
+					add(attr.values[element.StringValue()])
+				}
+			}
+		} else if value.Type() == ldvalue.StringType {
+			add(attr.values[value.StringValue()])
+		}
+	}
+	var ret []int
+	for i, f := range found {
+		if f {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// Implementation of the SegmentIndexEvaluator interface.
+func (e *evaluator) SegmentsForContext(index *SegmentIndex, context ldcontext.Context) ContextSegments {
+	var result ContextSegments
+	if err := context.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	// All of the segments are evaluated in one scope, so that big segment memberships are only
+	// queried once per context key.
+	es := evaluationScope{
+		owner:   e,
+		context: context,
+		extras:  &noEvaluationExtras,
+	}
+	stack := evaluationStack{
+		prerequisiteFlagChain: make([]string, 0, preallocatedPrerequisiteChainSize),
+		segmentChain:          make([]string, 0, preallocatedSegmentChainSize),
+	}
+	for _, i := range index.candidates(&context) {
+		s := index.segments[i]
+		es.stats = EvaluationStats{} // the step budget, if any, applies to each segment separately
+		included, err := es.segmentContainsContext(s, nil, stack)
+		if err != nil {
+			if result.Errors == nil {
+				result.Errors = make(map[string]error)
+			}
+			result.Errors[s.Key] = err
+		} else if included {
+			result.SegmentKeys = append(result.SegmentKeys, s.Key)
+		}
+	}
+	result.BigSegmentsStatus = es.bigSegmentsStatus
+	for key := range es.bigSegmentsMemberships {
+		result.BigSegmentLookups = append(result.BigSegmentLookups, key)
+	}
+	sort.Strings(result.BigSegmentLookups)
+	return result
+}
@@ -0,0 +1,165 @@
+package evaluation
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type segmentList []ldmodel.Segment
+
+func (l segmentList) GetAllSegments() []*ldmodel.Segment {
+	ret := make([]*ldmodel.Segment, 0, len(l))
+	for i := range l {
+		ret = append(ret, &l[i])
+	}
+	return ret
+}
+
+func makeSegmentsForIndexTest() segmentList {
+	deleted := ldbuilders.NewSegmentBuilder("deleted").Included("a").Build()
+	deleted.Deleted = true
+	return segmentList{
+		ldbuilders.NewSegmentBuilder("included").Included("a", "b").Excluded("c").Build(),
+		ldbuilders.NewSegmentBuilder("org-target").IncludedContextKind("org", "o1").Build(),
+		ldbuilders.NewSegmentBuilder("excluded").Included("b").Excluded("a").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+				ldbuilders.Clause(ldattr.KeyAttr, ldmodel.OperatorIn, ldvalue.String("a")))).
+			Build(),
+		ldbuilders.NewSegmentBuilder("by-email").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+				ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@example.com")),
+				ldbuilders.Clause("name", ldmodel.OperatorIn, ldvalue.String("Lucy"), ldvalue.String("Mina")))).
+			Build(),
+		ldbuilders.NewSegmentBuilder("by-group").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+				ldbuilders.ClauseWithKind("org", "groups", ldmodel.OperatorIn, ldvalue.String("beta")))).
+			Build(),
+		ldbuilders.NewSegmentBuilder("unindexed").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+				ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@example.com")))).
+			Build(),
+		ldbuilders.NewSegmentBuilder("nested").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("included"))).
+			Build(),
+		deleted,
+	}
+}
+
+func TestSegmentsForContext(t *testing.T) {
+	segments := makeSegmentsForIndexTest()
+	index := NewSegmentIndex(segments)
+	evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segments...))
+
+	for _, p := range []struct {
+		name     string
+		context  ldcontext.Context
+		expected []string
+	}{
+		{"included by key", ldcontext.New("a"), []string{"included", "nested"}},
+		{"included in two segments", ldcontext.New("b"), []string{"excluded", "included", "nested"}},
+		{"excluded", ldcontext.New("c"), nil},
+		{"rule with indexed and unindexed clauses",
+			ldcontext.NewBuilder("x").Name("Mina").SetString("email", "mina@example.com").Build(),
+			[]string{"by-email", "unindexed"}},
+		{"unindexed rule only", ldcontext.NewBuilder("x").SetString("email", "x@example.com").Build(),
+			[]string{"unindexed"}},
+		{"array attribute", ldcontext.NewBuilder("o2").Kind("org").
+			SetValue("groups", ldvalue.ArrayOf(ldvalue.String("alpha"), ldvalue.String("beta"))).Build(),
+			[]string{"by-group"}},
+		{"multi-kind", ldcontext.NewMulti(ldcontext.New("a"), ldcontext.NewWithKind("org", "o1")),
+			[]string{"included", "nested", "org-target"}},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			result := evaluator.(SegmentIndexEvaluator).SegmentsForContext(index, p.context)
+			assert.Equal(t, ContextSegments{SegmentKeys: p.expected}, result)
+
+			// The result should be the same as evaluating every segment individually.
+			var expected []string
+			for i := range segments {
+				if !segments[i].Deleted && evaluator.(SegmentEvaluator).EvaluateSegment(&segments[i], p.context).Included {
+					expected = append(expected, segments[i].Key)
+				}
+			}
+			sort.Strings(expected)
+			assert.Equal(t, expected, result.SegmentKeys)
+		})
+	}
+}
+
+func TestSegmentIndexOnlyEvaluatesCandidates(t *testing.T) {
+	index := NewSegmentIndex(makeSegmentsForIndexTest())
+	candidateKeys := func(context ldcontext.Context) []string {
+		var keys []string
+		for _, i := range index.candidates(&context) {
+			keys = append(keys, index.segments[i].Key)
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"nested", "unindexed"}, candidateKeys(ldcontext.New("z")))
+	assert.Equal(t, []string{"excluded", "included", "nested", "unindexed"}, candidateKeys(ldcontext.New("a")))
+	assert.Equal(t, []string{"by-email", "nested", "unindexed"},
+		candidateKeys(ldcontext.NewBuilder("z").Name("Lucy").Build()))
+	assert.Equal(t, []string{"nested", "org-target", "unindexed"}, candidateKeys(ldcontext.NewWithKind("org", "o1")))
+}
+
+func TestSegmentsForContextWithBigSegments(t *testing.T) {
+	big1 := ldbuilders.NewSegmentBuilder("big1").Unbounded(true).Generation(1).Build()
+	big2 := ldbuilders.NewSegmentBuilder("big2").Unbounded(true).Generation(1).Build()
+	bigOrg := ldbuilders.NewSegmentBuilder("big-org").Unbounded(true).UnboundedContextKind("org").
+		Generation(1).Build()
+	segments := segmentList{big1, big2, bigOrg}
+	provider := basicBigSegmentsProvider().withStatusForKey("o1", ldreason.BigSegmentsStale).
+		withMembership("a", basicMembership().include(makeBigSegmentRef(&big1)).exclude(makeBigSegmentRef(&big2))).
+		withMembership("o1", basicMembership().include(makeBigSegmentRef(&bigOrg)))
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredSegments(segments...),
+		EvaluatorOptionBigSegmentProvider(provider))
+	index := NewSegmentIndex(segments)
+
+	result := evaluator.(SegmentIndexEvaluator).SegmentsForContext(index, ldcontext.New("a"))
+	assert.Equal(t, ContextSegments{SegmentKeys: []string{"big1"}, BigSegmentsStatus: ldreason.BigSegmentsHealthy,
+		BigSegmentLookups: []string{"a"}}, result)
+
+	multiContext := ldcontext.NewMulti(ldcontext.New("a"), ldcontext.NewWithKind("org", "o1"))
+	result = evaluator.(SegmentIndexEvaluator).SegmentsForContext(index, multiContext)
+	assert.Equal(t, ContextSegments{SegmentKeys: []string{"big-org", "big1"},
+		BigSegmentsStatus: ldreason.BigSegmentsStale, BigSegmentLookups: []string{"a", "o1"}}, result)
+
+	result = evaluator.(SegmentIndexEvaluator).SegmentsForContext(index, ldcontext.NewWithKind("other", "a"))
+	assert.Equal(t, ContextSegments{}, result)
+}
+
+func TestSegmentsForContextReportsErrors(t *testing.T) {
+	segment0 := ldbuilders.NewSegmentBuilder("segmentkey0").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey1"))).
+		Build()
+	segment1 := ldbuilders.NewSegmentBuilder("segmentkey1").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey0"))).
+		Build()
+	segment2 := ldbuilders.NewSegmentBuilder("segmentkey2").Included("a").Build()
+	segments := segmentList{segment0, segment1, segment2}
+	evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segments...))
+
+	result := evaluator.(SegmentIndexEvaluator).SegmentsForContext(NewSegmentIndex(segments), ldcontext.New("a"))
+	assert.Equal(t, []string{"segmentkey2"}, result.SegmentKeys)
+	assert.Len(t, result.Errors, 2)
+	assert.Error(t, result.Errors["segmentkey0"])
+	assert.Error(t, result.Errors["segmentkey1"])
+}
+
+func TestSegmentsForContextWithInvalidContext(t *testing.T) {
+	segments := makeSegmentsForIndexTest()
+	evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segments...))
+	context := ldcontext.New("")
+	assert.Equal(t, ContextSegments{Err: context.Err()},
+		evaluator.(SegmentIndexEvaluator).SegmentsForContext(NewSegmentIndex(segments), context))
+}
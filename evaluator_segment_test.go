@@ -491,3 +491,150 @@ func TestMalformedFlagErrorForBadSegmentProperties(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluateSegment(t *testing.T) {
+	userKey, otherKind := "key1", ldcontext.Kind("kind2")
+	matchUserKey := ldbuilders.Clause(ldattr.KeyAttr, ldmodel.OperatorIn, ldvalue.String(userKey))
+	multiContext := ldcontext.NewMulti(ldcontext.New(userKey), ldcontext.NewWithKind(otherKind, "key2"))
+
+	for _, p := range []struct {
+		name     string
+		segment  ldmodel.Segment
+		context  ldcontext.Context
+		expected SegmentResult
+	}{
+		{
+			name:     "no match",
+			segment:  buildSegment().Included("other").Build(),
+			context:  ldcontext.New(userKey),
+			expected: SegmentResult{Reason: SegmentReason{Kind: SegmentReasonNoMatch}},
+		},
+		{
+			name:    "included by key",
+			segment: buildSegment().Included(userKey).Excluded(userKey).Build(),
+			context: ldcontext.New(userKey),
+			expected: SegmentResult{Included: true,
+				Reason: SegmentReason{Kind: SegmentReasonIncluded, ContextKind: ldcontext.DefaultKind}},
+		},
+		{
+			name:    "included by context target",
+			segment: buildSegment().IncludedContextKind("other", "x").IncludedContextKind(otherKind, "key2").Build(),
+			context: multiContext,
+			expected: SegmentResult{Included: true,
+				Reason: SegmentReason{Kind: SegmentReasonIncludedContext, ContextKind: otherKind, TargetIndex: 1}},
+		},
+		{
+			name:    "excluded by key",
+			segment: buildSegment().Excluded(userKey).AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(matchUserKey)).Build(),
+			context: ldcontext.New(userKey),
+			expected: SegmentResult{
+				Reason: SegmentReason{Kind: SegmentReasonExcluded, ContextKind: ldcontext.DefaultKind}},
+		},
+		{
+			name:    "excluded by context target",
+			segment: buildSegment().ExcludedContextKind(otherKind, "key2").Build(),
+			context: multiContext,
+			expected: SegmentResult{
+				Reason: SegmentReason{Kind: SegmentReasonExcludedContext, ContextKind: otherKind}},
+		},
+		{
+			name: "rule match",
+			segment: buildSegment().
+				AddRule(ldbuilders.NewSegmentRuleBuilder().ID("r0").Clauses(ldbuilders.Negate(matchUserKey))).
+				AddRule(ldbuilders.NewSegmentRuleBuilder().ID("r1").Clauses(matchUserKey)).
+				Build(),
+			context: ldcontext.New(userKey),
+			expected: SegmentResult{Included: true,
+				Reason: SegmentReason{Kind: SegmentReasonRuleMatch, RuleIndex: 1, RuleID: "r1"}},
+		},
+		{
+			// "userKeyA" is known to have a bucket value of 0.14574753 for this segment key and salt
+			name: "rule match with weight",
+			segment: ldbuilders.NewSegmentBuilder("segkey").Salt("salty").
+				AddRule(ldbuilders.NewSegmentRuleBuilder().ID("r0").Weight(30000)).
+				Build(),
+			context: ldcontext.New("userKeyA"),
+			expected: SegmentResult{Included: true,
+				Reason: SegmentReason{Kind: SegmentReasonRuleMatch, RuleID: "r0",
+					Weight: ldvalue.NewOptionalInt(30000), BucketValue: 0.14574753}},
+		},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			evaluator := NewEvaluator(basicDataProvider())
+			result := evaluator.(SegmentEvaluator).EvaluateSegment(&p.segment, p.context)
+			assert.Equal(t, p.expected.Included, result.Included)
+			assert.Equal(t, p.expected.Reason.Kind, result.Reason.Kind)
+			assert.Equal(t, p.expected.Reason.ContextKind, result.Reason.ContextKind)
+			assert.Equal(t, p.expected.Reason.TargetIndex, result.Reason.TargetIndex)
+			assert.Equal(t, p.expected.Reason.RuleIndex, result.Reason.RuleIndex)
+			assert.Equal(t, p.expected.Reason.RuleID, result.Reason.RuleID)
+			assert.Equal(t, p.expected.Reason.Weight, result.Reason.Weight)
+			assert.InDelta(t, p.expected.Reason.BucketValue, result.Reason.BucketValue, 0.0000001)
+			assert.Equal(t, ldreason.BigSegmentsStatus(""), result.BigSegmentsStatus)
+			assert.NoError(t, result.Err)
+		})
+	}
+}
+
+func TestEvaluateSegmentReportsReasonForOuterSegmentOnly(t *testing.T) {
+	inner := ldbuilders.NewSegmentBuilder("inner").Included("key1").Build()
+	outer := ldbuilders.NewSegmentBuilder("outer").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().ID("r0").Clauses(ldbuilders.SegmentMatchClause(inner.Key))).
+		Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredSegments(inner))
+
+	result := evaluator.(SegmentEvaluator).EvaluateSegment(&outer, ldcontext.New("key1"))
+	assert.True(t, result.Included)
+	assert.Equal(t, SegmentReason{Kind: SegmentReasonRuleMatch, RuleID: "r0"}, result.Reason)
+
+	result = evaluator.(SegmentEvaluator).EvaluateSegment(&outer, ldcontext.New("key2"))
+	assert.False(t, result.Included)
+	assert.Equal(t, SegmentReason{Kind: SegmentReasonNoMatch}, result.Reason)
+}
+
+func TestEvaluateSegmentBigSegment(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("big").Unbounded(true).UnboundedContextKind(ldcontext.Kind("org")).
+		Generation(2).Build()
+	context := ldcontext.NewWithKind("org", "key1")
+
+	t.Run("included", func(t *testing.T) {
+		provider := basicBigSegmentsProvider().withStatus(ldreason.BigSegmentsStale).
+			withMembership("key1", basicMembership().include(makeBigSegmentRef(&segment)))
+		evaluator := NewEvaluatorWithOptions(basicDataProvider(), EvaluatorOptionBigSegmentProvider(provider))
+		result := evaluator.(SegmentEvaluator).EvaluateSegment(&segment, context)
+		assert.Equal(t, SegmentResult{Included: true,
+			Reason:            SegmentReason{Kind: SegmentReasonBigSegment, ContextKind: "org"},
+			BigSegmentsStatus: ldreason.BigSegmentsStale}, result)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		evaluator := NewEvaluator(basicDataProvider())
+		result := evaluator.(SegmentEvaluator).EvaluateSegment(&segment, context)
+		assert.Equal(t, SegmentResult{Reason: SegmentReason{Kind: SegmentReasonNoMatch},
+			BigSegmentsStatus: ldreason.BigSegmentsNotConfigured}, result)
+	})
+}
+
+func TestEvaluateSegmentErrors(t *testing.T) {
+	t.Run("circular reference", func(t *testing.T) {
+		segment0 := ldbuilders.NewSegmentBuilder("segmentkey0").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey1"))).
+			Build()
+		segment1 := ldbuilders.NewSegmentBuilder("segmentkey1").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(ldbuilders.SegmentMatchClause("segmentkey0"))).
+			Build()
+		evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segment0, segment1))
+
+		result := evaluator.(SegmentEvaluator).EvaluateSegment(&segment0, flagTestContext)
+		assert.False(t, result.Included)
+		assert.Equal(t, SegmentReason{Kind: SegmentReasonError}, result.Reason)
+		assert.Error(t, result.Err)
+	})
+
+	t.Run("invalid context", func(t *testing.T) {
+		segment := buildSegment().Build()
+		result := NewEvaluator(basicDataProvider()).(SegmentEvaluator).EvaluateSegment(&segment, ldcontext.New(""))
+		assert.Equal(t, SegmentReason{Kind: SegmentReasonError}, result.Reason)
+		assert.Error(t, result.Err)
+	})
+}
@@ -0,0 +1,92 @@
+package evaluation
+
+import (
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// EvaluationStats describes how much work the evaluator did for an evaluation. See Result.Stats.
+//
+// The counts include the work done for prerequisite flags and for segments referenced by the flag, but
+// not any work that was avoided because a result was reused: a prerequisite flag that was already
+// evaluated earlier in the same evaluation, or earlier in the same EvaluateAll batch, is not evaluated
+// again. If a flag itself was already evaluated as a prerequisite earlier in an EvaluateAll batch, its
+// stats are those of that earlier evaluation.
+type EvaluationStats struct {
+	// RulesChecked is the number of flag rules and segment rules that were checked.
+	RulesChecked int
+	// ClausesEvaluated is the number of flag clauses and segment rule clauses that were evaluated.
+	ClausesEvaluated int
+	// RegexesExecuted is the number of times that a clause value was tested with the "matches" operator.
+	RegexesExecuted int
+	// SegmentsVisited is the number of times that a segment was evaluated.
+	SegmentsVisited int
+	// PrerequisitesEvaluated is the number of times that a prerequisite flag was evaluated.
+	PrerequisitesEvaluated int
+	// PrerequisiteDepth is the greatest depth of nested prerequisites that was reached: 0 if no
+	// prerequisite flags were evaluated, 1 if only the flag's own prerequisites were, and so on.
+	PrerequisiteDepth int
+	// BigSegmentQueries is the number of times that the evaluator queried the BigSegmentProvider.
+	BigSegmentQueries int
+	// Steps is the total amount of work that was counted against the step budget; see
+	// EvaluatorOptionStepBudget. Each rule, clause, segment, and prerequisite flag counts as one step,
+	// and so does each clause value that was compared with an operator other than "in", "inIgnoreCase",
+	// "containsAll", "containsNone", "ipInRange", or "ipEquals" (those operators look up the context
+	// value in all of the clause values at once).
+	Steps int
+}
+
+// countStep adds one step to the stats, and one to the specified counter in the stats, and returns an
+// error if that exceeds the step budget. It is called at every point where the evaluator can stop
+// early; steps that are counted elsewhere, such as clause value comparisons, are caught by the next
+// call to countStep. It does nothing if stats are not enabled; see EvaluatorOptionStats.
+func (es *evaluationScope) countStep(counter *int) error {
+	if !es.owner.countStats {
+		return nil
+	}
+	*counter++
+	es.stats.Steps++
+	if es.owner.stepBudget > 0 && es.stats.Steps > es.owner.stepBudget {
+		return StepBudgetExceededError(es.owner.stepBudget)
+	}
+	return nil
+}
+
+// countClauseValues adds a step for each clause value that was compared with the clause's operator.
+// These steps are not checked against the budget until the next call to countStep.
+func (es *evaluationScope) countClauseValues(op ldmodel.Operator, count int) {
+	if !es.owner.countStats {
+		return
+	}
+	es.stats.Steps += count
+	if op == ldmodel.OperatorMatches {
+		es.stats.RegexesExecuted += count
+	}
+}
+
+// beginNestedStats is called before evaluating a prerequisite flag at the specified depth, so that
+// endNestedStats can compute the stats that the prerequisite would have if it were evaluated on its own.
+// It returns the stats so far, which the caller passes to endNestedStats.
+func (es *evaluationScope) beginNestedStats(depth int) EvaluationStats {
+	before := es.stats
+	es.stats.PrerequisiteDepth = depth
+	return before
+}
+
+// endNestedStats returns the stats for a prerequisite flag that was evaluated at the specified depth,
+// and restores the greatest depth that was reached in the evaluation as a whole.
+func (es *evaluationScope) endNestedStats(before EvaluationStats, depth int) EvaluationStats {
+	nested := EvaluationStats{
+		RulesChecked:           es.stats.RulesChecked - before.RulesChecked,
+		ClausesEvaluated:       es.stats.ClausesEvaluated - before.ClausesEvaluated,
+		RegexesExecuted:        es.stats.RegexesExecuted - before.RegexesExecuted,
+		SegmentsVisited:        es.stats.SegmentsVisited - before.SegmentsVisited,
+		PrerequisitesEvaluated: es.stats.PrerequisitesEvaluated - before.PrerequisitesEvaluated,
+		PrerequisiteDepth:      es.stats.PrerequisiteDepth - depth,
+		BigSegmentQueries:      es.stats.BigSegmentQueries - before.BigSegmentQueries,
+		Steps:                  es.stats.Steps - before.Steps,
+	}
+	if before.PrerequisiteDepth > es.stats.PrerequisiteDepth {
+		es.stats.PrerequisiteDepth = before.PrerequisiteDepth
+	}
+	return nested
+}
@@ -0,0 +1,129 @@
+package evaluation
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	m "github.com/launchdarkly/go-test-helpers/v3/matchers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeFlagForStatsTest() (ldmodel.FeatureFlag, *simpleDataProvider) {
+	// The context matches the last rule. Along the way, the evaluator checks:
+	// - rule 0: a clause with two regexes, which do not match
+	// - rule 1: a segment match clause; the segment has one rule, whose clause does not match
+	// - rule 2: a clause that matches
+	// and the flag's prerequisite, which has a prerequisite of its own.
+	segment := ldbuilders.NewSegmentBuilder("segmentkey").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+			ldbuilders.Clause(ldattr.KeyAttr, ldmodel.OperatorIn, ldvalue.String("no")))).
+		Build()
+	prereq2 := ldbuilders.NewFlagBuilder("prereq2").On(true).FallthroughVariation(0).
+		Variations(ldvalue.Bool(true)).Build()
+	prereq1 := ldbuilders.NewFlagBuilder("prereq1").On(true).AddPrerequisite(prereq2.Key, 0).
+		FallthroughVariation(0).Variations(ldvalue.Bool(true)).Build()
+	flag := ldbuilders.NewFlagBuilder("feature").On(true).AddPrerequisite(prereq1.Key, 0).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(
+			ldbuilders.Clause(ldattr.KeyAttr, ldmodel.OperatorMatches, ldvalue.String("^a"), ldvalue.String("^b")))).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(ldbuilders.SegmentMatchClause(segment.Key))).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).Clauses(makeClauseToMatchAnyContextOfAnyKind())).
+		FallthroughVariation(0).Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	return flag, basicDataProvider().withStoredFlags(prereq1, prereq2).withStoredSegments(segment)
+}
+
+func TestEvaluationStats(t *testing.T) {
+	flag, provider := makeFlagForStatsTest()
+	result := NewEvaluatorWithOptions(provider, EvaluatorOptionStats(true)).Evaluate(&flag, flagTestContext, nil)
+
+	m.In(t).Assert(result, ResultDetailProps(1, ldvalue.Bool(true), ldreason.NewEvalReasonRuleMatch(2, "")))
+	assert.Equal(t, EvaluationStats{
+		RulesChecked:           4,
+		ClausesEvaluated:       4,
+		RegexesExecuted:        2,
+		SegmentsVisited:        1,
+		PrerequisitesEvaluated: 2,
+		PrerequisiteDepth:      2,
+		Steps:                  13, // 4 rules + 4 clauses + 1 segment + 2 prerequisites + 2 regex values
+	}, result.Stats)
+}
+
+func TestEvaluationStatsAreNotSetByDefault(t *testing.T) {
+	flag, provider := makeFlagForStatsTest()
+	result := NewEvaluator(provider).Evaluate(&flag, flagTestContext, nil)
+
+	m.In(t).Assert(result, ResultDetailProps(1, ldvalue.Bool(true), ldreason.NewEvalReasonRuleMatch(2, "")))
+	assert.Equal(t, EvaluationStats{}, result.Stats)
+}
+
+func TestEvaluationStatsCountBigSegmentQueries(t *testing.T) {
+	segment := ldbuilders.NewSegmentBuilder("big").Unbounded(true).Generation(1).Build()
+	flag := makeBooleanFlagToMatchAnyOfSegments(segment.Key)
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredSegments(segment),
+		EvaluatorOptionBigSegmentProvider(basicBigSegmentsProvider()), EvaluatorOptionStats(true))
+
+	result := evaluator.Evaluate(&flag, flagTestContext, nil)
+	assert.Equal(t, 1, result.Stats.BigSegmentQueries)
+	assert.Equal(t, 1, result.Stats.SegmentsVisited)
+}
+
+func TestStepBudget(t *testing.T) {
+	flag, provider := makeFlagForStatsTest()
+
+	t.Run("not exceeded", func(t *testing.T) {
+		evaluator := NewEvaluatorWithOptions(provider, EvaluatorOptionStepBudget(13), EvaluatorOptionStats(false))
+		result := evaluator.Evaluate(&flag, flagTestContext, nil)
+		m.In(t).Assert(result, ResultDetailProps(1, ldvalue.Bool(true), ldreason.NewEvalReasonRuleMatch(2, "")))
+		assert.Nil(t, result.Err)
+		assert.Equal(t, 13, result.Stats.Steps) // a step budget always enables stats
+	})
+
+	for _, p := range []struct {
+		name                   string
+		budget                 int
+		ruleIndex, clauseIndex int
+	}{
+		{"in prerequisite", 1, -1, -1},
+		{"at rule", 2, 0, -1},
+		{"at clause", 3, 0, 0},
+		{"in segment", 9, 1, 0},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			sink := &errorReportSink{}
+			evaluator := NewEvaluatorWithOptions(provider, EvaluatorOptionStepBudget(p.budget),
+				EvaluatorOptionErrorReporter(sink),
+				EvaluatorOptionDegradationPolicy(RuleErrorMalformedSegment, DegradationSkipRule))
+			result := evaluator.Evaluate(&flag, flagTestContext, nil)
+			m.In(t).Assert(result, ResultDetailError(EvalErrorStepBudgetExceeded))
+			assert.Equal(t, p.budget, result.Stats.Steps-1)
+			var evalErr EvaluationError
+			if assert.ErrorAs(t, result.Err, &evalErr) {
+				assert.Equal(t, StepBudgetExceededError(p.budget), evalErr.Err)
+				assert.Equal(t, p.ruleIndex, evalErr.RuleIndex)
+				assert.Equal(t, p.clauseIndex, evalErr.ClauseIndex)
+			}
+			assert.Len(t, sink.reports, 1)
+		})
+	}
+}
+
+func TestStepBudgetForSegmentsForContext(t *testing.T) {
+	segments := segmentList{
+		ldbuilders.NewSegmentBuilder("a").AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+			makeClauseToMatchAnyContextOfAnyKind())).Build(),
+		ldbuilders.NewSegmentBuilder("b").AddRule(ldbuilders.NewSegmentRuleBuilder().Clauses(
+			makeClauseToMatchAnyContextOfAnyKind())).Build(),
+	}
+	evaluator := NewEvaluatorWithOptions(basicDataProvider().withStoredSegments(segments...),
+		EvaluatorOptionStepBudget(3))
+
+	result := evaluator.(SegmentIndexEvaluator).SegmentsForContext(NewSegmentIndex(segments), ldcontext.New("x"))
+	assert.Equal(t, []string{"a", "b"}, result.SegmentKeys)
+	assert.Nil(t, result.Errors)
+}
@@ -0,0 +1,314 @@
+package evaluation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// TraceStepKind describes what kind of evaluation step a TraceStep represents.
+type TraceStepKind string
+
+const (
+	// TraceStepFlag is the evaluation of a feature flag: either the flag that was passed to
+	// ExplainingEvaluator.Explain, or a prerequisite flag. Its Key is the flag key, its Version is the flag
+	// version, and its Detail is the result of evaluating the flag.
+	TraceStepFlag TraceStepKind = "flag"
+	// TraceStepPrerequisite is the check of a single prerequisite. Its Key is the prerequisite flag
+	// key, its Variation is the variation index that the prerequisite flag must return, and Matched
+	// is true if the prerequisite was met. If the prerequisite flag exists, the step has a single
+	// child step of kind TraceStepFlag, unless the same flag was already evaluated earlier in the same
+	// evaluation, in which case the earlier result is reused and Note says so.
+	TraceStepPrerequisite TraceStepKind = "prerequisite"
+	// TraceStepTarget is the check of a single target list. Its ContextKind and Variation are those of
+	// the target, and Matched is true if the context's key was in the list.
+	TraceStepTarget TraceStepKind = "target"
+	// TraceStepRule is the check of a flag rule or segment rule. Its Index is the rule index and its Key
+	// is the rule ID. Its child steps are the clauses that were checked, and for a segment rule with a
+	// percentage rollout, the bucketing step.
+	TraceStepRule TraceStepKind = "rule"
+	// TraceStepClause is the check of a single clause. Its Index is the clause index within the rule;
+	// ContextKind, Attribute, Operator, Negate, and ClauseValues are copied from the clause; and
+	// ContextValue is the context attribute value that the clause values were compared to. For a
+	// segmentMatch clause, the child steps are the segments that were checked.
+	TraceStepClause TraceStepKind = "clause"
+	// TraceStepSegment is the check of whether the context is in a segment. Its Key is the segment key,
+	// Matched is true if the context is in the segment, and Note describes how that was determined.
+	// Its child steps are the segment rules that were checked.
+	TraceStepSegment TraceStepKind = "segment"
+	// TraceStepBucket is the computation of a bucket value for a percentage rollout or experiment.
+	// ContextKind and Attribute describe the context attribute that was hashed (ContextKind is never
+	// empty), BucketValue is the computed value in the range [0, 1), and BucketRangeStart and
+	// BucketRangeEnd are the bounds of the bucket it fell into. In a flag rollout, Variation is the
+	// variation index of that bucket. If the bucket value could not be computed, FailureReason says
+	// why, BucketValue is zero, and Err is set if the failure made the evaluation fail.
+	TraceStepBucket TraceStepKind = "bucket"
+)
+
+// TraceStep is a node in the tree of evaluation steps returned by ExplainingEvaluator.Explain.
+//
+// Which fields are meaningful depends on the Kind; see the TraceStepKind constants. Fields that do not
+// apply to a given kind have their zero values.
+type TraceStep struct {
+	Kind             TraceStepKind
+	Key              string
+	Version          int
+	Index            int
+	ContextKind      ldcontext.Kind
+	Attribute        ldattr.Ref
+	Operator         ldmodel.Operator
+	Negate           bool
+	ClauseValues     []ldvalue.Value
+	ContextValue     ldvalue.Value
+	Variation        ldvalue.OptionalInt
+	BucketValue      float32
+	BucketRangeStart float32
+	BucketRangeEnd   float32
+	Detail           ldreason.EvaluationDetail
+	Matched          bool
+	FailureReason    BucketingFailureReason
+	Note             string
+	Err              error
+	Children         []*TraceStep
+}
+
+// String returns a human-readable, multi-line description of this step and all of its child steps.
+func (s *TraceStep) String() string {
+	var b strings.Builder
+	s.writeTo(&b, 0)
+	return b.String()
+}
+
+func (s *TraceStep) writeTo(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(s.describe())
+	b.WriteString("\n")
+	for _, c := range s.Children {
+		c.writeTo(b, depth+1)
+	}
+}
+
+func (s *TraceStep) describe() string {
+	var desc string
+	switch s.Kind {
+	case TraceStepFlag:
+		return fmt.Sprintf("flag %q (version %d): value=%s, variation=%s, reason=%s",
+			s.Key, s.Version, s.Detail.Value.JSONString(), s.Detail.VariationIndex.String(), s.Detail.Reason)
+	case TraceStepPrerequisite:
+		desc = fmt.Sprintf("prerequisite %q must return variation %s", s.Key, s.Variation.String())
+	case TraceStepTarget:
+		desc = fmt.Sprintf("target list for kind %q (variation %s)", contextKindOrDefault(s.ContextKind),
+			s.Variation.String())
+	case TraceStepRule:
+		desc = fmt.Sprintf("rule %d (id %q)", s.Index, s.Key)
+	case TraceStepClause:
+		if s.Operator == ldmodel.OperatorSegmentMatch {
+			desc = fmt.Sprintf("clause %d: segmentMatch %s", s.Index, describeValues(s.ClauseValues))
+		} else {
+			not := ""
+			if s.Negate {
+				not = "not "
+			}
+			desc = fmt.Sprintf("clause %d: %s.%s %s%s %s, context value %s", s.Index,
+				contextKindOrDefault(s.ContextKind), s.Attribute, not, s.Operator, describeValues(s.ClauseValues),
+				s.ContextValue.JSONString())
+		}
+	case TraceStepSegment:
+		desc = fmt.Sprintf("segment %q", s.Key)
+	case TraceStepBucket:
+		desc = fmt.Sprintf("bucket by %s.%s: %f in [%f, %f)", contextKindOrDefault(s.ContextKind),
+			s.Attribute, s.BucketValue, s.BucketRangeStart, s.BucketRangeEnd)
+		if s.Variation.IsDefined() {
+			desc += fmt.Sprintf(" -> variation %d", s.Variation.IntValue())
+		}
+	default:
+		desc = string(s.Kind) // COVERAGE: unreachable
+	}
+	switch {
+	case s.Err != nil:
+		desc += ": error: " + s.Err.Error()
+	case s.Kind == TraceStepBucket:
+		if s.FailureReason != 0 {
+			desc += ": failed: " + describeBucketingFailure(s.FailureReason)
+		}
+	case s.Matched:
+		desc += ": matched"
+	default:
+		desc += ": not matched"
+	}
+	if s.Note != "" {
+		desc += " (" + s.Note + ")"
+	}
+	return desc
+}
+
+func describeValues(values []ldvalue.Value) string {
+	return ldvalue.CopyArbitraryValue(values).JSONString()
+}
+
+func describeBucketingFailure(reason BucketingFailureReason) string {
+	switch reason {
+	case BucketingFailureInvalidAttrRef:
+		return "invalid attribute reference"
+	case BucketingFailureContextLacksDesiredKind:
+		return "context does not have this kind"
+	case BucketingFailureAttributeNotFound:
+		return "attribute not found"
+	case BucketingFailureAttributeValueWrongType:
+		return "attribute value is not a string or integer"
+	default:
+		return fmt.Sprintf("reason %d", reason) // COVERAGE: unreachable
+	}
+}
+
+func contextKindOrDefault(kind ldcontext.Kind) ldcontext.Kind {
+	if kind == "" {
+		return ldcontext.DefaultKind
+	}
+	return kind
+}
+
+// evaluationTracer collects TraceSteps during an Explain call. An evaluationScope's tracer is nil for
+// a regular Evaluate call, so all of the tracing logic in the evaluator must check for that first.
+type evaluationTracer struct {
+	root *TraceStep
+	open []*TraceStep
+}
+
+// begin adds a step as a child of the step that is currently open, and makes it the open step.
+func (t *evaluationTracer) begin(step *TraceStep) *TraceStep {
+	if len(t.open) == 0 {
+		t.root = step
+	} else {
+		parent := t.open[len(t.open)-1]
+		parent.Children = append(parent.Children, step)
+	}
+	t.open = append(t.open, step)
+	return step
+}
+
+// end closes the step that was most recently opened with begin.
+func (t *evaluationTracer) end() {
+	t.open = t.open[:len(t.open)-1]
+}
+
+// add adds a step that has no children.
+func (t *evaluationTracer) add(step *TraceStep) {
+	t.begin(step)
+	t.end()
+}
+
+// note sets the Note property of the currently open step, if we are tracing.
+func (t *evaluationTracer) note(note string) {
+	if t != nil && len(t.open) != 0 {
+		t.open[len(t.open)-1].Note = note
+	}
+}
+
+// Implementation of the ExplainingEvaluator interface.
+func (e *evaluator) Explain(
+	flag *ldmodel.FeatureFlag,
+	context ldcontext.Context,
+	prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder,
+) (Result, *TraceStep) {
+	if context.Err() != nil {
+		// We don't call Evaluate here, because that would call the hooks.
+		result := invalidContextResult()
+		return result, &TraceStep{Kind: TraceStepFlag, Key: flag.Key, Version: flag.Version,
+			Detail: result.Detail, Err: context.Err()}
+	}
+	tracer := &evaluationTracer{}
+	result := e.evaluateFlag(flag, context, prerequisiteFlagEventRecorder, nil, tracer, nil)
+	// The root step was given the detail before the big segments status was added to the reason.
+	tracer.root.Detail = result.Detail
+	return result, tracer.root
+}
+
+func (es *evaluationScope) traceFlag(stack evaluationStack) (ldreason.EvaluationDetail, bool) {
+	step := es.extras.tracer.begin(&TraceStep{Kind: TraceStepFlag, Key: es.flag.Key, Version: es.flag.Version})
+	detail, ok := es.evaluate(stack)
+	step.Detail = detail
+	es.extras.tracer.end()
+	return detail, ok
+}
+
+func (es *evaluationScope) traceClause(
+	index int,
+	clause *ldmodel.Clause,
+	plan *clausePlan,
+	stack evaluationStack,
+) (bool, error) {
+	step := es.extras.tracer.begin(&TraceStep{
+		Kind:         TraceStepClause,
+		Index:        index,
+		ContextKind:  clause.ContextKind,
+		Attribute:    clause.Attribute,
+		Operator:     clause.Op,
+		Negate:       clause.Negate,
+		ClauseValues: clause.Values,
+	})
+	if clause.Op != ldmodel.OperatorSegmentMatch {
+		step.ContextValue = getClauseContextValueForTrace(clause, &es.context)
+	}
+	match, err := es.clauseMatchesContext(clause, plan, stack)
+	step.Matched, step.Err = match, err
+	es.extras.tracer.end()
+	return match, err
+}
+
+func (es *evaluationScope) traceSegment(
+	segment *ldmodel.Segment,
+	plan *segmentPlan,
+	stack evaluationStack,
+) (bool, error) {
+	step := es.extras.tracer.begin(&TraceStep{Kind: TraceStepSegment, Key: segment.Key, Version: segment.Version})
+	match, err := es.segmentContainsContext(segment, plan, stack)
+	step.Matched, step.Err = match, err
+	es.extras.tracer.end()
+	return match, err
+}
+
+func (es *evaluationScope) traceBucket(
+	contextKind ldcontext.Kind,
+	attribute ldattr.Ref,
+	bucketValue, rangeStart, rangeEnd float32,
+	variation ldvalue.OptionalInt,
+	failureReason BucketingFailureReason,
+	err error,
+) {
+	es.extras.tracer.add(&TraceStep{
+		Kind:             TraceStepBucket,
+		ContextKind:      contextKind,
+		Attribute:        attribute,
+		BucketValue:      bucketValue,
+		BucketRangeStart: rangeStart,
+		BucketRangeEnd:   rangeEnd,
+		Variation:        variation,
+		FailureReason:    failureReason,
+		Err:              err,
+	})
+}
+
+// getClauseContextValueForTrace repeats the attribute lookup that es.clauseMatchesContextNoSegments does,
+// so that we don't need to complicate that function in order to report the value in a trace.
+func getClauseContextValueForTrace(c *ldmodel.Clause, context *ldcontext.Context) ldvalue.Value {
+	if c.Attribute.String() == ldattr.KindAttr {
+		if !context.Multiple() {
+			return ldvalue.String(string(context.Kind()))
+		}
+		kinds := ldvalue.ArrayBuild()
+		for i := 0; i < context.IndividualContextCount(); i++ {
+			kinds.Add(ldvalue.String(string(context.IndividualContextByIndex(i).Kind())))
+		}
+		return kinds.Build()
+	}
+	if c.Attribute.Err() != nil {
+		return ldvalue.Null()
+	}
+	return context.IndividualContextByKind(c.ContextKind).GetValueForRef(c.Attribute)
+}
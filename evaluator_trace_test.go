@@ -0,0 +1,275 @@
+package evaluation
+
+import (
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainReturnsSameResultAsEvaluate(t *testing.T) {
+	f := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+	evaluator := basicEvaluator()
+
+	result, trace := evaluator.(ExplainingEvaluator).Explain(&f, flagTestContext, nil)
+
+	assert.Equal(t, evaluator.Evaluate(&f, flagTestContext, nil), result)
+	require.NotNil(t, trace)
+	assert.Equal(t, TraceStepFlag, trace.Kind)
+	assert.Equal(t, f.Key, trace.Key)
+	assert.Equal(t, result.Detail, trace.Detail)
+}
+
+func TestExplainWithInvalidContext(t *testing.T) {
+	f := makeFlagToMatchContext(flagTestContext, ldbuilders.Variation(2))
+
+	result, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, ldcontext.New(""), nil)
+
+	assert.Equal(t, ldreason.EvalErrorUserNotSpecified, result.Detail.Reason.GetErrorKind())
+	require.NotNil(t, trace)
+	assert.Error(t, trace.Err)
+	assert.Len(t, trace.Children, 0)
+}
+
+func TestExplainRecordsTargetsRulesAndClauses(t *testing.T) {
+	context := ldcontext.NewBuilder("userkey").SetString("email", "a@example.com").Build()
+	f := ldbuilders.NewFlagBuilder("feature").On(true).
+		AddTarget(0, "someoneelse").
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule0").Variation(0).
+			Clauses(ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@other.com")))).
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule1").Variation(1).
+			Clauses(
+				ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@example.com")),
+				ldbuilders.Negate(ldbuilders.Clause(ldattr.KindAttr, ldmodel.OperatorIn, ldvalue.String("org"))),
+			)).
+		FallthroughVariation(0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+
+	result, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, context, nil)
+
+	assert.Equal(t, ldreason.NewEvalReasonRuleMatch(1, "rule1"), result.Detail.Reason)
+	require.Len(t, trace.Children, 3)
+
+	target := trace.Children[0]
+	assert.Equal(t, TraceStepTarget, target.Kind)
+	assert.Equal(t, ldvalue.NewOptionalInt(0), target.Variation)
+	assert.False(t, target.Matched)
+
+	rule0 := trace.Children[1]
+	assert.Equal(t, TraceStepRule, rule0.Kind)
+	assert.Equal(t, "rule0", rule0.Key)
+	assert.False(t, rule0.Matched)
+	require.Len(t, rule0.Children, 1)
+	assert.Equal(t, ldvalue.String("a@example.com"), rule0.Children[0].ContextValue)
+	assert.False(t, rule0.Children[0].Matched)
+
+	rule1 := trace.Children[2]
+	assert.Equal(t, 1, rule1.Index)
+	assert.True(t, rule1.Matched)
+	require.Len(t, rule1.Children, 2)
+	assert.Equal(t, TraceStepClause, rule1.Children[0].Kind)
+	assert.Equal(t, ldmodel.OperatorEndsWith, rule1.Children[0].Operator)
+	assert.Equal(t, []ldvalue.Value{ldvalue.String("@example.com")}, rule1.Children[0].ClauseValues)
+	assert.True(t, rule1.Children[0].Matched)
+	assert.Equal(t, 1, rule1.Children[1].Index)
+	assert.True(t, rule1.Children[1].Negate)
+	assert.Equal(t, ldvalue.String("user"), rule1.Children[1].ContextValue)
+	assert.True(t, rule1.Children[1].Matched)
+}
+
+func TestExplainRecordsPrerequisites(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).OffVariation(1).
+		AddPrerequisite("feature1", 1).
+		AddPrerequisite("feature2", 1).
+		FallthroughVariation(0).
+		Variations(fallthroughValue, offValue, onValue).
+		Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).Version(3).FallthroughVariation(1).
+		Variations(ldvalue.String("nogo"), ldvalue.String("go")).Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredFlags(f1).withNonexistentFlag("feature2"))
+
+	eventSink := prereqEventSink{}
+	result, trace := evaluator.(ExplainingEvaluator).Explain(&f0, flagTestContext, eventSink.record)
+
+	assert.Equal(t, ldreason.NewEvalReasonPrerequisiteFailed("feature2"), result.Detail.Reason)
+	assert.Len(t, eventSink.events, 1)
+	require.Len(t, trace.Children, 2)
+
+	prereq1 := trace.Children[0]
+	assert.Equal(t, TraceStepPrerequisite, prereq1.Kind)
+	assert.Equal(t, "feature1", prereq1.Key)
+	assert.True(t, prereq1.Matched)
+	require.Len(t, prereq1.Children, 1)
+	assert.Equal(t, TraceStepFlag, prereq1.Children[0].Kind)
+	assert.Equal(t, 3, prereq1.Children[0].Version)
+	assert.Equal(t, ldvalue.String("go"), prereq1.Children[0].Detail.Value)
+
+	prereq2 := trace.Children[1]
+	assert.Equal(t, "feature2", prereq2.Key)
+	assert.False(t, prereq2.Matched)
+	assert.Equal(t, "flag not found", prereq2.Note)
+	assert.Len(t, prereq2.Children, 0)
+}
+
+func TestExplainRecordsCircularPrerequisite(t *testing.T) {
+	f0 := ldbuilders.NewFlagBuilder("feature0").On(true).AddPrerequisite("feature1", 0).
+		Variations(ldvalue.Bool(true)).FallthroughVariation(0).Build()
+	f1 := ldbuilders.NewFlagBuilder("feature1").On(true).AddPrerequisite("feature0", 0).
+		Variations(ldvalue.Bool(true)).FallthroughVariation(0).Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredFlags(f0, f1))
+
+	result, trace := evaluator.(ExplainingEvaluator).Explain(&f0, flagTestContext, nil)
+
+	assert.Equal(t, ldreason.EvalErrorMalformedFlag, result.Detail.Reason.GetErrorKind())
+	nested := trace.Children[0].Children[0].Children[0]
+	assert.Equal(t, TraceStepPrerequisite, nested.Kind)
+	require.Len(t, nested.Children, 1)
+	assert.Equal(t, CircularPrereqReferenceError("feature0"), nested.Children[0].Err)
+}
+
+func TestExplainRecordsSegmentsAndBucketing(t *testing.T) {
+	context := ldcontext.NewBuilder("userkey").SetString("country", "us").Build()
+	segment0 := ldbuilders.NewSegmentBuilder("segment0").Excluded("userkey").Build()
+	segment1 := ldbuilders.NewSegmentBuilder("segment1").
+		AddRule(ldbuilders.NewSegmentRuleBuilder().ID("srule").Weight(100000).
+			Clauses(ldbuilders.Clause("country", ldmodel.OperatorIn, ldvalue.String("us")))).
+		Build()
+	f := ldbuilders.NewFlagBuilder("feature").On(true).
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule").
+			VariationOrRollout(ldbuilders.Rollout(ldbuilders.Bucket(0, 0), ldbuilders.Bucket(1, 100000))).
+			Clauses(ldbuilders.SegmentMatchClause("segment0", "segment1"))).
+		FallthroughVariation(0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+	evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segment0, segment1))
+
+	result, trace := evaluator.(ExplainingEvaluator).Explain(&f, context, nil)
+
+	assert.Equal(t, ldvalue.Bool(true), result.Detail.Value)
+	require.Len(t, trace.Children, 2)
+	clause := trace.Children[0].Children[0]
+	assert.Equal(t, ldmodel.OperatorSegmentMatch, clause.Operator)
+	require.Len(t, clause.Children, 2)
+
+	assert.Equal(t, TraceStepSegment, clause.Children[0].Kind)
+	assert.Equal(t, "segment0", clause.Children[0].Key)
+	assert.False(t, clause.Children[0].Matched)
+	assert.Equal(t, "excluded", clause.Children[0].Note)
+
+	seg1 := clause.Children[1]
+	assert.True(t, seg1.Matched)
+	require.Len(t, seg1.Children, 1)
+	segRule := seg1.Children[0]
+	assert.Equal(t, "srule", segRule.Key)
+	require.Len(t, segRule.Children, 2)
+	assert.Equal(t, TraceStepClause, segRule.Children[0].Kind)
+	assert.Equal(t, TraceStepBucket, segRule.Children[1].Kind)
+	assert.Equal(t, float32(1), segRule.Children[1].BucketRangeEnd)
+
+	bucket := trace.Children[1]
+	assert.Equal(t, TraceStepBucket, bucket.Kind)
+	assert.Equal(t, ldattr.NewLiteralRef(ldattr.KeyAttr), bucket.Attribute)
+	assert.Equal(t, ldvalue.NewOptionalInt(1), bucket.Variation)
+	assert.Equal(t, float32(0), bucket.BucketRangeStart)
+	assert.Equal(t, float32(1), bucket.BucketRangeEnd)
+	assert.True(t, bucket.BucketValue >= bucket.BucketRangeStart && bucket.BucketValue < bucket.BucketRangeEnd)
+}
+
+func TestExplainRecordsBucketingFailures(t *testing.T) {
+	makeFlag := func(contextKind ldcontext.Kind, bucketBy ldattr.Ref) ldmodel.FeatureFlag {
+		vr := ldbuilders.Rollout(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000))
+		vr.Rollout.ContextKind, vr.Rollout.BucketBy = contextKind, bucketBy
+		return ldbuilders.NewFlagBuilder("feature").On(true).Fallthrough(vr).
+			Variations(ldvalue.Bool(false), ldvalue.Bool(true)).Build()
+	}
+
+	t.Run("invalid bucketBy", func(t *testing.T) {
+		f := makeFlag("", ldattr.NewRef("///"))
+		result, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, ldcontext.New("userkey"), nil)
+
+		assert.Equal(t, ldreason.EvalErrorMalformedFlag, result.Detail.Reason.GetErrorKind())
+		require.Len(t, trace.Children, 1)
+		bucket := trace.Children[0]
+		assert.Equal(t, TraceStepBucket, bucket.Kind)
+		assert.Equal(t, ldcontext.DefaultKind, bucket.ContextKind)
+		assert.Equal(t, BucketingFailureInvalidAttrRef, bucket.FailureReason)
+		assert.Equal(t, BadAttrRefError("///"), bucket.Err)
+	})
+
+	t.Run("context lacks kind", func(t *testing.T) {
+		f := makeFlag("org", ldattr.Ref{})
+		_, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, ldcontext.New("userkey"), nil)
+
+		require.Len(t, trace.Children, 1)
+		bucket := trace.Children[0]
+		assert.Equal(t, ldcontext.Kind("org"), bucket.ContextKind)
+		assert.Equal(t, BucketingFailureContextLacksDesiredKind, bucket.FailureReason)
+		assert.Equal(t, float32(0), bucket.BucketValue)
+		assert.Equal(t, ldvalue.NewOptionalInt(0), bucket.Variation)
+		assert.Nil(t, bucket.Err)
+	})
+
+	t.Run("attribute not found", func(t *testing.T) {
+		f := makeFlag("", ldattr.NewRef("group"))
+		_, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, ldcontext.New("userkey"), nil)
+
+		require.Len(t, trace.Children, 1)
+		bucket := trace.Children[0]
+		assert.Equal(t, ldcontext.DefaultKind, bucket.ContextKind)
+		assert.Equal(t, ldattr.NewRef("group"), bucket.Attribute)
+		assert.Equal(t, BucketingFailureAttributeNotFound, bucket.FailureReason)
+		assert.Equal(t, "bucket by user.group: 0.000000 in [0.000000, 0.500000) -> variation 0: "+
+			"failed: attribute not found", bucket.describe())
+	})
+
+	t.Run("segment rule context lacks kind", func(t *testing.T) {
+		segment := ldbuilders.NewSegmentBuilder("segment").
+			AddRule(ldbuilders.NewSegmentRuleBuilder().ID("srule").Weight(100000).RolloutContextKind("org").
+				Clauses(ldbuilders.Clause("key", ldmodel.OperatorIn, ldvalue.String("userkey")))).
+			Build()
+		f := ldbuilders.NewFlagBuilder("feature").On(true).
+			AddRule(ldbuilders.NewRuleBuilder().ID("rule").Variation(1).
+				Clauses(ldbuilders.SegmentMatchClause("segment"))).
+			FallthroughVariation(0).
+			Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+			Build()
+		evaluator := NewEvaluator(basicDataProvider().withStoredSegments(segment))
+
+		result, trace := evaluator.(ExplainingEvaluator).Explain(&f, ldcontext.New("userkey"), nil)
+
+		assert.Equal(t, ldvalue.Bool(false), result.Detail.Value)
+		segRule := trace.Children[0].Children[0].Children[0].Children[0]
+		assert.Equal(t, "srule", segRule.Key)
+		require.Len(t, segRule.Children, 2)
+		bucket := segRule.Children[1]
+		assert.Equal(t, TraceStepBucket, bucket.Kind)
+		assert.Equal(t, ldcontext.Kind("org"), bucket.ContextKind)
+		assert.Equal(t, BucketingFailureContextLacksDesiredKind, bucket.FailureReason)
+	})
+}
+
+func TestTraceStepString(t *testing.T) {
+	context := ldcontext.NewBuilder("userkey").SetString("email", "a@example.com").Build()
+	f := ldbuilders.NewFlagBuilder("feature").On(true).Version(2).
+		AddRule(ldbuilders.NewRuleBuilder().ID("rule0").Variation(1).
+			Clauses(ldbuilders.Clause("email", ldmodel.OperatorEndsWith, ldvalue.String("@example.com")))).
+		FallthroughVariation(0).
+		Variations(ldvalue.Bool(false), ldvalue.Bool(true)).
+		Build()
+
+	_, trace := basicEvaluator().(ExplainingEvaluator).Explain(&f, context, nil)
+
+	expected := `flag "feature" (version 2): value=true, variation=1, reason=RULE_MATCH(0,rule0)
+  rule 0 (id "rule0"): matched
+    clause 0: user.email endsWith ["@example.com"], context value "a@example.com": matched
+`
+	assert.Equal(t, expected, trace.String())
+}
@@ -9,6 +9,7 @@ require (
 	github.com/launchdarkly/go-test-helpers/v3 v3.0.2
 	github.com/mailru/easyjson v0.7.7
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/text v0.14.0
 )
 
 require (
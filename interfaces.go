@@ -1,6 +1,8 @@
 package evaluation
 
 import (
+	"time"
+
 	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
@@ -18,7 +20,8 @@ type Evaluator interface {
 	// The evaluator does not know anything about analytics events; generating any appropriate analytics
 	// events is the responsibility of the caller, who can also provide a callback in prerequisiteFlagEventRecorder
 	// to be notified if any additional evaluations were done due to prerequisites. The prerequisiteFlagEventRecorder
-	// parameter can be nil if you do not need to track prerequisite evaluations.
+	// parameter can be nil if you do not need to track prerequisite evaluations. See PrerequisiteFlagEventRecorder
+	// for details of when it is called.
 	Evaluate(
 		flag *ldmodel.FeatureFlag,
 		context ldcontext.Context,
@@ -26,8 +29,141 @@ type Evaluator interface {
 	) Result
 }
 
+// BatchEvaluator is an Evaluator that can also evaluate every feature flag at once. The Evaluator
+// returned by NewEvaluator or NewEvaluatorWithOptions always implements this interface.
+type BatchEvaluator interface {
+	Evaluator
+
+	// EvaluateAll evaluates every feature flag known to the DataProvider for the specified context.
+	//
+	// This requires the Evaluator's DataProvider to also implement EnumerableDataProvider; if it does
+	// not, EvaluateAll returns a nil map and DataProviderNotEnumerableError. Flags that are deleted, or
+	// that are rejected by the filter, are skipped. The filter parameter can be nil if all flags should
+	// be evaluated.
+	//
+	// The return value is a map of flag keys to evaluation results. Each result is the same as what
+	// Evaluate would have returned for that flag, but work that is common to more than one flag is
+	// only done once for the whole batch: a prerequisite flag that is referenced by several flags is
+	// only evaluated once, and big segment memberships are only queried once per context key.
+	//
+	// No prerequisite events are reported by this method, since it is intended for use cases such as
+	// bootstrapping where the caller does not generate analytics events for each flag.
+	EvaluateAll(context ldcontext.Context, filter FlagFilter) (map[string]Result, error)
+}
+
+// ExplainingEvaluator is an Evaluator that can also describe how it arrived at a result. The Evaluator
+// returned by NewEvaluator or NewEvaluatorWithOptions always implements this interface.
+type ExplainingEvaluator interface {
+	Evaluator
+
+	// Explain evaluates a feature flag in the same way as Evaluate, but also records every step of the
+	// evaluation and returns them as a tree of TraceSteps. The root step describes the flag itself; its
+	// descendants describe each prerequisite, target list, rule, clause, segment, and bucketing
+	// computation that the evaluator looked at, in the order that it looked at them.
+	//
+	// This is meant for diagnosing unexpected evaluation results. It is much slower than Evaluate, so
+	// it should not be used for ordinary flag evaluations.
+	Explain(
+		flag *ldmodel.FeatureFlag,
+		context ldcontext.Context,
+		prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder,
+	) (Result, *TraceStep)
+}
+
+// SegmentEvaluator is an Evaluator that can also evaluate a segment by itself. The Evaluator returned by
+// NewEvaluator or NewEvaluatorWithOptions always implements this interface.
+type SegmentEvaluator interface {
+	Evaluator
+
+	// EvaluateSegment determines whether a context is a member of a segment, and why.
+	//
+	// This uses the same logic that the evaluator uses for a segmentMatch clause in a flag rule,
+	// including any big segments query and any segments that are referenced by the segment's rules.
+	// A circular reference between segments causes an error, rather than a non-match. See
+	// SegmentResult.
+	//
+	// The segment is passed by reference only for efficiency; the evaluator will never modify any
+	// segment properties. Passing a nil segment will result in a panic.
+	EvaluateSegment(segment *ldmodel.Segment, context ldcontext.Context) SegmentResult
+}
+
+// SegmentIndexEvaluator is an Evaluator that can also find all of the segments that a context is a
+// member of. The Evaluator returned by NewEvaluator or NewEvaluatorWithOptions always implements this
+// interface.
+type SegmentIndexEvaluator interface {
+	Evaluator
+
+	// SegmentsForContext finds all of the segments in a SegmentIndex that a context is a member of.
+	//
+	// Each segment is evaluated in the same way as SegmentEvaluator.EvaluateSegment, but only if the
+	// index shows that the context might be a member of it; see SegmentIndex. Any segments that are
+	// referenced by segment rules are retrieved from the evaluator's DataProvider, as usual. If the
+	// context is invalid, no segments are evaluated, and the result's Err is the context's error.
+	SegmentsForContext(index *SegmentIndex, context ldcontext.Context) ContextSegments
+}
+
+// PreparingEvaluator is an Evaluator that can also prepare a flag for repeated evaluations. The
+// Evaluator returned by NewEvaluator or NewEvaluatorWithOptions always implements this interface.
+type PreparingEvaluator interface {
+	Evaluator
+
+	// Prepare creates a PreparedFlag, which can evaluate the specified feature flag repeatedly without
+	// redoing work that does not depend on the context.
+	//
+	// The evaluator resolves all of the prerequisite flags and segments that the flag references,
+	// directly or indirectly, by querying the DataProvider once at this time. It also compiles each
+	// clause ahead of time, parsing any numeric, date, semantic version, or regex clause values once
+	// rather than converting them on every test. This mostly benefits clauses with many values; a
+	// flag whose clauses each have a single value is evaluated at about the same speed as by Evaluate.
+	// The PreparedFlag does not query the DataProvider again, so it is a snapshot of the current data;
+	// see PreparedFlag.IsStale.
+	//
+	// The flag is passed by reference only for efficiency; the evaluator will never modify any flag
+	// properties. Passing a nil flag will result in a panic.
+	Prepare(flag *ldmodel.FeatureFlag) PreparedFlag
+}
+
+// PreparedFlag is a feature flag that has been prepared for evaluation by PreparingEvaluator.Prepare.
+//
+// Evaluating a PreparedFlag always produces the same result as calling Evaluator.Evaluate with the
+// same flag, as long as the flag and everything it references are unchanged in the DataProvider.
+// If the caller's data may have changed, it should check IsStale and, if that returns true, discard
+// the PreparedFlag and call PreparingEvaluator.Prepare again.
+//
+// It is safe to use a PreparedFlag concurrently from multiple goroutines.
+type PreparedFlag interface {
+	// Flag returns the flag that was passed to PreparingEvaluator.Prepare.
+	Flag() *ldmodel.FeatureFlag
+
+	// Evaluate evaluates the flag for the specified context. The parameters have the same meaning as
+	// for Evaluator.Evaluate.
+	Evaluate(context ldcontext.Context, prerequisiteFlagEventRecorder PrerequisiteFlagEventRecorder) Result
+
+	// IsStale returns true if the flag, or any of the prerequisite flags or segments that were
+	// resolved when the flag was prepared, now has a different version in the DataProvider, or has
+	// been added or removed.
+	//
+	// This queries the DataProvider for every one of those items, so it should be called when the
+	// caller knows that its data has been updated, rather than before every evaluation.
+	IsStale() bool
+}
+
+// FlagFilter is a function that BatchEvaluator.EvaluateAll() calls to determine whether a flag should be
+// included in the results. It should return true to include the flag.
+//
+// The flag is passed by reference for efficiency only; the filter must not modify the flag's properties.
+type FlagFilter func(flag *ldmodel.FeatureFlag) bool
+
 // PrerequisiteFlagEventRecorder is a function that Evaluator.Evaluate() will call to record the
 // result of a prerequisite flag evaluation.
+//
+// Within a single evaluation, each prerequisite flag is evaluated at most once, no matter how many
+// flags in the dependency graph refer to it; the result is remembered and reused. The recorder is
+// called once for each prerequisite of each flag that was evaluated, in the order in which the
+// prerequisites were checked. So, if flag A has prerequisites B and C, and both B and C have
+// prerequisite D, the recorder is called for D with TargetFlagKey B, for B with TargetFlagKey A, for
+// D with TargetFlagKey C (with the same result as before, since D is not evaluated again), and for C
+// with TargetFlagKey A. If D had any prerequisites of its own, they would only be reported once.
 type PrerequisiteFlagEventRecorder func(PrerequisiteFlagEvent)
 
 // PrerequisiteFlagEvent is the parameter data passed to PrerequisiteFlagEventRecorder.
@@ -73,6 +209,95 @@ type DataProvider interface {
 	GetSegment(key string) *ldmodel.Segment
 }
 
+// EnumerableDataProvider is a DataProvider that is also able to list all of the feature flags in the
+// data store. This is required for BatchEvaluator.EvaluateAll().
+type EnumerableDataProvider interface {
+	DataProvider
+	// GetAllFeatureFlags returns all of the feature flags in the data store, in any order.
+	//
+	// The DataProvider may either omit deleted flags or return their placeholders; the evaluator
+	// skips any flag whose Deleted property is true.
+	GetAllFeatureFlags() []*ldmodel.FeatureFlag
+}
+
+// ErrorReporter receives information about errors that caused evaluations to fail, such as a malformed
+// flag. The caller provides an implementation of this interface to EvaluatorOptionErrorReporter.
+//
+// ReportError is called synchronously during an evaluation, every time the error happens, so it should
+// be fast, and it must be safe to call concurrently from multiple goroutines. See
+// NewRateLimitedErrorReporter for an implementation that avoids logging the same error repeatedly.
+type ErrorReporter interface {
+	ReportError(report ErrorReport)
+}
+
+// ErrorReport is the parameter data passed to ErrorReporter.
+type ErrorReport struct {
+	// FlagKey and FlagVersion identify the flag where the error was found. This may be a prerequisite
+	// of the flag that was being evaluated.
+	FlagKey     string
+	FlagVersion int
+	// RuleIndex is the index of the flag rule where the error was found, or -1 if it was not in a rule.
+	RuleIndex int
+	// ClauseIndex is the index of the clause within that rule where the error was found, or -1 if it
+	// was not in a clause.
+	ClauseIndex int
+	// ErrorKind is the error kind that is reported in the evaluation reason.
+	ErrorKind ldreason.EvalErrorKind
+	// Err is the underlying error, such as BadVariationError. This is the same as the Err property of
+	// the EvaluationError in Result.Err.
+	Err error
+}
+
+// EvaluationHook receives notifications before and after each flag evaluation, for purposes such as
+// metrics and auditing. The caller provides implementations of this interface to
+// EvaluatorOptionHooks.
+//
+// The hook is called for Evaluator.Evaluate, BatchEvaluator.EvaluateAll, and PreparedFlag.Evaluate,
+// even if the context is invalid, but not for ExplainingEvaluator.Explain. It is also called for every
+// prerequisite flag evaluation that is reported to the PrerequisiteFlagEventRecorder, regardless of
+// whether there is a recorder; in that case EvaluationHookInfo.PrerequisiteOf is set. The prerequisite
+// evaluations happen after the BeforeEvaluation call for the flag that depends on them, and before its
+// AfterEvaluation call.
+//
+// The methods are called synchronously during the evaluation, so they should be fast, and they must
+// be safe to call concurrently from multiple goroutines.
+type EvaluationHook interface {
+	// BeforeEvaluation is called before the flag is evaluated. The return value, which can be
+	// anything, is passed to AfterEvaluation for the same evaluation; this allows the hook to keep
+	// state for each evaluation without any synchronization.
+	BeforeEvaluation(info EvaluationHookInfo) interface{}
+
+	// AfterEvaluation is called after the flag is evaluated, with the value that BeforeEvaluation
+	// returned, the result, and the time that the evaluation took. For a prerequisite, the result is
+	// the same as in the PrerequisiteFlagEvent.
+	AfterEvaluation(info EvaluationHookInfo, data interface{}, result Result, duration time.Duration)
+}
+
+// EvaluationHookInfo is the parameter data passed to EvaluationHook.
+type EvaluationHookInfo struct {
+	// FlagKey and FlagVersion identify the flag that is being evaluated.
+	FlagKey     string
+	FlagVersion int
+	// ContextKinds are the kinds of the evaluation context: a single kind, or the kinds of all of the
+	// individual contexts in a multi-kind context. It is nil if the context is invalid. The slice is
+	// shared by every call for the same evaluation, so the hook must not modify it.
+	ContextKinds []ldcontext.Kind
+	// PrerequisiteOf is the key of the flag that this flag is a prerequisite of, if this is a
+	// prerequisite evaluation. It is empty otherwise.
+	PrerequisiteOf string
+}
+
+// CachingBigSegmentProvider is the BigSegmentProvider returned by NewCachingBigSegmentProvider.
+type CachingBigSegmentProvider interface {
+	BigSegmentProvider
+
+	// Close stops the provider from starting any more background refreshes (see
+	// BigSegmentCacheOptionStaleWhileRevalidate), and waits for any that are in progress to finish.
+	// The provider can still be used afterward, but it never returns an expired result; a query for an
+	// expired key waits for the underlying provider. Close does not close the underlying provider.
+	Close()
+}
+
 // BigSegmentProvider is an abstraction for querying membership in big segments. The caller
 // provides an implementation of this interface to NewEvaluatorWithBigSegments.
 type BigSegmentProvider interface {
@@ -108,6 +333,9 @@ type BigSegmentProvider interface {
 // was called. Calling CheckMembership should not cause the state to be queried again. The object
 // should be safe for concurrent access by multiple goroutines.
 //
+// NewMapBigSegmentMembership, NewSortedBigSegmentMembership, and NewBloomBigSegmentMembership
+// provide ready-made implementations.
+//
 // This interface also exists in go-server-sdk because it is exposed as part of the public SDK API;
 // users can write their own implementations of SDK components, but we do not want application code
 // to reference go-server-sdk-evaluation symbols directly as part of that, because this library is
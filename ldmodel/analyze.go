@@ -0,0 +1,333 @@
+package ldmodel
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DataKind identifies whether a DataItemRef refers to a flag or a segment.
+type DataKind string
+
+const (
+	// DataKindFlag means that a DataItemRef refers to a FeatureFlag.
+	DataKindFlag DataKind = "flag"
+	// DataKindSegment means that a DataItemRef refers to a Segment.
+	DataKindSegment DataKind = "segment"
+)
+
+// DataItemRef identifies a flag or segment in a data set.
+type DataItemRef struct {
+	Kind DataKind
+	Key  string
+}
+
+// String returns a description of the item such as `flag "key"`.
+func (r DataItemRef) String() string {
+	return fmt.Sprintf("%s %q", r.Kind, r.Key)
+}
+
+// DanglingReference describes a reference from one item in a data set to another item that does not
+// exist. See AnalyzeDataSet.
+type DanglingReference struct {
+	// From is the flag or segment that contains the reference.
+	From DataItemRef
+	// Path is the location of the reference within From, in the same format as ValidationFinding.Path:
+	// for instance, "/prerequisites/0/key" or "/rules/1/clauses/0/values/2".
+	Path string
+	// To is the flag or segment that does not exist.
+	To DataItemRef
+}
+
+// DataSetAnalysis is the result of AnalyzeDataSet.
+type DataSetAnalysis struct {
+	// DanglingReferences lists every prerequisite or segmentMatch reference to a flag or segment that
+	// is not in the data set. An evaluation treats a missing prerequisite as a failed prerequisite,
+	// and a missing segment as a non-match.
+	DanglingReferences []DanglingReference
+	// Cycles lists every circular chain of prerequisites or of segment references. Each cycle starts
+	// and ends with the same item, so a flag that is a prerequisite of itself is reported as
+	// [flag "a", flag "a"]. If several cycles overlap, only one of them is reported for each group of
+	// items that refer to each other. An evaluation that encounters a cycle returns a MALFORMED_FLAG
+	// error.
+	Cycles [][]DataItemRef
+	// Order lists the items in dependency order: each item comes after every item it refers to.
+	// Items that are part of a cycle, or that refer directly or indirectly to an item in a cycle,
+	// are omitted.
+	Order []DataItemRef
+}
+
+// HasProblems returns true if there are any dangling references or cycles.
+func (a DataSetAnalysis) HasProblems() bool {
+	return len(a.DanglingReferences) != 0 || len(a.Cycles) != 0
+}
+
+type dataSetGraph struct {
+	nodes []DataItemRef
+	index map[DataItemRef]int
+	edges [][]int
+}
+
+// AnalyzeDataSet checks the references between all of the flags and segments in a data set, such as
+// the full contents of a data store. It reports prerequisites and segmentMatch clauses that refer to
+// nonexistent items, circular references, and an order in which the items could be stored so that
+// nothing is stored before the items it depends on.
+//
+// Nil items and items whose Deleted property is true are treated as nonexistent. All of the results
+// are sorted deterministically by kind and key.
+func AnalyzeDataSet(flags []*FeatureFlag, segments []*Segment) DataSetAnalysis {
+	var ret DataSetAnalysis
+	g := dataSetGraph{index: make(map[DataItemRef]int)}
+	liveFlags := make([]*FeatureFlag, 0, len(flags))
+	liveSegments := make([]*Segment, 0, len(segments))
+	for _, f := range flags {
+		if f != nil && !f.Deleted {
+			liveFlags = append(liveFlags, f)
+			g.addNode(DataItemRef{DataKindFlag, f.Key})
+		}
+	}
+	for _, s := range segments {
+		if s != nil && !s.Deleted {
+			liveSegments = append(liveSegments, s)
+			g.addNode(DataItemRef{DataKindSegment, s.Key})
+		}
+	}
+	g.edges = make([][]int, len(g.nodes))
+
+	addReference := func(from DataItemRef, path string, to DataItemRef) {
+		if toIndex, ok := g.index[to]; ok {
+			g.edges[g.index[from]] = append(g.edges[g.index[from]], toIndex)
+		} else {
+			ret.DanglingReferences = append(ret.DanglingReferences, DanglingReference{From: from, Path: path, To: to})
+		}
+	}
+	addClauseReferences := func(from DataItemRef, rulePath string, clauses []Clause) {
+		for i, c := range clauses {
+			if c.Op != OperatorSegmentMatch {
+				continue
+			}
+			for j, v := range c.Values {
+				if v.IsString() {
+					addReference(from, fmt.Sprintf("%s/clauses/%d/values/%d", rulePath, i, j),
+						DataItemRef{DataKindSegment, v.StringValue()})
+				}
+			}
+		}
+	}
+	for _, f := range liveFlags {
+		from := DataItemRef{DataKindFlag, f.Key}
+		for i, p := range f.Prerequisites {
+			addReference(from, fmt.Sprintf("/prerequisites/%d/key", i), DataItemRef{DataKindFlag, p.Key})
+		}
+		for i, r := range f.Rules {
+			addClauseReferences(from, fmt.Sprintf("/rules/%d", i), r.Clauses)
+		}
+	}
+	for _, s := range liveSegments {
+		from := DataItemRef{DataKindSegment, s.Key}
+		for i, r := range s.Rules {
+			addClauseReferences(from, fmt.Sprintf("/rules/%d", i), r.Clauses)
+		}
+	}
+	sort.SliceStable(ret.DanglingReferences, func(i, j int) bool {
+		return dataItemRefLess(ret.DanglingReferences[i].From, ret.DanglingReferences[j].From)
+	})
+
+	g.sortNodes()
+	inCycle := make([]bool, len(g.nodes))
+	for _, component := range g.stronglyConnectedComponents() {
+		if len(component) == 1 && !g.hasEdge(component[0], component[0]) {
+			continue
+		}
+		for _, n := range component {
+			inCycle[n] = true
+		}
+		ret.Cycles = append(ret.Cycles, g.findCycle(component))
+	}
+	sort.Slice(ret.Cycles, func(i, j int) bool { return dataItemRefLess(ret.Cycles[i][0], ret.Cycles[j][0]) })
+
+	ret.Order = g.dependencyOrder(inCycle)
+	return ret
+}
+
+func dataItemRefLess(a, b DataItemRef) bool {
+	if a.Kind != b.Kind {
+		return a.Kind == DataKindFlag
+	}
+	return a.Key < b.Key
+}
+
+func (g *dataSetGraph) addNode(ref DataItemRef) {
+	if _, ok := g.index[ref]; ok {
+		return // duplicate key; the first one wins
+	}
+	g.index[ref] = len(g.nodes)
+	g.nodes = append(g.nodes, ref)
+}
+
+// sortNodes renumbers the nodes in sorted order, and sorts and deduplicates each node's edges, so
+// that all of the graph traversals produce deterministic results.
+func (g *dataSetGraph) sortNodes() {
+	order := make([]int, len(g.nodes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return dataItemRefLess(g.nodes[order[i]], g.nodes[order[j]]) })
+	newIndex := make([]int, len(order))
+	for newPos, oldPos := range order {
+		newIndex[oldPos] = newPos
+	}
+	nodes := make([]DataItemRef, len(g.nodes))
+	edges := make([][]int, len(g.nodes))
+	for oldPos, ref := range g.nodes {
+		n := newIndex[oldPos]
+		nodes[n] = ref
+		g.index[ref] = n
+		seen := make(map[int]bool, len(g.edges[oldPos]))
+		for _, e := range g.edges[oldPos] {
+			if !seen[newIndex[e]] {
+				seen[newIndex[e]] = true
+				edges[n] = append(edges[n], newIndex[e])
+			}
+		}
+		sort.Ints(edges[n])
+	}
+	g.nodes, g.edges = nodes, edges
+}
+
+func (g *dataSetGraph) hasEdge(from, to int) bool {
+	for _, e := range g.edges[from] {
+		if e == to {
+			return true
+		}
+	}
+	return false
+}
+
+// stronglyConnectedComponents uses Tarjan's algorithm to find every group of nodes that can all
+// reach each other.
+func (g *dataSetGraph) stronglyConnectedComponents() [][]int {
+	const unvisited = -1
+	index := make([]int, len(g.nodes))
+	lowLink := make([]int, len(g.nodes))
+	onStack := make([]bool, len(g.nodes))
+	for i := range index {
+		index[i] = unvisited
+	}
+	var stack []int
+	var components [][]int
+	next := 0
+
+	var visit func(n int)
+	visit = func(n int) {
+		index[n], lowLink[n] = next, next
+		next++
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, e := range g.edges[n] {
+			if index[e] == unvisited {
+				visit(e)
+				if lowLink[e] < lowLink[n] {
+					lowLink[n] = lowLink[e]
+				}
+			} else if onStack[e] && index[e] < lowLink[n] {
+				lowLink[n] = index[e]
+			}
+		}
+		if lowLink[n] == index[n] {
+			var component []int
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == n {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	for n := range g.nodes {
+		if index[n] == unvisited {
+			visit(n)
+		}
+	}
+	return components
+}
+
+// findCycle returns the shortest cycle that starts and ends with the lowest-sorted node in a strongly
+// connected component.
+func (g *dataSetGraph) findCycle(component []int) []DataItemRef {
+	start := component[0]
+	members := make(map[int]bool, len(component))
+	for _, n := range component {
+		members[n] = true
+		if n < start {
+			start = n
+		}
+	}
+	parent := make(map[int]int, len(component))
+	queue := []int{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range g.edges[n] {
+			if e == start {
+				var path []DataItemRef
+				for p := n; p != start; p = parent[p] {
+					path = append(path, g.nodes[p])
+				}
+				path = append(path, g.nodes[start])
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return append(path, g.nodes[start])
+			}
+			if _, seen := parent[e]; !seen && members[e] {
+				parent[e] = n
+				queue = append(queue, e)
+			}
+		}
+	}
+	return nil // COVERAGE: can't happen, since every node in the component can reach the start node
+}
+
+// dependencyOrder returns the nodes in depth-first post-order, which puts every node after all of
+// the nodes it has edges to. Nodes that are in a cycle or can reach one are omitted.
+func (g *dataSetGraph) dependencyOrder(inCycle []bool) []DataItemRef {
+	const (
+		notVisited = iota
+		ordered
+		omitted
+	)
+	state := make([]int, len(g.nodes))
+	ret := make([]DataItemRef, 0, len(g.nodes))
+	var visit func(n int) bool
+	visit = func(n int) bool {
+		switch state[n] {
+		case ordered:
+			return true
+		case omitted:
+			return false
+		}
+		state[n] = omitted // also stops recursion if we're in a cycle
+		if inCycle[n] {
+			return false
+		}
+		ok := true
+		for _, e := range g.edges[n] {
+			if !visit(e) {
+				ok = false
+			}
+		}
+		if ok {
+			state[n] = ordered
+			ret = append(ret, g.nodes[n])
+		}
+		return ok
+	}
+	for n := range g.nodes {
+		visit(n)
+	}
+	return ret
+}
@@ -0,0 +1,105 @@
+package ldmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+func flagWithRefs(key string, prereqKeys []string, segmentKeys ...string) *FeatureFlag {
+	f := &FeatureFlag{Key: key}
+	for _, p := range prereqKeys {
+		f.Prerequisites = append(f.Prerequisites, Prerequisite{Key: p})
+	}
+	if len(segmentKeys) != 0 {
+		f.Rules = []FlagRule{{Clauses: []Clause{makeSegmentMatchClause(segmentKeys...)}}}
+	}
+	return f
+}
+
+func segmentWithRefs(key string, segmentKeys ...string) *Segment {
+	s := &Segment{Key: key}
+	if len(segmentKeys) != 0 {
+		s.Rules = []SegmentRule{{Clauses: []Clause{makeSegmentMatchClause(segmentKeys...)}}}
+	}
+	return s
+}
+
+func makeSegmentMatchClause(segmentKeys ...string) Clause {
+	c := Clause{Op: OperatorSegmentMatch}
+	for _, k := range segmentKeys {
+		c.Values = append(c.Values, ldvalue.String(k))
+	}
+	return c
+}
+
+func flagRef(key string) DataItemRef    { return DataItemRef{DataKindFlag, key} }
+func segmentRef(key string) DataItemRef { return DataItemRef{DataKindSegment, key} }
+
+func TestAnalyzeConsistentDataSet(t *testing.T) {
+	a := AnalyzeDataSet(
+		[]*FeatureFlag{
+			flagWithRefs("c", []string{"b", "a"}),
+			flagWithRefs("b", nil, "s1"),
+			flagWithRefs("a", []string{"b"}),
+		},
+		[]*Segment{segmentWithRefs("s1", "s0"), segmentWithRefs("s0")},
+	)
+	assert.False(t, a.HasProblems())
+	assert.Len(t, a.DanglingReferences, 0)
+	assert.Len(t, a.Cycles, 0)
+	assert.Equal(t, []DataItemRef{segmentRef("s0"), segmentRef("s1"), flagRef("b"), flagRef("a"), flagRef("c")},
+		a.Order)
+}
+
+func TestAnalyzeDanglingReferences(t *testing.T) {
+	deletedFlag := flagWithRefs("deleted", nil)
+	deletedFlag.Deleted = true
+	a := AnalyzeDataSet(
+		[]*FeatureFlag{
+			flagWithRefs("b", []string{"missing", "deleted"}),
+			flagWithRefs("a", nil, "s", "missing-segment"),
+			deletedFlag,
+			nil,
+		},
+		[]*Segment{segmentWithRefs("s", "missing-segment")},
+	)
+	assert.True(t, a.HasProblems())
+	assert.Equal(t, []DanglingReference{
+		{From: flagRef("a"), Path: "/rules/0/clauses/0/values/1", To: segmentRef("missing-segment")},
+		{From: flagRef("b"), Path: "/prerequisites/0/key", To: flagRef("missing")},
+		{From: flagRef("b"), Path: "/prerequisites/1/key", To: flagRef("deleted")},
+		{From: segmentRef("s"), Path: "/rules/0/clauses/0/values/0", To: segmentRef("missing-segment")},
+	}, a.DanglingReferences)
+	assert.Equal(t, []DataItemRef{segmentRef("s"), flagRef("a"), flagRef("b")}, a.Order)
+}
+
+func TestAnalyzeCycles(t *testing.T) {
+	a := AnalyzeDataSet(
+		[]*FeatureFlag{
+			flagWithRefs("self", []string{"self"}),
+			flagWithRefs("c", []string{"a"}),
+			flagWithRefs("b", []string{"c"}),
+			flagWithRefs("a", []string{"b"}),
+			flagWithRefs("dependsOnCycle", []string{"ok", "a"}),
+			flagWithRefs("ok", nil),
+			flagWithRefs("usesSegmentCycle", nil, "s0"),
+		},
+		[]*Segment{segmentWithRefs("s1", "s0"), segmentWithRefs("s0", "s1")},
+	)
+	assert.True(t, a.HasProblems())
+	assert.Len(t, a.DanglingReferences, 0)
+	assert.Equal(t, [][]DataItemRef{
+		{flagRef("a"), flagRef("b"), flagRef("c"), flagRef("a")},
+		{flagRef("self"), flagRef("self")},
+		{segmentRef("s0"), segmentRef("s1"), segmentRef("s0")},
+	}, a.Cycles)
+	assert.Equal(t, []DataItemRef{flagRef("ok")}, a.Order)
+}
+
+func TestDataItemRefString(t *testing.T) {
+	assert.Equal(t, `flag "a"`, flagRef("a").String())
+	assert.Equal(t, `segment "b"`, segmentRef("b").String())
+}
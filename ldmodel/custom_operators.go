@@ -0,0 +1,34 @@
+package ldmodel
+
+import (
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// CustomOperatorPreprocessor is a function that parses a clause value for a custom operator into some
+// other representation that is more efficient to use during evaluations. See
+// CustomOperatorPreprocessors.
+//
+// The function is called once for each value of each clause that uses the operator. It may return nil
+// if the value is not valid for this operator. It must not retain or modify the value.
+type CustomOperatorPreprocessor func(clauseValue ldvalue.Value) interface{}
+
+// CustomOperatorPreprocessors specifies, for each custom operator, a function that will be used to
+// preprocess the clause values of every clause that uses that operator.
+//
+// This is only useful for an operator that has been defined for the evaluator with
+// evaluation.EvaluatorOptionCustomOperator. When PreprocessFlagWithCustomOperators or
+// PreprocessSegmentWithCustomOperators encounters a clause with one of these operators, it calls the
+// function for each of the clause's values and stores the result, which the evaluator will then pass to
+// the custom operator each time it is used. ValidateFlag and ValidateSegment also use the functions to
+// find clause values that are not valid for the operator.
+//
+// Preprocessors cannot be specified for any of the operators that are defined in this package; any
+// such entries are ignored. A nil map is valid and means there are no preprocessors.
+type CustomOperatorPreprocessors map[Operator]CustomOperatorPreprocessor
+
+func (p CustomOperatorPreprocessors) get(op Operator) CustomOperatorPreprocessor {
+	if IsBuiltInOperator(op) {
+		return nil
+	}
+	return p[op]
+}
@@ -0,0 +1,532 @@
+package ldmodel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// ChangeKind describes what kind of change is represented by a DiffChange.
+type ChangeKind string
+
+const (
+	// ChangeKindModified means that a property has a different value. DiffChange.Old and DiffChange.New
+	// are the old and new values.
+	ChangeKindModified ChangeKind = "modified"
+	// ChangeKindAdded means that an element was added to a list or set. DiffChange.New describes it:
+	// for a flag rule, this is the rule ID; for a prerequisite, it is the flag key; for a clause value or
+	// target value, it is the value itself.
+	ChangeKindAdded ChangeKind = "added"
+	// ChangeKindRemoved means that an element was removed from a list or set. DiffChange.Old describes
+	// it, in the same way as DiffChange.New for ChangeKindAdded.
+	ChangeKindRemoved ChangeKind = "removed"
+	// ChangeKindMoved means that a rule or prerequisite is in a different position relative to the
+	// others. DiffChange.Old and DiffChange.New are the old and new indexes.
+	ChangeKindMoved ChangeKind = "moved"
+)
+
+// DiffChange describes a single difference between two versions of a flag or segment. See DiffFlags.
+type DiffChange struct {
+	// Kind is the kind of change.
+	Kind ChangeKind
+	// Path identifies the property that changed, in the same format as ValidationFinding.Path. Indexes
+	// in the path refer to the new version, except for ChangeKindRemoved where they refer to the old
+	// version.
+	Path string
+	// RuleID is the ID of the rule that the change is in, if any.
+	RuleID string
+	// Old is the old value, if applicable.
+	Old ldvalue.Value
+	// New is the new value, if applicable.
+	New ldvalue.Value
+}
+
+// String returns a human-readable description of the change.
+func (c DiffChange) String() string {
+	var desc string
+	switch c.Kind {
+	case ChangeKindAdded:
+		desc = fmt.Sprintf("%s: added %s", c.Path, c.New.JSONString())
+	case ChangeKindRemoved:
+		desc = fmt.Sprintf("%s: removed %s", c.Path, c.Old.JSONString())
+	case ChangeKindMoved:
+		desc = fmt.Sprintf("%s: moved from index %s to %s", c.Path, c.Old.JSONString(), c.New.JSONString())
+	default:
+		desc = fmt.Sprintf("%s: changed from %s to %s", c.Path, c.Old.JSONString(), c.New.JSONString())
+	}
+	if c.RuleID != "" {
+		desc += fmt.Sprintf(" (rule %q)", c.RuleID)
+	}
+	return desc
+}
+
+// ChangeList is a list of changes returned by DiffFlags or DiffSegments.
+type ChangeList []DiffChange
+
+// String returns a human-readable description of all the changes, one per line.
+func (l ChangeList) String() string {
+	var b strings.Builder
+	for _, c := range l {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// DiffFlags returns a description of everything that is different between two versions of a flag,
+// or nil if they are equivalent. The Version properties are not compared.
+//
+// Rules are matched by ID, so that a rule that has been moved is reported as ChangeKindMoved rather
+// than as a series of modifications. If any rule in either version has no ID, or if two rules have the
+// same ID, the rules are matched by position instead. Prerequisites are matched by flag key in the same
+// way, since their order determines which failed prerequisite is reported. Targets are matched by
+// context kind and variation. The order of values in clauses and targets is not significant.
+func DiffFlags(oldFlag, newFlag *FeatureFlag) ChangeList {
+	var d differ
+	d.modified("/key", ldvalue.String(oldFlag.Key), ldvalue.String(newFlag.Key))
+	d.modified("/deleted", ldvalue.Bool(oldFlag.Deleted), ldvalue.Bool(newFlag.Deleted))
+	d.modified("/on", ldvalue.Bool(oldFlag.On), ldvalue.Bool(newFlag.On))
+	d.diffPrerequisites(oldFlag.Prerequisites, newFlag.Prerequisites)
+	d.diffTargets("/targets", oldFlag.Targets, newFlag.Targets)
+	d.diffTargets("/contextTargets", oldFlag.ContextTargets, newFlag.ContextTargets)
+	d.diffFlagRules(oldFlag.Rules, newFlag.Rules)
+	d.diffVariationOrRollout("/fallthrough", oldFlag.Fallthrough, newFlag.Fallthrough)
+	d.modified("/offVariation", oldFlag.OffVariation.AsValue(), newFlag.OffVariation.AsValue())
+	for i := 0; i < len(oldFlag.Variations) || i < len(newFlag.Variations); i++ {
+		path := fmt.Sprintf("/variations/%d", i)
+		switch {
+		case i >= len(oldFlag.Variations):
+			d.add(ChangeKindAdded, path, ldvalue.Null(), newFlag.Variations[i])
+		case i >= len(newFlag.Variations):
+			d.add(ChangeKindRemoved, path, oldFlag.Variations[i], ldvalue.Null())
+		default:
+			d.modified(path, oldFlag.Variations[i], newFlag.Variations[i])
+		}
+	}
+	d.modified("/clientSideAvailability/usingMobileKey",
+		ldvalue.Bool(oldFlag.ClientSideAvailability.UsingMobileKey),
+		ldvalue.Bool(newFlag.ClientSideAvailability.UsingMobileKey))
+	d.modified("/clientSideAvailability/usingEnvironmentId",
+		ldvalue.Bool(oldFlag.ClientSideAvailability.UsingEnvironmentID),
+		ldvalue.Bool(newFlag.ClientSideAvailability.UsingEnvironmentID))
+	d.modified("/salt", ldvalue.String(oldFlag.Salt), ldvalue.String(newFlag.Salt))
+	d.modified("/trackEvents", ldvalue.Bool(oldFlag.TrackEvents), ldvalue.Bool(newFlag.TrackEvents))
+	d.modified("/trackEventsFallthrough", ldvalue.Bool(oldFlag.TrackEventsFallthrough),
+		ldvalue.Bool(newFlag.TrackEventsFallthrough))
+	d.modified("/debugEventsUntilDate", unixMillisValue(int64(oldFlag.DebugEventsUntilDate)),
+		unixMillisValue(int64(newFlag.DebugEventsUntilDate)))
+	var oldCheckRatio, newCheckRatio ldvalue.OptionalInt
+	if oldFlag.Migration != nil {
+		oldCheckRatio = oldFlag.Migration.CheckRatio
+	}
+	if newFlag.Migration != nil {
+		newCheckRatio = newFlag.Migration.CheckRatio
+	}
+	d.modified("/migration/checkRatio", oldCheckRatio.AsValue(), newCheckRatio.AsValue())
+	d.modified("/samplingRatio", oldFlag.SamplingRatio.AsValue(), newFlag.SamplingRatio.AsValue())
+	d.modified("/excludeFromSummaries", ldvalue.Bool(oldFlag.ExcludeFromSummaries),
+		ldvalue.Bool(newFlag.ExcludeFromSummaries))
+	return d.changes
+}
+
+// DiffSegments returns a description of everything that is different between two versions of a
+// segment, or nil if they are equivalent. The Version properties are not compared.
+//
+// Rules and clauses are compared in the same way as for DiffFlags. Targets are matched by context kind.
+func DiffSegments(oldSegment, newSegment *Segment) ChangeList {
+	var d differ
+	d.modified("/key", ldvalue.String(oldSegment.Key), ldvalue.String(newSegment.Key))
+	d.modified("/deleted", ldvalue.Bool(oldSegment.Deleted), ldvalue.Bool(newSegment.Deleted))
+	d.stringSet("/included", oldSegment.Included, newSegment.Included)
+	d.stringSet("/excluded", oldSegment.Excluded, newSegment.Excluded)
+	d.diffSegmentTargets("/includedContexts", oldSegment.IncludedContexts, newSegment.IncludedContexts)
+	d.diffSegmentTargets("/excludedContexts", oldSegment.ExcludedContexts, newSegment.ExcludedContexts)
+	d.modified("/salt", ldvalue.String(oldSegment.Salt), ldvalue.String(newSegment.Salt))
+	d.diffSegmentRules(oldSegment.Rules, newSegment.Rules)
+	d.modified("/unbounded", ldvalue.Bool(oldSegment.Unbounded), ldvalue.Bool(newSegment.Unbounded))
+	d.modified("/unboundedContextKind", kindValue(oldSegment.UnboundedContextKind),
+		kindValue(newSegment.UnboundedContextKind))
+	d.modified("/generation", oldSegment.Generation.AsValue(), newSegment.Generation.AsValue())
+	return d.changes
+}
+
+type differ struct {
+	changes ChangeList
+	ruleID  string // set while we are comparing the properties of a rule
+}
+
+func (d *differ) add(kind ChangeKind, path string, oldValue, newValue ldvalue.Value) {
+	d.changes = append(d.changes,
+		DiffChange{Kind: kind, Path: path, RuleID: d.ruleID, Old: oldValue, New: newValue})
+}
+
+func (d *differ) modified(path string, oldValue, newValue ldvalue.Value) {
+	if !oldValue.Equal(newValue) {
+		d.add(ChangeKindModified, path, oldValue, newValue)
+	}
+}
+
+func (d *differ) stringSet(path string, oldValues, newValues []string) {
+	oldSet, newSet := make(map[string]bool, len(oldValues)), make(map[string]bool, len(newValues))
+	for _, v := range oldValues {
+		oldSet[v] = true
+	}
+	for _, v := range newValues {
+		newSet[v] = true
+	}
+	var removed, added []string
+	for v := range oldSet {
+		if !newSet[v] {
+			removed = append(removed, v)
+		}
+	}
+	for v := range newSet {
+		if !oldSet[v] {
+			added = append(added, v)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	for _, v := range removed {
+		d.add(ChangeKindRemoved, path, ldvalue.String(v), ldvalue.Null())
+	}
+	for _, v := range added {
+		d.add(ChangeKindAdded, path, ldvalue.Null(), ldvalue.String(v))
+	}
+}
+
+func (d *differ) valueSet(path string, oldValues, newValues []ldvalue.Value) {
+	contains := func(values []ldvalue.Value, v ldvalue.Value) bool {
+		for _, x := range values {
+			if x.Equal(v) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, v := range oldValues {
+		if !contains(newValues, v) {
+			d.add(ChangeKindRemoved, path, v, ldvalue.Null())
+		}
+	}
+	for _, v := range newValues {
+		if !contains(oldValues, v) {
+			d.add(ChangeKindAdded, path, ldvalue.Null(), v)
+		}
+	}
+}
+
+func (d *differ) diffPrerequisites(oldPrereqs, newPrereqs []Prerequisite) {
+	oldKeys, newKeys := make([]string, len(oldPrereqs)), make([]string, len(newPrereqs))
+	for i, p := range oldPrereqs {
+		oldKeys[i] = p.Key
+	}
+	for i, p := range newPrereqs {
+		newKeys[i] = p.Key
+	}
+	d.diffKeyedList("/prerequisites", "key", false, oldKeys, newKeys, func(path string, oldIndex, newIndex int) {
+		d.modified(path+"/variation", ldvalue.Int(oldPrereqs[oldIndex].Variation),
+			ldvalue.Int(newPrereqs[newIndex].Variation))
+	})
+}
+
+func (d *differ) diffTargets(path string, oldTargets, newTargets []Target) {
+	type targetKey struct {
+		kind      ldcontext.Kind
+		variation int
+	}
+	oldIndexes := make(map[targetKey]int, len(oldTargets))
+	for i, t := range oldTargets {
+		oldIndexes[targetKey{t.ContextKind, t.Variation}] = i
+	}
+	newKeys := make(map[targetKey]bool, len(newTargets))
+	for _, t := range newTargets {
+		newKeys[targetKey{t.ContextKind, t.Variation}] = true
+	}
+	for i, t := range oldTargets {
+		if !newKeys[targetKey{t.ContextKind, t.Variation}] {
+			d.stringSet(fmt.Sprintf("%s/%d/values", path, i), t.Values, nil)
+		}
+	}
+	for i, t := range newTargets {
+		var oldValues []string
+		if oldIndex, ok := oldIndexes[targetKey{t.ContextKind, t.Variation}]; ok {
+			oldValues = oldTargets[oldIndex].Values
+		}
+		d.stringSet(fmt.Sprintf("%s/%d/values", path, i), oldValues, t.Values)
+	}
+}
+
+func (d *differ) diffSegmentTargets(path string, oldTargets, newTargets []SegmentTarget) {
+	oldIndexes := make(map[ldcontext.Kind]int, len(oldTargets))
+	for i, t := range oldTargets {
+		oldIndexes[t.ContextKind] = i
+	}
+	newKinds := make(map[ldcontext.Kind]bool, len(newTargets))
+	for _, t := range newTargets {
+		newKinds[t.ContextKind] = true
+	}
+	for i, t := range oldTargets {
+		if !newKinds[t.ContextKind] {
+			d.stringSet(fmt.Sprintf("%s/%d/values", path, i), t.Values, nil)
+		}
+	}
+	for i, t := range newTargets {
+		var oldValues []string
+		if oldIndex, ok := oldIndexes[t.ContextKind]; ok {
+			oldValues = oldTargets[oldIndex].Values
+		}
+		d.stringSet(fmt.Sprintf("%s/%d/values", path, i), oldValues, t.Values)
+	}
+}
+
+func (d *differ) diffFlagRules(oldRules, newRules []FlagRule) {
+	oldIDs, newIDs := make([]string, len(oldRules)), make([]string, len(newRules))
+	for i, r := range oldRules {
+		oldIDs[i] = r.ID
+	}
+	for i, r := range newRules {
+		newIDs[i] = r.ID
+	}
+	d.diffKeyedList("/rules", "id", true, oldIDs, newIDs, func(path string, oldIndex, newIndex int) {
+		oldRule, newRule := oldRules[oldIndex], newRules[newIndex]
+		d.diffClauses(path, oldRule.Clauses, newRule.Clauses)
+		d.diffVariationOrRollout(path, oldRule.VariationOrRollout, newRule.VariationOrRollout)
+		d.modified(path+"/trackEvents", ldvalue.Bool(oldRule.TrackEvents), ldvalue.Bool(newRule.TrackEvents))
+	})
+}
+
+func (d *differ) diffSegmentRules(oldRules, newRules []SegmentRule) {
+	oldIDs, newIDs := make([]string, len(oldRules)), make([]string, len(newRules))
+	for i, r := range oldRules {
+		oldIDs[i] = r.ID
+	}
+	for i, r := range newRules {
+		newIDs[i] = r.ID
+	}
+	d.diffKeyedList("/rules", "id", true, oldIDs, newIDs, func(path string, oldIndex, newIndex int) {
+		oldRule, newRule := oldRules[oldIndex], newRules[newIndex]
+		d.diffClauses(path, oldRule.Clauses, newRule.Clauses)
+		d.modified(path+"/weight", oldRule.Weight.AsValue(), newRule.Weight.AsValue())
+		d.modified(path+"/bucketBy", attrRefValue(oldRule.BucketBy), attrRefValue(newRule.BucketBy))
+		d.modified(path+"/rolloutContextKind", kindValue(oldRule.RolloutContextKind),
+			kindValue(newRule.RolloutContextKind))
+	})
+}
+
+// diffKeyedList matches up the old and new elements of a list of rules or prerequisites by their IDs
+// or flag keys, and reports elements that were added, removed, or moved. It calls diffItem for each
+// pair of matching elements to compare their properties. If keysAreRuleIDs is true, the changes are
+// attributed to the rules in DiffChange.RuleID.
+//
+// An element is only reported as moved if its position relative to the other elements changed:
+// inserting a rule at the top of the list does not cause all of the other rules to be reported as
+// moved. To determine this, we find the longest sequence of matching elements that are still in the
+// same relative order, and consider only the elements that are not in that sequence to have moved.
+//
+// Matching by key only makes sense if every element has a distinct key. Otherwise, we fall back to
+// comparing the elements by position.
+func (d *differ) diffKeyedList(
+	listPath, keyProperty string,
+	keysAreRuleIDs bool,
+	oldKeys, newKeys []string,
+	diffItem func(path string, oldIndex, newIndex int),
+) {
+	ruleID := func(key string) string {
+		if keysAreRuleIDs {
+			return key
+		}
+		return ""
+	}
+	if !hasUniqueKeys(oldKeys) || !hasUniqueKeys(newKeys) {
+		d.diffKeyedListByPosition(listPath, keyProperty, ruleID, oldKeys, newKeys, diffItem)
+		return
+	}
+	oldIndexes := make(map[string]int, len(oldKeys))
+	for i, key := range oldKeys {
+		oldIndexes[key] = i
+	}
+	newIndexes := make(map[string]int, len(newKeys))
+	for i, key := range newKeys {
+		newIndexes[key] = i
+	}
+	for i, key := range oldKeys {
+		if _, ok := newIndexes[key]; !ok {
+			d.ruleID = ruleID(key)
+			d.add(ChangeKindRemoved, fmt.Sprintf("%s/%d", listPath, i), ldvalue.String(key), ldvalue.Null())
+		}
+	}
+
+	// matched[k] is the old index of the kth new element that also existed in the old list
+	var matched, matchedNewIndexes []int
+	for i, key := range newKeys {
+		if oldIndex, ok := oldIndexes[key]; ok {
+			matched = append(matched, oldIndex)
+			matchedNewIndexes = append(matchedNewIndexes, i)
+		}
+	}
+	inOrder := longestIncreasingSubsequence(matched)
+
+	k := 0
+	for i, key := range newKeys {
+		d.ruleID = ruleID(key)
+		path := fmt.Sprintf("%s/%d", listPath, i)
+		if k >= len(matchedNewIndexes) || matchedNewIndexes[k] != i {
+			d.add(ChangeKindAdded, path, ldvalue.Null(), ldvalue.String(key))
+			continue
+		}
+		oldIndex := matched[k]
+		if !inOrder[k] {
+			d.add(ChangeKindMoved, path, ldvalue.Int(oldIndex), ldvalue.Int(i))
+		}
+		diffItem(path, oldIndex, i)
+		k++
+	}
+	d.ruleID = ""
+}
+
+func (d *differ) diffKeyedListByPosition(
+	listPath, keyProperty string,
+	ruleID func(string) string,
+	oldKeys, newKeys []string,
+	diffItem func(path string, oldIndex, newIndex int),
+) {
+	for i := 0; i < len(oldKeys) || i < len(newKeys); i++ {
+		path := fmt.Sprintf("%s/%d", listPath, i)
+		switch {
+		case i >= len(oldKeys):
+			d.ruleID = ruleID(newKeys[i])
+			d.add(ChangeKindAdded, path, ldvalue.Null(), ldvalue.String(newKeys[i]))
+		case i >= len(newKeys):
+			d.ruleID = ruleID(oldKeys[i])
+			d.add(ChangeKindRemoved, path, ldvalue.String(oldKeys[i]), ldvalue.Null())
+		default:
+			d.ruleID = ruleID(newKeys[i])
+			d.modified(path+"/"+keyProperty, ldvalue.String(oldKeys[i]), ldvalue.String(newKeys[i]))
+			diffItem(path, i, i)
+		}
+	}
+	d.ruleID = ""
+}
+
+func hasUniqueKeys(keys []string) bool {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key == "" || seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	return true
+}
+
+// longestIncreasingSubsequence returns a slice that is true for each element of values that is part of
+// the longest subsequence of values in increasing order. The values must be unique, which diffKeyedList
+// ensures by only matching elements by key if the keys are unique.
+func longestIncreasingSubsequence(values []int) []bool {
+	// This is the simple O(n^2) algorithm, which is fine for the number of rules that a flag will have.
+	lengths, previous := make([]int, len(values)), make([]int, len(values))
+	best := -1
+	for i := range values {
+		lengths[i], previous[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if values[j] < values[i] && lengths[j]+1 > lengths[i] {
+				lengths[i], previous[i] = lengths[j]+1, j
+			}
+		}
+		if best < 0 || lengths[i] > lengths[best] {
+			best = i
+		}
+	}
+	ret := make([]bool, len(values))
+	for i := best; i >= 0; i = previous[i] {
+		ret[i] = true
+	}
+	return ret
+}
+
+func (d *differ) diffClauses(rulePath string, oldClauses, newClauses []Clause) {
+	for i := 0; i < len(oldClauses) || i < len(newClauses); i++ {
+		path := fmt.Sprintf("%s/clauses/%d", rulePath, i)
+		switch {
+		case i >= len(oldClauses):
+			d.add(ChangeKindAdded, path, ldvalue.Null(), clauseDescription(newClauses[i]))
+		case i >= len(newClauses):
+			d.add(ChangeKindRemoved, path, clauseDescription(oldClauses[i]), ldvalue.Null())
+		default:
+			oldClause, newClause := oldClauses[i], newClauses[i]
+			d.modified(path+"/contextKind", kindValue(oldClause.ContextKind), kindValue(newClause.ContextKind))
+			d.modified(path+"/attribute", attrRefValue(oldClause.Attribute), attrRefValue(newClause.Attribute))
+			d.modified(path+"/op", ldvalue.String(string(oldClause.Op)), ldvalue.String(string(newClause.Op)))
+			d.modified(path+"/negate", ldvalue.Bool(oldClause.Negate), ldvalue.Bool(newClause.Negate))
+			d.valueSet(path+"/values", oldClause.Values, newClause.Values)
+		}
+	}
+}
+
+func (d *differ) diffVariationOrRollout(path string, oldVR, newVR VariationOrRollout) {
+	d.modified(path+"/variation", oldVR.Variation.AsValue(), newVR.Variation.AsValue())
+	oldRollout, newRollout := oldVR.Rollout, newVR.Rollout
+	rolloutPath := path + "/rollout"
+	d.modified(rolloutPath+"/kind", ldvalue.String(string(oldRollout.Kind)), ldvalue.String(string(newRollout.Kind)))
+	d.modified(rolloutPath+"/contextKind", kindValue(oldRollout.ContextKind), kindValue(newRollout.ContextKind))
+	d.modified(rolloutPath+"/bucketBy", attrRefValue(oldRollout.BucketBy), attrRefValue(newRollout.BucketBy))
+	d.modified(rolloutPath+"/seed", oldRollout.Seed.AsValue(), newRollout.Seed.AsValue())
+	for i := 0; i < len(oldRollout.Variations) || i < len(newRollout.Variations); i++ {
+		bucketPath := fmt.Sprintf("%s/variations/%d", rolloutPath, i)
+		switch {
+		case i >= len(oldRollout.Variations):
+			d.add(ChangeKindAdded, bucketPath, ldvalue.Null(), weightedVariationDescription(newRollout.Variations[i]))
+		case i >= len(newRollout.Variations):
+			d.add(ChangeKindRemoved, bucketPath, weightedVariationDescription(oldRollout.Variations[i]), ldvalue.Null())
+		default:
+			oldBucket, newBucket := oldRollout.Variations[i], newRollout.Variations[i]
+			d.modified(bucketPath+"/variation", ldvalue.Int(oldBucket.Variation), ldvalue.Int(newBucket.Variation))
+			d.modified(bucketPath+"/weight", ldvalue.Int(oldBucket.Weight), ldvalue.Int(newBucket.Weight))
+			d.modified(bucketPath+"/untracked", ldvalue.Bool(oldBucket.Untracked), ldvalue.Bool(newBucket.Untracked))
+		}
+	}
+}
+
+func clauseDescription(c Clause) ldvalue.Value {
+	return ldvalue.ObjectBuild().
+		Set("contextKind", kindValue(c.ContextKind)).
+		Set("attribute", attrRefValue(c.Attribute)).
+		Set("op", ldvalue.String(string(c.Op))).
+		Set("values", ldvalue.ArrayOf(c.Values...)).
+		Set("negate", ldvalue.Bool(c.Negate)).
+		Build()
+}
+
+func weightedVariationDescription(wv WeightedVariation) ldvalue.Value {
+	return ldvalue.ObjectBuild().
+		Set("variation", ldvalue.Int(wv.Variation)).
+		Set("weight", ldvalue.Int(wv.Weight)).
+		Set("untracked", ldvalue.Bool(wv.Untracked)).
+		Build()
+}
+
+func kindValue(kind ldcontext.Kind) ldvalue.Value {
+	if kind == "" {
+		return ldvalue.Null()
+	}
+	return ldvalue.String(string(kind))
+}
+
+func attrRefValue(ref ldattr.Ref) ldvalue.Value {
+	if !ref.IsDefined() {
+		return ldvalue.Null()
+	}
+	return ldvalue.String(ref.String())
+}
+
+func unixMillisValue(t int64) ldvalue.Value {
+	if t == 0 {
+		return ldvalue.Null()
+	}
+	return ldvalue.Float64(float64(t))
+}
@@ -0,0 +1,192 @@
+package ldmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+func makeFlagForDiff() FeatureFlag {
+	rule := func(id string, values ...string) FlagRule {
+		c := Clause{Attribute: ldattr.NewRef("name"), Op: OperatorIn}
+		for _, v := range values {
+			c.Values = append(c.Values, ldvalue.String(v))
+		}
+		return FlagRule{ID: id, Clauses: []Clause{c},
+			VariationOrRollout: VariationOrRollout{Variation: ldvalue.NewOptionalInt(1)}}
+	}
+	return FeatureFlag{
+		Key:           "flag",
+		On:            true,
+		Version:       1,
+		Prerequisites: []Prerequisite{{Key: "p0", Variation: 0}, {Key: "p1", Variation: 1}},
+		Targets:       []Target{{Values: []string{"a", "b"}, Variation: 0}},
+		Rules:         []FlagRule{rule("r0", "x"), rule("r1", "y"), rule("r2", "z"), rule("r3", "w")},
+		Fallthrough: VariationOrRollout{Rollout: Rollout{
+			Variations: []WeightedVariation{{Variation: 0, Weight: 50000}, {Variation: 1, Weight: 50000}},
+		}},
+		OffVariation: ldvalue.NewOptionalInt(0),
+		Variations:   []ldvalue.Value{ldvalue.Bool(false), ldvalue.Bool(true)},
+	}
+}
+
+func TestDiffFlagsWithNoChanges(t *testing.T) {
+	f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+	f1.Version = 2
+	assert.Nil(t, DiffFlags(&f0, &f1))
+}
+
+func TestDiffFlagsTopLevelProperties(t *testing.T) {
+	f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+	f1.On = false
+	f1.OffVariation = ldvalue.OptionalInt{}
+	f1.Variations = append(f1.Variations, ldvalue.String("x"))
+	f1.Salt = "new-salt"
+	assert.Equal(t, ChangeList{
+		{Kind: ChangeKindModified, Path: "/on", Old: ldvalue.Bool(true), New: ldvalue.Bool(false)},
+		{Kind: ChangeKindModified, Path: "/offVariation", Old: ldvalue.Int(0), New: ldvalue.Null()},
+		{Kind: ChangeKindAdded, Path: "/variations/2", New: ldvalue.String("x")},
+		{Kind: ChangeKindModified, Path: "/salt", Old: ldvalue.String(""), New: ldvalue.String("new-salt")},
+	}, DiffFlags(&f0, &f1))
+}
+
+func TestDiffFlagsPrerequisitesAndTargets(t *testing.T) {
+	f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+	f1.Prerequisites = []Prerequisite{{Key: "p1", Variation: 0}, {Key: "p2", Variation: 0}}
+	f1.Targets = []Target{{Values: []string{"c", "a"}, Variation: 0}, {Values: []string{"d"}, Variation: 1}}
+	assert.Equal(t, ChangeList{
+		{Kind: ChangeKindRemoved, Path: "/prerequisites/0", Old: ldvalue.String("p0")},
+		{Kind: ChangeKindModified, Path: "/prerequisites/0/variation", Old: ldvalue.Int(1), New: ldvalue.Int(0)},
+		{Kind: ChangeKindAdded, Path: "/prerequisites/1", New: ldvalue.String("p2")},
+		{Kind: ChangeKindRemoved, Path: "/targets/0/values", Old: ldvalue.String("b")},
+		{Kind: ChangeKindAdded, Path: "/targets/0/values", New: ldvalue.String("c")},
+		{Kind: ChangeKindAdded, Path: "/targets/1/values", New: ldvalue.String("d")},
+	}, DiffFlags(&f0, &f1))
+}
+
+func TestDiffFlagsReorderedPrerequisites(t *testing.T) {
+	f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+	f1.Prerequisites = []Prerequisite{{Key: "p1", Variation: 1}, {Key: "p0", Variation: 0}}
+	assert.Equal(t, ChangeList{
+		{Kind: ChangeKindMoved, Path: "/prerequisites/1", Old: ldvalue.Int(0), New: ldvalue.Int(1)},
+	}, DiffFlags(&f0, &f1))
+}
+
+func TestDiffFlagsRules(t *testing.T) {
+	t.Run("added, removed, and moved", func(t *testing.T) {
+		f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+		// old order: r0 r1 r2 r3; new order: new r2 r0 r1 (r3 removed, r2 moved to before r0)
+		newRule := FlagRule{ID: "new", VariationOrRollout: VariationOrRollout{Variation: ldvalue.NewOptionalInt(0)}}
+		f1.Rules = []FlagRule{newRule, f0.Rules[2], f0.Rules[0], f0.Rules[1]}
+		assert.Equal(t, ChangeList{
+			{Kind: ChangeKindRemoved, Path: "/rules/3", RuleID: "r3", Old: ldvalue.String("r3")},
+			{Kind: ChangeKindAdded, Path: "/rules/0", RuleID: "new", New: ldvalue.String("new")},
+			{Kind: ChangeKindMoved, Path: "/rules/1", RuleID: "r2", Old: ldvalue.Int(2), New: ldvalue.Int(1)},
+		}, DiffFlags(&f0, &f1))
+	})
+
+	t.Run("inserting a rule does not move the others", func(t *testing.T) {
+		f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+		f1.Rules = append([]FlagRule{{ID: "new"}}, f1.Rules...)
+		assert.Equal(t, ChangeList{
+			{Kind: ChangeKindAdded, Path: "/rules/0", RuleID: "new", New: ldvalue.String("new")},
+		}, DiffFlags(&f0, &f1))
+	})
+
+	t.Run("duplicate IDs are compared by position", func(t *testing.T) {
+		f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+		f0.Rules[2].ID, f1.Rules[2].ID = "r1", "r1"
+		f1.Rules = []FlagRule{f1.Rules[0], f1.Rules[2], f1.Rules[1], f1.Rules[3]}
+		assert.Equal(t, ChangeList{
+			{Kind: ChangeKindRemoved, Path: "/rules/1/clauses/0/values", RuleID: "r1", Old: ldvalue.String("y")},
+			{Kind: ChangeKindAdded, Path: "/rules/1/clauses/0/values", RuleID: "r1", New: ldvalue.String("z")},
+			{Kind: ChangeKindRemoved, Path: "/rules/2/clauses/0/values", RuleID: "r1", Old: ldvalue.String("z")},
+			{Kind: ChangeKindAdded, Path: "/rules/2/clauses/0/values", RuleID: "r1", New: ldvalue.String("y")},
+		}, DiffFlags(&f0, &f1))
+	})
+
+	t.Run("missing IDs are compared by position", func(t *testing.T) {
+		f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+		f1.Rules[0].ID = ""
+		f1.Rules = append(f1.Rules, FlagRule{ID: "new"})
+		assert.Equal(t, ChangeList{
+			{Kind: ChangeKindModified, Path: "/rules/0/id", Old: ldvalue.String("r0"), New: ldvalue.String("")},
+			{Kind: ChangeKindAdded, Path: "/rules/4", RuleID: "new", New: ldvalue.String("new")},
+		}, DiffFlags(&f0, &f1))
+	})
+
+	t.Run("clauses and rollout", func(t *testing.T) {
+		f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+		f1.Rules[1].Clauses = []Clause{
+			{Attribute: ldattr.NewRef("name"), Op: OperatorIn, Negate: true,
+				Values: []ldvalue.Value{ldvalue.String("y2"), ldvalue.String("y")}},
+			{Attribute: ldattr.NewRef("email"), Op: OperatorEndsWith, Values: []ldvalue.Value{ldvalue.String("@x")}},
+		}
+		f1.Rules[1].Variation = ldvalue.OptionalInt{}
+		f1.Rules[1].Rollout = Rollout{Variations: []WeightedVariation{{Variation: 1, Weight: 100000}}}
+		assert.Equal(t, ChangeList{
+			{Kind: ChangeKindModified, Path: "/rules/1/clauses/0/negate", RuleID: "r1",
+				Old: ldvalue.Bool(false), New: ldvalue.Bool(true)},
+			{Kind: ChangeKindAdded, Path: "/rules/1/clauses/0/values", RuleID: "r1", New: ldvalue.String("y2")},
+			{Kind: ChangeKindAdded, Path: "/rules/1/clauses/1", RuleID: "r1", New: ldvalue.ObjectBuild().
+				Set("contextKind", ldvalue.Null()).Set("attribute", ldvalue.String("email")).
+				Set("op", ldvalue.String("endsWith")).Set("values", ldvalue.ArrayOf(ldvalue.String("@x"))).
+				Set("negate", ldvalue.Bool(false)).Build()},
+			{Kind: ChangeKindModified, Path: "/rules/1/variation", RuleID: "r1", Old: ldvalue.Int(1), New: ldvalue.Null()},
+			{Kind: ChangeKindAdded, Path: "/rules/1/rollout/variations/0", RuleID: "r1", New: ldvalue.ObjectBuild().
+				Set("variation", ldvalue.Int(1)).Set("weight", ldvalue.Int(100000)).
+				Set("untracked", ldvalue.Bool(false)).Build()},
+		}, DiffFlags(&f0, &f1))
+	})
+}
+
+func TestDiffFlagsFallthroughRollout(t *testing.T) {
+	f0, f1 := makeFlagForDiff(), makeFlagForDiff()
+	f1.Fallthrough.Rollout.Variations = []WeightedVariation{{Variation: 0, Weight: 10000}, {Variation: 1, Weight: 90000}}
+	assert.Equal(t, ChangeList{
+		{Kind: ChangeKindModified, Path: "/fallthrough/rollout/variations/0/weight",
+			Old: ldvalue.Int(50000), New: ldvalue.Int(10000)},
+		{Kind: ChangeKindModified, Path: "/fallthrough/rollout/variations/1/weight",
+			Old: ldvalue.Int(50000), New: ldvalue.Int(90000)},
+	}, DiffFlags(&f0, &f1))
+}
+
+func TestDiffSegments(t *testing.T) {
+	s0 := Segment{
+		Key:              "segment",
+		Included:         []string{"a", "b"},
+		IncludedContexts: []SegmentTarget{{ContextKind: "org", Values: []string{"o1"}}},
+		Rules:            []SegmentRule{{ID: "r0", Weight: ldvalue.NewOptionalInt(1000)}},
+	}
+	s1 := s0
+	s1.Included = []string{"b"}
+	s1.IncludedContexts = nil
+	s1.Rules = []SegmentRule{{ID: "r0", Weight: ldvalue.NewOptionalInt(2000), BucketBy: ldattr.NewRef("email")}}
+	s1.Unbounded = true
+	assert.Equal(t, ChangeList{
+		{Kind: ChangeKindRemoved, Path: "/included", Old: ldvalue.String("a")},
+		{Kind: ChangeKindRemoved, Path: "/includedContexts/0/values", Old: ldvalue.String("o1")},
+		{Kind: ChangeKindModified, Path: "/rules/0/weight", RuleID: "r0", Old: ldvalue.Int(1000), New: ldvalue.Int(2000)},
+		{Kind: ChangeKindModified, Path: "/rules/0/bucketBy", RuleID: "r0", Old: ldvalue.Null(),
+			New: ldvalue.String("email")},
+		{Kind: ChangeKindModified, Path: "/unbounded", Old: ldvalue.Bool(false), New: ldvalue.Bool(true)},
+	}, DiffSegments(&s0, &s1))
+}
+
+func TestChangeListString(t *testing.T) {
+	changes := ChangeList{
+		{Kind: ChangeKindModified, Path: "/fallthrough/variation", Old: ldvalue.Int(0), New: ldvalue.Int(1)},
+		{Kind: ChangeKindAdded, Path: "/rules/0", RuleID: "r", New: ldvalue.String("r")},
+		{Kind: ChangeKindRemoved, Path: "/targets/0/values", Old: ldvalue.String("a")},
+		{Kind: ChangeKindMoved, Path: "/rules/1", RuleID: "q", Old: ldvalue.Int(0), New: ldvalue.Int(1)},
+	}
+	expected := `/fallthrough/variation: changed from 0 to 1
+/rules/0: added "r" (rule "r")
+/targets/0/values: removed "a"
+/rules/1: moved from index 0 to 1 (rule "q")
+`
+	assert.Equal(t, expected, changes.String())
+}
@@ -37,13 +37,19 @@ var EvaluatorAccessors EvaluatorAccessorMethods //nolint:gochecknoglobals
 // object, or a JSON null (since equality tests are not valid for these in the LaunchDarkly
 // model), or if the clause parameter is nil.
 //
+// If the Clause's operator is OperatorInIgnoreCase, the value and the Clause's Values must be
+// strings, and they are compared after normalizing them with TypeConversions.ValueToNormalizedString.
+//
 // If preprocessing has been done, this is a fast map lookup (as long as the Clause's operator
-// is "in", which is the only case where it makes sense to create a map). Otherwise it iterates
-// the list.
+// is "in", "inIgnoreCase", "containsAll", or "containsNone", which are the only cases where it
+// makes sense to create a map). Otherwise it iterates the list.
 func (e EvaluatorAccessorMethods) ClauseFindValue(clause *Clause, contextValue ldvalue.Value) bool {
 	if clause == nil {
 		return false
 	}
+	if clause.Op == OperatorInIgnoreCase {
+		return e.clauseFindNormalizedString(clause, contextValue)
+	}
 	if clause.preprocessed.valuesMap != nil {
 		if key := asPrimitiveValueKey(contextValue); key.isValid() {
 			_, found := clause.preprocessed.valuesMap[key]
@@ -63,6 +69,116 @@ func (e EvaluatorAccessorMethods) ClauseFindValue(clause *Clause, contextValue l
 	return false
 }
 
+func (e EvaluatorAccessorMethods) clauseFindNormalizedString(clause *Clause, contextValue ldvalue.Value) bool {
+	s, ok := parseNormalizedString(contextValue)
+	if !ok {
+		return false
+	}
+	if clause.preprocessed.valuesMap != nil {
+		_, found := clause.preprocessed.valuesMap[asPrimitiveValueKey(ldvalue.String(s))]
+		return found
+	}
+	for i := range clause.Values {
+		if clauseValue, ok := e.ClauseGetValueAsNormalizedString(clause, i); ok && clauseValue == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ClauseContainsAllValues returns true if every one of the Clause's Values is deeply equal to some
+// element of the specified array, or false otherwise. A value that is not an array is treated as an
+// array containing only that value. Equality is tested in the same way as for ClauseFindValue, so a
+// Clause value that is a JSON array, object, or null is never found. It also returns false if the
+// Clause has no Values, or if the clause parameter is nil.
+//
+// If preprocessing has been done, this looks up each element of the array in a map. Otherwise it
+// searches the array for each of the Clause's Values.
+func (e EvaluatorAccessorMethods) ClauseContainsAllValues(clause *Clause, contextValues ldvalue.Value) bool {
+	if clause == nil || len(clause.Values) == 0 {
+		return false
+	}
+	count := arrayOrSingleValueCount(contextValues)
+	if valuesMap := clause.preprocessed.valuesMap; valuesMap != nil {
+		// Count the distinct elements that are in the map. We remember which of the map's values have
+		// already been found by their positions, so that an element that is equal to an earlier element
+		// is not counted again. A bitset is enough for all but very large clauses.
+		var foundBits uint64
+		var foundFlags []bool
+		if len(valuesMap) > 64 {
+			foundFlags = make([]bool, len(valuesMap))
+		}
+		found := 0
+		for i := 0; i < count; i++ {
+			key := asPrimitiveValueKey(arrayOrSingleValueElement(contextValues, i))
+			if !key.isValid() {
+				continue
+			}
+			position, ok := valuesMap[key]
+			switch {
+			case !ok:
+				continue
+			case foundFlags != nil:
+				if foundFlags[position] {
+					continue
+				}
+				foundFlags[position] = true
+			default:
+				if foundBits&(1<<position) != 0 {
+					continue
+				}
+				foundBits |= 1 << position
+			}
+			found++
+		}
+		return found == len(valuesMap)
+	}
+	for _, clauseValue := range clause.Values {
+		if !asPrimitiveValueKey(clauseValue).isValid() || !arrayContainsBefore(contextValues, count, clauseValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// ClauseContainsAnyValue returns true if any element of the specified array is deeply equal to any
+// of the Clause's Values, or false otherwise. A value that is not an array is treated as an array
+// containing only that value. Equality is tested in the same way as for ClauseFindValue. It also
+// returns false if the clause parameter is nil.
+//
+// If preprocessing has been done, this looks up each element of the array in a map. Otherwise it
+// iterates the list for each element.
+func (e EvaluatorAccessorMethods) ClauseContainsAnyValue(clause *Clause, contextValues ldvalue.Value) bool {
+	for i := 0; i < arrayOrSingleValueCount(contextValues); i++ {
+		if e.ClauseFindValue(clause, arrayOrSingleValueElement(contextValues, i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClauseMatchesIPAddress returns true if the specified value is a string representing an IP address
+// that matches any of the Clause's Values, according to the Clause's operator, which must be
+// OperatorIPInRange or OperatorIPEquals. It returns false for any other operator, or if the clause
+// parameter is nil.
+//
+// If preprocessing has been done, this is a binary search in a sorted list of address ranges.
+// Otherwise it parses all of the values first.
+func (e EvaluatorAccessorMethods) ClauseMatchesIPAddress(clause *Clause, contextValue ldvalue.Value) bool {
+	if clause == nil || (clause.Op != OperatorIPInRange && clause.Op != OperatorIPEquals) {
+		return false
+	}
+	addr, ok := parseIPAddress(contextValue)
+	if !ok {
+		return false
+	}
+	ranges := clause.preprocessed.ipRanges
+	if ranges == nil {
+		ranges = newIPRangeSet(clause.Values, clause.Op == OperatorIPInRange)
+	}
+	return ranges.contains(addr)
+}
+
 // ClauseGetValueAsRegexp returns one of the Clause's values as a Regexp, if the value is a string
 // that represents a valid regular expression.
 //
@@ -86,6 +202,31 @@ func (e EvaluatorAccessorMethods) ClauseGetValueAsRegexp(clause *Clause, index i
 	return nil
 }
 
+// ClauseGetValueAsNormalizedString returns one of the Clause's values as a normalized string, if
+// the value is a string. Any other type is invalid.
+//
+// The second return value is true for success or false for failure. It also returns failure if the
+// index is out of range, or if the clause parameter is nil.
+//
+// If preprocessing has been done, this is a fast slice lookup. Otherwise it calls
+// TypeConversions.ValueToNormalizedString.
+func (e EvaluatorAccessorMethods) ClauseGetValueAsNormalizedString(clause *Clause, index int) (string, bool) {
+	if clause == nil {
+		return "", false
+	}
+	if clause.preprocessed.values != nil {
+		if index < 0 || index >= len(clause.preprocessed.values) {
+			return "", false
+		}
+		p := clause.preprocessed.values[index]
+		return p.normalized, p.valid
+	}
+	if index >= 0 && index < len(clause.Values) {
+		return TypeConversions.ValueToNormalizedString(clause.Values[index])
+	}
+	return "", false
+}
+
 // ClauseGetValueAsSemanticVersion returns one of the Clause's values as a semver.Version, if the
 // value is a string in the correct format. Any other type is invalid.
 //
@@ -136,6 +277,46 @@ func (e EvaluatorAccessorMethods) ClauseGetValueAsTimestamp(clause *Clause, inde
 	return time.Time{}, false
 }
 
+// ClauseGetValueAsDuration returns one of the Clause's values as a time.Duration, if the value is a
+// string in one of the formats described for TypeConversionMethods.ValueToDuration. Any other type is
+// invalid.
+//
+// The second return value is true for success or false for failure. It also returns failure if the
+// index is out of range, or if the clause parameter is nil.
+//
+// If preprocessing has been done, this is a fast slice lookup. Otherwise it calls
+// TypeConversions.ValueToDuration.
+func (e EvaluatorAccessorMethods) ClauseGetValueAsDuration(clause *Clause, index int) (time.Duration, bool) {
+	if clause == nil {
+		return 0, false
+	}
+	if clause.preprocessed.values != nil {
+		if index < 0 || index >= len(clause.preprocessed.values) {
+			return 0, false
+		}
+		p := clause.preprocessed.values[index]
+		return p.parsedDur, p.valid
+	}
+	if index >= 0 && index < len(clause.Values) {
+		return TypeConversions.ValueToDuration(clause.Values[index])
+	}
+	return 0, false
+}
+
+// ClauseGetValueAsCustom returns the result of preprocessing one of the Clause's values with the
+// CustomOperatorPreprocessor for the Clause's operator, that was passed to
+// PreprocessFlagWithCustomOperators or PreprocessSegmentWithCustomOperators.
+//
+// It returns nil if the clause was not preprocessed with a preprocessor for its operator, if the
+// preprocessor returned nil for this value, if the index is out of range, or if the clause parameter
+// is nil. This is always a fast slice lookup.
+func (e EvaluatorAccessorMethods) ClauseGetValueAsCustom(clause *Clause, index int) interface{} {
+	if clause == nil || index < 0 || index >= len(clause.preprocessed.values) {
+		return nil
+	}
+	return clause.preprocessed.values[index].custom
+}
+
 // SegmentFindKeyInExcluded returns true if the specified key is in this Segment's
 // Excluded list, or false otherwise. It also returns false if the segment parameter is nil.
 //
@@ -180,6 +361,30 @@ func (e EvaluatorAccessorMethods) TargetFindKey(target *Target, key string) bool
 	return findValueInMapOrStrings(key, target.Values, target.preprocessed.valuesMap)
 }
 
+func arrayOrSingleValueCount(value ldvalue.Value) int {
+	if value.Type() == ldvalue.ArrayType {
+		return value.Count()
+	}
+	return 1
+}
+
+func arrayOrSingleValueElement(value ldvalue.Value, index int) ldvalue.Value {
+	if value.Type() == ldvalue.ArrayType {
+		return value.GetByIndex(index)
+	}
+	return value
+}
+
+// arrayContainsBefore returns true if any of the first n elements of the array is equal to the value.
+func arrayContainsBefore(values ldvalue.Value, n int, value ldvalue.Value) bool {
+	for i := 0; i < n; i++ {
+		if arrayOrSingleValueElement(values, i).Equal(value) {
+			return true
+		}
+	}
+	return false
+}
+
 func findValueInMapOrStrings(value string, values []string, valuesMap map[string]struct{}) bool {
 	if valuesMap != nil {
 		_, found := valuesMap[value]
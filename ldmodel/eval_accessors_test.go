@@ -17,7 +17,7 @@ func TestClauseFindValue(t *testing.T) {
 		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
 			clause := Clause{Op: OperatorIn, Values: foundValues}
 			if withPreprocessing {
-				clause.preprocessed = preprocessClause(clause)
+				clause.preprocessed = preprocessClause(clause, nil)
 			}
 			for _, value := range foundValues {
 				assert.True(t, EvaluatorAccessors.ClauseFindValue(&clause, value), "value: %s", value)
@@ -34,7 +34,7 @@ func TestClauseFindValue(t *testing.T) {
 			t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
 				clause := Clause{Op: OperatorIn, Values: badValues}
 				if withPreprocessing {
-					clause.preprocessed = preprocessClause(clause)
+					clause.preprocessed = preprocessClause(clause, nil)
 				}
 				for _, value := range badValues {
 					assert.False(t, EvaluatorAccessors.ClauseFindValue(&clause, value), "value: %s", value)
@@ -48,13 +48,135 @@ func TestClauseFindValue(t *testing.T) {
 	})
 }
 
+func TestClauseFindValueIgnoreCase(t *testing.T) {
+	clauseValues := []ldvalue.Value{ldvalue.String("X"), ldvalue.String("Straße"), ldvalue.Int(2)}
+	foundValues := []ldvalue.Value{ldvalue.String("x"), ldvalue.String("X"), ldvalue.String("STRASSE")}
+	notFoundValues := []ldvalue.Value{ldvalue.String("y"), ldvalue.Int(2), ldvalue.Null()}
+
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
+			clause := Clause{Op: OperatorInIgnoreCase, Values: clauseValues}
+			if withPreprocessing {
+				clause.preprocessed = preprocessClause(clause, nil)
+				assert.NotNil(t, clause.preprocessed.valuesMap)
+			}
+			for _, value := range foundValues {
+				assert.True(t, EvaluatorAccessors.ClauseFindValue(&clause, value), "value: %s", value)
+			}
+			for _, value := range notFoundValues {
+				assert.False(t, EvaluatorAccessors.ClauseFindValue(&clause, value), "value: %s", value)
+			}
+		})
+	}
+}
+
+func TestClauseGetValueAsNormalizedString(t *testing.T) {
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
+			clause := Clause{Op: OperatorStartsWithIgnoreCase, Values: []ldvalue.Value{ldvalue.String("ABC"), ldvalue.Int(1)}}
+			if withPreprocessing {
+				clause.preprocessed = preprocessClause(clause, nil)
+			}
+
+			s, ok := EvaluatorAccessors.ClauseGetValueAsNormalizedString(&clause, 0)
+			assert.True(t, ok)
+			assert.Equal(t, "abc", s)
+
+			_, ok = EvaluatorAccessors.ClauseGetValueAsNormalizedString(&clause, 1)
+			assert.False(t, ok)
+
+			_, ok = EvaluatorAccessors.ClauseGetValueAsNormalizedString(&clause, 2)
+			assert.False(t, ok)
+		})
+	}
+
+	t.Run("nil pointer", func(t *testing.T) {
+		_, ok := EvaluatorAccessors.ClauseGetValueAsNormalizedString(nil, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestClauseContainsAllValues(t *testing.T) {
+	clauseValues := []ldvalue.Value{ldvalue.Bool(true), ldvalue.Int(2), ldvalue.String("x"), ldvalue.String("x")}
+
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
+			clause := Clause{Op: OperatorContainsAll, Values: clauseValues}
+			if withPreprocessing {
+				clause.preprocessed = preprocessClause(clause, nil)
+			}
+			assert.True(t, EvaluatorAccessors.ClauseContainsAllValues(&clause,
+				ldvalue.ArrayOf(ldvalue.String("x"), ldvalue.String("y"), ldvalue.Int(2), ldvalue.Bool(true))))
+			assert.False(t, EvaluatorAccessors.ClauseContainsAllValues(&clause,
+				ldvalue.ArrayOf(ldvalue.String("x"), ldvalue.String("x"), ldvalue.Int(2), ldvalue.Null())))
+			assert.False(t, EvaluatorAccessors.ClauseContainsAllValues(&clause, ldvalue.String("x")))
+		})
+	}
+
+	t.Run("clause with more than 64 values", func(t *testing.T) {
+		var values, allButLast []ldvalue.Value
+		for i := 0; i < 100; i++ {
+			values = append(values, ldvalue.Int(i))
+		}
+		allButLast = append(allButLast, values[:99]...)
+		allButLast = append(allButLast, values[:99]...) // repeated elements must not be counted twice
+		for _, withPreprocessing := range []bool{false, true} {
+			t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
+				clause := Clause{Op: OperatorContainsAll, Values: values}
+				if withPreprocessing {
+					clause.preprocessed = preprocessClause(clause, nil)
+				}
+				assert.True(t, EvaluatorAccessors.ClauseContainsAllValues(&clause, ldvalue.ArrayOf(values...)))
+				assert.False(t, EvaluatorAccessors.ClauseContainsAllValues(&clause, ldvalue.ArrayOf(allButLast...)))
+			})
+		}
+	})
+
+	t.Run("non-array value is treated as a single element", func(t *testing.T) {
+		clause := Clause{Op: OperatorContainsAll, Values: []ldvalue.Value{ldvalue.String("x")}}
+		assert.True(t, EvaluatorAccessors.ClauseContainsAllValues(&clause, ldvalue.String("x")))
+	})
+
+	t.Run("clause with no values", func(t *testing.T) {
+		clause := Clause{Op: OperatorContainsAll}
+		assert.False(t, EvaluatorAccessors.ClauseContainsAllValues(&clause, ldvalue.ArrayOf(ldvalue.String("x"))))
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		assert.False(t, EvaluatorAccessors.ClauseContainsAllValues(nil, ldvalue.ArrayOf()))
+	})
+}
+
+func TestClauseContainsAnyValue(t *testing.T) {
+	clauseValues := []ldvalue.Value{ldvalue.Bool(true), ldvalue.Int(2), ldvalue.String("x")}
+
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
+			clause := Clause{Op: OperatorContainsNone, Values: clauseValues}
+			if withPreprocessing {
+				clause.preprocessed = preprocessClause(clause, nil)
+			}
+			assert.True(t, EvaluatorAccessors.ClauseContainsAnyValue(&clause,
+				ldvalue.ArrayOf(ldvalue.String("y"), ldvalue.Int(2))))
+			assert.True(t, EvaluatorAccessors.ClauseContainsAnyValue(&clause, ldvalue.String("x")))
+			assert.False(t, EvaluatorAccessors.ClauseContainsAnyValue(&clause,
+				ldvalue.ArrayOf(ldvalue.String("y"), ldvalue.Bool(false), ldvalue.ArrayOf(ldvalue.String("x")))))
+			assert.False(t, EvaluatorAccessors.ClauseContainsAnyValue(&clause, ldvalue.ArrayOf()))
+		})
+	}
+
+	t.Run("nil pointer", func(t *testing.T) {
+		assert.False(t, EvaluatorAccessors.ClauseContainsAnyValue(nil, ldvalue.String("x")))
+	})
+}
+
 func TestClauseGetValueAsRegexp(t *testing.T) {
 	for _, withPreprocessing := range []bool{false, true} {
 		t.Run(fmt.Sprintf("preprocessed: %t", withPreprocessing), func(t *testing.T) {
 			clause := Clause{Op: OperatorMatches,
 				Values: []ldvalue.Value{ldvalue.String("a.*b"), ldvalue.String("**"), ldvalue.Int(1)}}
 			if withPreprocessing {
-				clause.preprocessed = preprocessClause(clause)
+				clause.preprocessed = preprocessClause(clause, nil)
 			}
 
 			r := EvaluatorAccessors.ClauseGetValueAsRegexp(&clause, 0)
@@ -81,7 +203,7 @@ func TestClauseGetValueAsSemanticVersion(t *testing.T) {
 			clause := Clause{Op: OperatorSemVerEqual,
 				Values: []ldvalue.Value{ldvalue.String("1.2.3"), ldvalue.Int(100000)}}
 			if withPreprocessing {
-				clause.preprocessed = preprocessClause(clause)
+				clause.preprocessed = preprocessClause(clause, nil)
 			}
 
 			result, ok := EvaluatorAccessors.ClauseGetValueAsSemanticVersion(&clause, 0)
@@ -111,7 +233,7 @@ func TestClauseGetValueAsTimestamp(t *testing.T) {
 			clause := Clause{Op: OperatorBefore,
 				Values: []ldvalue.Value{ldvalue.String("1970-01-01T00:00:00Z"), ldvalue.Int(100000), ldvalue.Bool(true)}}
 			if withPreprocessing {
-				clause.preprocessed = preprocessClause(clause)
+				clause.preprocessed = preprocessClause(clause, nil)
 			}
 
 			result, ok := EvaluatorAccessors.ClauseGetValueAsTimestamp(&clause, 0)
@@ -140,6 +262,41 @@ func TestClauseGetValueAsTimestamp(t *testing.T) {
 	})
 }
 
+func TestClauseGetValueAsCustom(t *testing.T) {
+	op := Operator("customOp")
+	customOperators := CustomOperatorPreprocessors{op: func(v ldvalue.Value) interface{} {
+		if v.IsNumber() {
+			return v.IntValue() * 2
+		}
+		return nil
+	}}
+
+	t.Run("preprocessed", func(t *testing.T) {
+		clause := Clause{Op: op, Values: []ldvalue.Value{ldvalue.Int(2), ldvalue.String("x")}}
+		clause.preprocessed = preprocessClause(clause, customOperators)
+
+		assert.Equal(t, 4, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, 0))
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, 1))
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, -1)) // out of range
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, 2))  // out of range
+	})
+
+	t.Run("not preprocessed", func(t *testing.T) {
+		clause := Clause{Op: op, Values: []ldvalue.Value{ldvalue.Int(2)}}
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, 0))
+	})
+
+	t.Run("no preprocessor", func(t *testing.T) {
+		clause := Clause{Op: Operator("otherOp"), Values: []ldvalue.Value{ldvalue.Int(2)}}
+		clause.preprocessed = preprocessClause(clause, customOperators)
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(&clause, 0))
+	})
+
+	t.Run("nil pointer", func(t *testing.T) {
+		assert.Nil(t, EvaluatorAccessors.ClauseGetValueAsCustom(nil, 0))
+	})
+}
+
 func TestSegmentFindKeyInExcluded(t *testing.T) {
 	foundValues := []string{"a", "b", "c"}
 	notFoundValues := []string{"d", "e", "f"}
@@ -0,0 +1,89 @@
+package ldmodel
+
+import (
+	"net/netip"
+	"sort"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// ipRangeSet is the preprocessed form of the values of a clause that uses OperatorIPInRange or
+// OperatorIPEquals. The ranges are sorted and merged, so that checking an address is a binary search
+// no matter how many values the clause has.
+type ipRangeSet struct {
+	v4 []ipRange
+	v6 []ipRange
+}
+
+// ipRange is an inclusive range of addresses of the same family.
+type ipRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// newIPRangeSet parses the values of a clause. If allowRanges is false (for OperatorIPEquals), only
+// single addresses are valid. Invalid values are ignored.
+func newIPRangeSet(values []ldvalue.Value, allowRanges bool) *ipRangeSet {
+	ret := &ipRangeSet{}
+	for _, v := range values {
+		var prefix netip.Prefix
+		var ok bool
+		if allowRanges {
+			prefix, ok = parseIPRange(v)
+		} else if addr, isAddr := parseIPAddress(v); isAddr {
+			prefix, ok = netip.PrefixFrom(addr, addr.BitLen()), true
+		}
+		if !ok {
+			continue
+		}
+		r := ipRange{first: prefix.Addr(), last: lastAddressInPrefix(prefix)}
+		if r.first.Is4() {
+			ret.v4 = append(ret.v4, r)
+		} else {
+			ret.v6 = append(ret.v6, r)
+		}
+	}
+	ret.v4 = mergeIPRanges(ret.v4)
+	ret.v6 = mergeIPRanges(ret.v6)
+	return ret
+}
+
+// contains returns true if the address is in any of the ranges. The address must already have been
+// normalized by parseIPAddress.
+func (s *ipRangeSet) contains(addr netip.Addr) bool {
+	ranges := s.v6
+	if addr.Is4() {
+		ranges = s.v4
+	}
+	// Find the first range that ends at or after the address; since the ranges do not overlap, that is
+	// the only one that could contain it.
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].last.Compare(addr) >= 0 })
+	return i < len(ranges) && ranges[i].first.Compare(addr) <= 0
+}
+
+func lastAddressInPrefix(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
+
+// mergeIPRanges sorts the ranges and combines any that overlap or are adjacent.
+func mergeIPRanges(ranges []ipRange) []ipRange {
+	if len(ranges) < 2 {
+		return ranges
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].first.Less(ranges[j].first) })
+	ret := ranges[:1]
+	for _, r := range ranges[1:] {
+		last := &ret[len(ret)-1]
+		if next := last.last.Next(); next.IsValid() && r.first.Compare(next) > 0 {
+			ret = append(ret, r)
+		} else if r.last.Compare(last.last) > 0 {
+			last.last = r.last
+		}
+	}
+	return ret
+}
@@ -0,0 +1,90 @@
+package ldmodel
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeIPRangeSet(allowRanges bool, values ...string) *ipRangeSet {
+	var vs []ldvalue.Value
+	for _, v := range values {
+		vs = append(vs, ldvalue.String(v))
+	}
+	return newIPRangeSet(vs, allowRanges)
+}
+
+func TestIPRangeSetMergesRanges(t *testing.T) {
+	s := makeIPRangeSet(true, "10.1.0.0/16", "10.0.0.0/8", "192.168.1.0/24", "192.168.0.0/24", "192.168.3.1",
+		"2001:db8::/32", "::/0", "bad")
+	assert.Equal(t, []ipRange{
+		{netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.255.255.255")},
+		{netip.MustParseAddr("192.168.0.0"), netip.MustParseAddr("192.168.1.255")},
+		{netip.MustParseAddr("192.168.3.1"), netip.MustParseAddr("192.168.3.1")},
+	}, s.v4)
+	assert.Equal(t, []ipRange{
+		{netip.MustParseAddr("::"), netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+	}, s.v6)
+}
+
+func TestIPRangeSetContains(t *testing.T) {
+	s := makeIPRangeSet(true, "10.0.0.0/8", "192.168.0.0/24", "192.168.2.0/24", "::ffff:172.16.0.0/108")
+	for _, p := range []struct {
+		addr     string
+		expected bool
+	}{
+		{"9.255.255.255", false},
+		{"10.0.0.0", true},
+		{"10.255.255.255", true},
+		{"11.0.0.0", false},
+		{"192.168.1.1", false},
+		{"192.168.2.1", true},
+		{"172.16.5.5", true},
+		{"172.32.0.0", false},
+		{"::a00:1", false},
+	} {
+		t.Run(p.addr, func(t *testing.T) {
+			addr, ok := parseIPAddress(ldvalue.String(p.addr))
+			assert.True(t, ok)
+			assert.Equal(t, p.expected, s.contains(addr))
+		})
+	}
+}
+
+func TestIPRangeSetForEqualityIgnoresRanges(t *testing.T) {
+	s := makeIPRangeSet(false, "10.0.0.0/8", "10.1.2.3")
+	assert.Equal(t, []ipRange{{netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("10.1.2.3")}}, s.v4)
+}
+
+func TestIPRangeSetWithManyRanges(t *testing.T) {
+	var values []string
+	for i := 0; i < 5000; i++ {
+		values = append(values, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	s := makeIPRangeSet(true, values...)
+	assert.Len(t, s.v4, 1) // they are all adjacent
+	assert.True(t, s.contains(netip.MustParseAddr("10.19.135.200")))
+	assert.False(t, s.contains(netip.MustParseAddr("10.19.136.0")))
+}
+
+func TestClauseMatchesIPAddress(t *testing.T) {
+	clause := Clause{Op: OperatorIPInRange, Values: []ldvalue.Value{ldvalue.String("10.0.0.0/8")}}
+	for _, withPreprocessing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("preprocess: %t", withPreprocessing), func(t *testing.T) {
+			c := clause
+			if withPreprocessing {
+				c.preprocessed = preprocessClause(c, nil)
+			}
+			assert.True(t, EvaluatorAccessors.ClauseMatchesIPAddress(&c, ldvalue.String("10.1.1.1")))
+			assert.False(t, EvaluatorAccessors.ClauseMatchesIPAddress(&c, ldvalue.String("11.1.1.1")))
+			assert.False(t, EvaluatorAccessors.ClauseMatchesIPAddress(&c, ldvalue.Int(10)))
+		})
+	}
+	assert.False(t, EvaluatorAccessors.ClauseMatchesIPAddress(nil, ldvalue.String("10.1.1.1")))
+	wrongOp := Clause{Op: OperatorIn, Values: clause.Values}
+	assert.False(t, EvaluatorAccessors.ClauseMatchesIPAddress(&wrongOp, ldvalue.String("10.1.1.1")))
+}
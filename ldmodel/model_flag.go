@@ -83,8 +83,8 @@ type FeatureFlag struct {
 	// millisecond timestamp when this mode should expire. Until then, the SDK will send full event data
 	// for each evaluation of this flag.
 	//
-	// The go-server-sdk-evaluation package does not implement that behavior; it is only in the data
-	// model for use by the SDK.
+	// The go-server-sdk-evaluation package does not implement that behavior, but it reports whether
+	// this mode is active at the time of the evaluation in Result.DebugEventsActive.
 	DebugEventsUntilDate ldtime.UnixMillisecondTime
 	// Version is an integer that is incremented by LaunchDarkly every time the configuration of the flag is
 	// changed.
@@ -16,6 +16,23 @@ const (
 	// OperatorContains matches a user value and clause value if they are both strings and the former contains
 	// the latter.
 	OperatorContains Operator = "contains"
+	// OperatorInIgnoreCase matches a user value and clause value if they are both strings and they are
+	// equal when differences in case and in Unicode normalization are ignored. For instance, "Ada@Example.com"
+	// matches "ada@example.com".
+	//
+	// Strings are compared using Unicode canonical caseless matching, so that characters with accents are
+	// equal whether they are represented by a single code point or by a letter and a combining mark; see
+	// TypeConversionMethods.ValueToNormalizedString.
+	OperatorInIgnoreCase Operator = "inIgnoreCase"
+	// OperatorEndsWithIgnoreCase is the same as OperatorEndsWith, except that strings are compared in the
+	// same way as for OperatorInIgnoreCase.
+	OperatorEndsWithIgnoreCase Operator = "endsWithIgnoreCase"
+	// OperatorStartsWithIgnoreCase is the same as OperatorStartsWith, except that strings are compared in
+	// the same way as for OperatorInIgnoreCase.
+	OperatorStartsWithIgnoreCase Operator = "startsWithIgnoreCase"
+	// OperatorContainsIgnoreCase is the same as OperatorContains, except that strings are compared in the
+	// same way as for OperatorInIgnoreCase.
+	OperatorContainsIgnoreCase Operator = "containsIgnoreCase"
 	// OperatorLessThan matches a user value and clause value if they are both numbers and the former < the
 	// latter.
 	OperatorLessThan Operator = "lessThan"
@@ -40,6 +57,22 @@ const (
 	// A valid timestamp is either a string in RFC3339/ISO8601 format, or a number which is treated as Unix
 	// milliseconds.
 	OperatorAfter Operator = "after"
+	// OperatorBeforeRelative matches a user value and clause value if the former is a timestamp, the latter
+	// is a duration, and the timestamp is before the current time plus the duration. For instance, "trial
+	// ends in less than 7 days" could be a clause with the attribute "trialEnd", this operator, and the
+	// value "P7D".
+	//
+	// A valid timestamp is the same as for OperatorBefore. A valid duration is a string that is either an
+	// ISO 8601 duration, such as "P30D" or "-PT1H30M", or a Go duration, such as "720h" or "-1h30m"; see
+	// TypeConversionMethods.ValueToDuration. The current time is determined by the evaluator.
+	OperatorBeforeRelative Operator = "beforeRelative"
+	// OperatorAfterRelative matches a user value and clause value if the former is a timestamp, the latter
+	// is a duration, and the timestamp is after the current time plus the duration. For instance,
+	// "account created within the last 30 days" could be a clause with the attribute "createdAt", this
+	// operator, and the value "-P30D".
+	//
+	// Timestamps and durations are the same as for OperatorBeforeRelative.
+	OperatorAfterRelative Operator = "afterRelative"
 	// OperatorSegmentMatch matches a user if the user is included in the user segment whose key is the clause
 	// value.
 	OperatorSegmentMatch Operator = "segmentMatch"
@@ -64,7 +97,64 @@ const (
 	// version consisting of digits and optional periods in the form "m" (equivalent to m.0.0) or "m.n"
 	// (equivalent to m.n.0).
 	OperatorSemVerGreaterThan Operator = "semVerGreaterThan"
+	// OperatorIPInRange matches a user value and clause value if they are both strings, the former is an
+	// IPv4 or IPv6 address, and the latter is an address range in CIDR notation (such as "10.0.0.0/8" or
+	// "2001:db8::/32") that contains it. A clause value can also be a single address.
+	//
+	// An IPv4 address in IPv4-mapped IPv6 form, such as "::ffff:10.1.2.3", is treated as the equivalent
+	// IPv4 address. An IPv6 zone, such as "%eth0", is ignored.
+	OperatorIPInRange Operator = "ipInRange"
+	// OperatorIPEquals matches a user value and clause value if they are both strings representing the
+	// same IPv4 or IPv6 address. Unlike OperatorIn, this ignores differences in the text representation,
+	// so "2001:db8::1" is equal to "2001:0db8:0:0:0:0:0:1".
+	//
+	// An IPv4 address in IPv4-mapped IPv6 form, such as "::ffff:10.1.2.3", is treated as the equivalent
+	// IPv4 address. An IPv6 zone, such as "%eth0", is ignored.
+	OperatorIPEquals Operator = "ipEquals"
+	// OperatorContainsAll matches a user value if it is an array that contains every one of the clause
+	// values. For instance, "user has all of these entitlements" could be a clause with the attribute
+	// "entitlements", this operator, and the values "reports" and "export".
+	//
+	// Unlike the other operators, which match an array if any of its elements matches, this and the
+	// other array operators test the array as a whole. A user value that is not an array is treated as
+	// an array containing only that value. Elements and clause values are compared in the same way as
+	// for OperatorIn, so a clause value that is an array, an object, or null is never found.
+	OperatorContainsAll Operator = "containsAll"
+	// OperatorContainsNone matches a user value if it is an array that contains none of the clause
+	// values. Arrays are handled in the same way as for OperatorContainsAll.
+	//
+	// As with the other operators, the clause does not match if the user value is null or missing,
+	// unless the clause is negated.
+	OperatorContainsNone Operator = "containsNone"
+	// OperatorSizeGreaterThan matches a user value and clause value if the latter is a number and the
+	// former is an array with more elements than that number. Arrays are handled in the same way as
+	// for OperatorContainsAll, so a user value that is not an array has a size of 1.
+	OperatorSizeGreaterThan Operator = "sizeGreaterThan"
+	// OperatorSizeLessThan matches a user value and clause value if the latter is a number and the
+	// former is an array with fewer elements than that number. Arrays are handled in the same way as
+	// for OperatorContainsAll, so a user value that is not an array has a size of 1.
+	OperatorSizeLessThan Operator = "sizeLessThan"
 )
 
 // Operator describes an operator for a clause.
 type Operator string
+
+// IsBuiltInOperator returns true if the operator is one of the Operator constants defined in this
+// package, all of which are implemented by the evaluator.
+//
+// Any other operator is either unrecognized, in which case a clause that uses it never matches, or
+// is a custom operator; see CustomOperatorPreprocessors.
+func IsBuiltInOperator(op Operator) bool {
+	switch op {
+	case OperatorIn, OperatorEndsWith, OperatorStartsWith, OperatorMatches, OperatorContains,
+		OperatorInIgnoreCase, OperatorEndsWithIgnoreCase, OperatorStartsWithIgnoreCase, OperatorContainsIgnoreCase,
+		OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual,
+		OperatorBefore, OperatorAfter, OperatorBeforeRelative, OperatorAfterRelative, OperatorSegmentMatch,
+		OperatorSemVerEqual, OperatorSemVerLessThan, OperatorSemVerGreaterThan,
+		OperatorIPInRange, OperatorIPEquals, OperatorContainsAll, OperatorContainsNone,
+		OperatorSizeGreaterThan, OperatorSizeLessThan:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,11 +1,19 @@
 package ldmodel
 
 import (
+	"math"
+	"net/netip"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
 	"github.com/launchdarkly/go-semver"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
 )
 
 func parseDateTime(value ldvalue.Value) (time.Time, bool) {
@@ -31,6 +39,42 @@ func parseRegexp(value ldvalue.Value) *regexp.Regexp {
 	return nil
 }
 
+func parseNormalizedString(value ldvalue.Value) (string, bool) {
+	if value.IsString() {
+		return normalizeString(value.StringValue()), true
+	}
+	return "", false
+}
+
+// foldCasers holds Casers created by cases.Fold, since a Caser is not safe for concurrent use but is
+// relatively expensive to create for every string.
+var foldCasers = sync.Pool{ //nolint:gochecknoglobals
+	New: func() interface{} {
+		c := cases.Fold()
+		return &c
+	},
+}
+
+// normalizeString transforms a string so that two strings are equal after normalization if they
+// are equal according to Unicode canonical caseless matching: that is, after they are case-folded
+// and canonically decomposed. The result is in NFC form. For ASCII strings, which are the usual case,
+// this is the same as converting them to lowercase.
+//
+// We use golang.org/x/text for case folding, rather than unicode.SimpleFold or strings.EqualFold,
+// because those only implement simple case folding, which does not treat "ß" and "ss" as equal. The
+// normalization forms are only available from golang.org/x/text anyway.
+func normalizeString(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			caser := foldCasers.Get().(*cases.Caser)
+			folded := caser.String(norm.NFD.String(s))
+			foldCasers.Put(caser)
+			return norm.NFC.String(folded)
+		}
+	}
+	return strings.ToLower(s)
+}
+
 func parseSemVer(value ldvalue.Value) (semver.Version, bool) {
 	if value.IsString() {
 		versionStr := value.StringValue()
@@ -40,3 +84,113 @@ func parseSemVer(value ldvalue.Value) (semver.Version, bool) {
 	}
 	return semver.Version{}, false
 }
+
+func parseIPAddress(value ldvalue.Value) (netip.Addr, bool) {
+	if value.IsString() {
+		if addr, err := netip.ParseAddr(value.StringValue()); err == nil {
+			return addr.WithZone("").Unmap(), true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parseIPRange parses a CIDR range, or a single address which is treated as a range of one address.
+func parseIPRange(value ldvalue.Value) (netip.Prefix, bool) {
+	if value.IsString() {
+		if prefix, err := netip.ParsePrefix(value.StringValue()); err == nil {
+			addr, bits := prefix.Addr(), prefix.Bits()
+			if addr.Is4In6() {
+				addr, bits = addr.Unmap(), bits-96
+				if bits < 0 {
+					return netip.Prefix{}, false
+				}
+			}
+			return netip.PrefixFrom(addr, bits).Masked(), true
+		}
+		if addr, ok := parseIPAddress(value); ok {
+			return netip.PrefixFrom(addr, addr.BitLen()), true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+func parseDuration(value ldvalue.Value) (time.Duration, bool) {
+	if !value.IsString() {
+		return 0, false
+	}
+	s := value.StringValue()
+	if d, ok := parseISO8601Duration(s); ok {
+		return d, true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, true
+	}
+	return 0, false
+}
+
+// parseISO8601Duration parses a duration such as "P1W", "P30D", "PT1H30M", or "-P1DT0.5S". Years and
+// months are not supported, because they do not have a fixed length.
+func parseISO8601Duration(s string) (time.Duration, bool) {
+	negative := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		negative, s = s[0] == '-', s[1:]
+	}
+	if !strings.HasPrefix(s, "P") {
+		return 0, false
+	}
+	s = s[1:]
+	var total float64
+	var lastUnit time.Duration
+	inTime, found := false, false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime {
+				return 0, false
+			}
+			inTime, s = true, s[1:]
+			if s == "" {
+				return 0, false
+			}
+			continue
+		}
+		i := strings.IndexAny(s, "WDHMS")
+		if i <= 0 {
+			return 0, false
+		}
+		if strings.Trim(s[:i], "0123456789.,") != "" {
+			return 0, false // ParseFloat would also accept signs, exponents, "Inf", etc.
+		}
+		n, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+		if err != nil {
+			return 0, false
+		}
+		var unit time.Duration
+		switch {
+		case s[i] == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case s[i] == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case s[i] == 'H' && inTime:
+			unit = time.Hour
+		case s[i] == 'M' && inTime:
+			unit = time.Minute
+		case s[i] == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, false
+		}
+		if found && unit >= lastUnit {
+			return 0, false // each unit may appear only once, from largest to smallest
+		}
+		total += n * float64(unit)
+		lastUnit = unit
+		found, s = true, s[i+1:]
+	}
+	if !found || total > math.MaxInt64 {
+		return 0, false
+	}
+	if negative {
+		return -time.Duration(total), true
+	}
+	return time.Duration(total), true
+}
@@ -19,8 +19,11 @@ type segmentPreprocessedData struct {
 }
 
 type clausePreprocessedData struct {
-	values    []clausePreprocessedValue
-	valuesMap map[jsonPrimitiveValueKey]struct{}
+	values []clausePreprocessedValue
+	// valuesMap is used for OperatorIn, OperatorInIgnoreCase, OperatorContainsAll, etc. The value for
+	// each key is its position among the distinct keys, which ClauseContainsAllValues uses.
+	valuesMap map[jsonPrimitiveValueKey]int
+	ipRanges  *ipRangeSet // used for OperatorIPInRange, OperatorIPEquals
 }
 
 type clausePreprocessedValue struct {
@@ -28,7 +31,10 @@ type clausePreprocessedValue struct {
 	valid        bool
 	parsedRegexp *regexp.Regexp // used for OperatorMatches
 	parsedTime   time.Time      // used for OperatorAfter, OperatorBefore
+	parsedDur    time.Duration  // used for OperatorAfterRelative, OperatorBeforeRelative
 	parsedSemver semver.Version // used for OperatorSemVerEqual, etc.
+	normalized   string         // used for OperatorInIgnoreCase, etc.
+	custom       interface{}    // used for custom operators; see CustomOperatorPreprocessors
 }
 
 type jsonPrimitiveValueKey struct {
@@ -48,13 +54,24 @@ func (j jsonPrimitiveValueKey) isValid() bool {
 // This is called once after a flag is deserialized from JSON, or is created with ldbuilders. If you
 // construct a flag by some other means, you should call PreprocessFlag exactly once before making it
 // available to any other code. The method is not safe for concurrent access across goroutines.
+//
+// This does not preprocess the values of clauses that use custom operators; for that, use
+// PreprocessFlagWithCustomOperators instead.
 func PreprocessFlag(f *FeatureFlag) {
+	PreprocessFlagWithCustomOperators(f, nil)
+}
+
+// PreprocessFlagWithCustomOperators is the same as PreprocessFlag, but also preprocesses the values of
+// clauses that use custom operators, with the specified preprocessors. Since flags that are
+// deserialized from JSON or created with ldbuilders have already been preprocessed without them, it is
+// safe to call this again on such a flag, as long as no other code is using the flag yet.
+func PreprocessFlagWithCustomOperators(f *FeatureFlag, customOperators CustomOperatorPreprocessors) {
 	for i, t := range f.Targets {
 		f.Targets[i].preprocessed.valuesMap = preprocessStringSet(t.Values)
 	}
 	for i, r := range f.Rules {
 		for j, c := range r.Clauses {
-			f.Rules[i].Clauses[j].preprocessed = preprocessClause(c)
+			f.Rules[i].Clauses[j].preprocessed = preprocessClause(c, customOperators)
 		}
 	}
 }
@@ -65,7 +82,18 @@ func PreprocessFlag(f *FeatureFlag) {
 // This is called once after a segment is deserialized from JSON, or is created with ldbuilders. If you
 // construct a segment by some other means, you should call PreprocessSegment exactly once before making
 // it available to any other code. The method is not safe for concurrent access across goroutines.
+//
+// This does not preprocess the values of clauses that use custom operators; for that, use
+// PreprocessSegmentWithCustomOperators instead.
 func PreprocessSegment(s *Segment) {
+	PreprocessSegmentWithCustomOperators(s, nil)
+}
+
+// PreprocessSegmentWithCustomOperators is the same as PreprocessSegment, but also preprocesses the
+// values of clauses that use custom operators, with the specified preprocessors. As with
+// PreprocessFlagWithCustomOperators, it is safe to call this on a segment that was already
+// preprocessed, as long as no other code is using the segment yet.
+func PreprocessSegmentWithCustomOperators(s *Segment, customOperators CustomOperatorPreprocessors) {
 	p := segmentPreprocessedData{}
 	p.includeMap = preprocessStringSet(s.Included)
 	p.excludeMap = preprocessStringSet(s.Excluded)
@@ -79,33 +107,35 @@ func PreprocessSegment(s *Segment) {
 
 	for i, r := range s.Rules {
 		for j, c := range r.Clauses {
-			s.Rules[i].Clauses[j].preprocessed = preprocessClause(c)
+			s.Rules[i].Clauses[j].preprocessed = preprocessClause(c, customOperators)
 		}
 	}
 }
 
-func preprocessClause(c Clause) clausePreprocessedData {
+func preprocessClause(c Clause, customOperators CustomOperatorPreprocessors) clausePreprocessedData {
 	ret := clausePreprocessedData{}
 	switch c.Op {
-	case OperatorIn:
-		// This is a special case where the clause is testing for an exact match against any of the
-		// clause values. As long as the values are primitives, we can use them in a map key (map
-		// keys just can't contain slices or maps), and we can convert this test from a linear search
-		// to a map lookup.
-		if len(c.Values) > 1 { // don't bother if it's empty or has a single value
-			valid := true
-			m := make(map[jsonPrimitiveValueKey]struct{}, len(c.Values))
-			for _, v := range c.Values {
-				if key := asPrimitiveValueKey(v); key.isValid() {
-					m[key] = struct{}{}
-				} else {
-					valid = false
-					break
+	case OperatorIn, OperatorContainsAll, OperatorContainsNone:
+		// These operators test for an exact match against any of the clause values. As long as the
+		// values are primitives, we can use them in a map key (map keys just can't contain slices or
+		// maps), and we can convert this test from a linear search to a map lookup.
+		ret.valuesMap = preprocessValuesMap(c.Values)
+	case OperatorInIgnoreCase, OperatorEndsWithIgnoreCase, OperatorStartsWithIgnoreCase, OperatorContainsIgnoreCase:
+		// The clause values are normalized once here, so that only the context value needs to be
+		// normalized during an evaluation.
+		ret.values = preprocessValues(c.Values, func(v ldvalue.Value) clausePreprocessedValue {
+			s, ok := parseNormalizedString(v)
+			return clausePreprocessedValue{valid: ok, normalized: s}
+		})
+		if c.Op == OperatorInIgnoreCase && len(c.Values) > 1 {
+			// As with OperatorIn, we can use a map lookup; the keys are the normalized strings. Values
+			// that are not strings can never match, so they are left out.
+			ret.valuesMap = make(map[jsonPrimitiveValueKey]int, len(c.Values))
+			for _, p := range ret.values {
+				if p.valid {
+					addToValuesMap(ret.valuesMap, asPrimitiveValueKey(ldvalue.String(p.normalized)))
 				}
 			}
-			if valid {
-				ret.valuesMap = m
-			}
 		}
 	case OperatorMatches:
 		ret.values = preprocessValues(c.Values, func(v ldvalue.Value) clausePreprocessedValue {
@@ -117,12 +147,27 @@ func preprocessClause(c Clause) clausePreprocessedData {
 			t, ok := parseDateTime(v)
 			return clausePreprocessedValue{valid: ok, parsedTime: t}
 		})
+	case OperatorBeforeRelative, OperatorAfterRelative:
+		ret.values = preprocessValues(c.Values, func(v ldvalue.Value) clausePreprocessedValue {
+			d, ok := parseDuration(v)
+			return clausePreprocessedValue{valid: ok, parsedDur: d}
+		})
 	case OperatorSemVerEqual, OperatorSemVerGreaterThan, OperatorSemVerLessThan:
 		ret.values = preprocessValues(c.Values, func(v ldvalue.Value) clausePreprocessedValue {
 			s, ok := parseSemVer(v)
 			return clausePreprocessedValue{valid: ok, parsedSemver: s}
 		})
+	case OperatorIPInRange, OperatorIPEquals:
+		// Rather than parsing each value separately, we build a single sorted set of ranges, so that
+		// a clause with many values does not have to test each of them.
+		ret.ipRanges = newIPRangeSet(c.Values, c.Op == OperatorIPInRange)
 	default:
+		if fn := customOperators.get(c.Op); fn != nil {
+			ret.values = preprocessValues(c.Values, func(v ldvalue.Value) clausePreprocessedValue {
+				custom := fn(v)
+				return clausePreprocessedValue{valid: custom != nil, custom: custom}
+			})
+		}
 	}
 	return ret
 }
@@ -140,6 +185,27 @@ func asPrimitiveValueKey(v ldvalue.Value) jsonPrimitiveValueKey {
 	}
 }
 
+func preprocessValuesMap(values []ldvalue.Value) map[jsonPrimitiveValueKey]int {
+	if len(values) <= 1 { // don't bother if it's empty or has a single value
+		return nil
+	}
+	ret := make(map[jsonPrimitiveValueKey]int, len(values))
+	for _, v := range values {
+		key := asPrimitiveValueKey(v)
+		if !key.isValid() {
+			return nil
+		}
+		addToValuesMap(ret, key)
+	}
+	return ret
+}
+
+func addToValuesMap(valuesMap map[jsonPrimitiveValueKey]int, key jsonPrimitiveValueKey) {
+	if _, ok := valuesMap[key]; !ok {
+		valuesMap[key] = len(valuesMap)
+	}
+}
+
 func preprocessStringSet(valuesIn []string) map[string]struct{} {
 	if len(valuesIn) == 0 {
 		return nil
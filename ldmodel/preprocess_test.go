@@ -57,13 +57,32 @@ func TestPreprocessFlagCreatesClauseValuesMapForMultiValueEqualityTest(t *testin
 	PreprocessFlag(&f)
 
 	m := f.Rules[0].Clauses[0].preprocessed.valuesMap
-	assert.Equal(t, map[jsonPrimitiveValueKey]struct{}{
-		asPrimitiveValueKey(ldvalue.Bool(true)):  {},
-		asPrimitiveValueKey(ldvalue.String("a")): {},
-		asPrimitiveValueKey(ldvalue.Int(0)):      {},
+	assert.Equal(t, map[jsonPrimitiveValueKey]int{
+		asPrimitiveValueKey(ldvalue.Bool(true)):  0,
+		asPrimitiveValueKey(ldvalue.String("a")): 1,
+		asPrimitiveValueKey(ldvalue.Int(0)):      2,
 	}, m)
 }
 
+func TestPreprocessFlagCreatesClauseValuesMapForArrayOperators(t *testing.T) {
+	for _, op := range []Operator{OperatorContainsAll, OperatorContainsNone} {
+		t.Run(string(op), func(t *testing.T) {
+			f := FeatureFlag{
+				Rules: []FlagRule{
+					{Clauses: []Clause{{Op: op, Values: []ldvalue.Value{ldvalue.String("a"), ldvalue.Int(0)}}}},
+				},
+			}
+
+			PreprocessFlag(&f)
+
+			assert.Equal(t, map[jsonPrimitiveValueKey]int{
+				asPrimitiveValueKey(ldvalue.String("a")): 0,
+				asPrimitiveValueKey(ldvalue.Int(0)):      1,
+			}, f.Rules[0].Clauses[0].preprocessed.valuesMap)
+		})
+	}
+}
+
 func TestPreprocessFlagDoesNotCreateClauseValuesMapForSingleValueEqualityTest(t *testing.T) {
 	f := FeatureFlag{
 		Rules: []FlagRule{
@@ -104,7 +123,7 @@ func TestPreprocessFlagDoesNotCreateClauseValuesMapForNonEqualityOperators(t *te
 		OperatorEndsWith, OperatorStartsWith, OperatorMatches, OperatorContains, OperatorLessThan,
 		OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual, OperatorBefore,
 		OperatorAfter, OperatorSegmentMatch, OperatorSemVerEqual, OperatorSemVerLessThan,
-		OperatorSemVerGreaterThan,
+		OperatorSemVerGreaterThan, OperatorSizeGreaterThan, OperatorSizeLessThan,
 	}
 
 	values := []ldvalue.Value{ldvalue.String("a"), ldvalue.String("b")}
@@ -244,6 +263,57 @@ func TestPreprocessFlagParsesClauseSemver(t *testing.T) {
 	}
 }
 
+func TestPreprocessFlagParsesClauseValuesForCustomOperator(t *testing.T) {
+	op := Operator("customOp")
+	customOperators := CustomOperatorPreprocessors{
+		op: func(v ldvalue.Value) interface{} {
+			if v.IsString() {
+				return "parsed " + v.StringValue()
+			}
+			return nil
+		},
+		OperatorIn: func(v ldvalue.Value) interface{} { return "x" }, // ignored, since it is a built-in operator
+	}
+
+	f := FeatureFlag{
+		Rules: []FlagRule{
+			{
+				Clauses: []Clause{
+					{
+						Op:     op,
+						Values: []ldvalue.Value{ldvalue.String("a"), ldvalue.Int(1)},
+					},
+					{
+						Op:     Operator("unregisteredOp"),
+						Values: []ldvalue.Value{ldvalue.String("a")},
+					},
+					{
+						Op:     OperatorIn,
+						Values: []ldvalue.Value{ldvalue.String("a")},
+					},
+				},
+			},
+		},
+	}
+
+	PreprocessFlag(&f)
+	assert.Nil(t, f.Rules[0].Clauses[0].preprocessed.values)
+
+	PreprocessFlagWithCustomOperators(&f, customOperators)
+
+	p := f.Rules[0].Clauses[0].preprocessed.values
+	require.Len(t, p, 2)
+	assert.True(t, p[0].computed)
+	assert.True(t, p[0].valid)
+	assert.Equal(t, "parsed a", p[0].custom)
+	assert.True(t, p[1].computed)
+	assert.False(t, p[1].valid)
+	assert.Nil(t, p[1].custom)
+
+	assert.Nil(t, f.Rules[0].Clauses[1].preprocessed.values)
+	assert.Nil(t, f.Rules[0].Clauses[2].preprocessed.values)
+}
+
 func TestPreprocessSegmentBuildsIncludeAndExcludeMaps(t *testing.T) {
 	s := Segment{
 		Included: []string{"a", "b"},
@@ -45,6 +45,32 @@ func (e TypeConversionMethods) ValueToTimestamp(value ldvalue.Value) (time.Time,
 	return time.Time{}, false
 }
 
+// ValueToDuration attempts to convert a JSON value to a time.Duration, as used by
+// OperatorBeforeRelative and OperatorAfterRelative.
+//
+// If the value is a string, it is parsed either as an ISO 8601 duration, such as "P30D", "PT1H30M",
+// or "P1W", with an optional leading sign, or else with time.ParseDuration, such as "720h" or
+// "-1h30m". ISO 8601 durations can use weeks, days, hours, minutes, and seconds, but not years or
+// months, since those do not have a fixed length. Any other type is invalid.
+//
+// The second return value is true for success or false for failure.
+func (e TypeConversionMethods) ValueToDuration(value ldvalue.Value) (time.Duration, bool) {
+	return parseDuration(value)
+}
+
+// ValueToNormalizedString attempts to convert a JSON value to a normalized string, as used by
+// OperatorInIgnoreCase and the other case-insensitive string operators.
+//
+// If the value is a string, it is case-folded and converted to Unicode normalization form NFC, so
+// that two strings that are equal according to Unicode canonical caseless matching have the same
+// normalized form. For instance, "STRASSE" and "straße" both become "strasse". Any other type is
+// invalid.
+//
+// The second return value is true for success or false for failure.
+func (e TypeConversionMethods) ValueToNormalizedString(value ldvalue.Value) (string, bool) {
+	return parseNormalizedString(value)
+}
+
 // ValueToSemanticVersion attempts to convert a JSON value to a semver.Version.
 //
 // If the value is a string, it is parsed with the parser defined in the semver package. Any
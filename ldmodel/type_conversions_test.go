@@ -1,6 +1,7 @@
 package ldmodel
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -93,3 +94,106 @@ func TestValueToTimestamp(t *testing.T) {
 		}
 	})
 }
+
+func TestValueToNormalizedString(t *testing.T) {
+	for _, p := range []struct {
+		input    string
+		expected string
+	}{
+		{"abc", "abc"},
+		{"Ada@Example.COM", "ada@example.com"},
+		{"Straße", "strasse"},
+		{"CAFÉ", "café"},
+		{"Cafe\u0301", "café"},
+		{"\u212A", "k"}, // Kelvin sign
+	} {
+		t.Run(p.input, func(t *testing.T) {
+			result, ok := TypeConversions.ValueToNormalizedString(ldvalue.String(p.input))
+			assert.True(t, ok)
+			assert.Equal(t, p.expected, result)
+		})
+	}
+
+	for _, value := range []ldvalue.Value{ldvalue.Null(), ldvalue.Bool(true), ldvalue.Int(1), ldvalue.ArrayOf()} {
+		t.Run(value.JSONString(), func(t *testing.T) {
+			_, ok := TypeConversions.ValueToNormalizedString(value)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestValueToNormalizedStringConcurrently(t *testing.T) {
+	// The case folding state is reused, so make sure that concurrent calls don't interfere.
+	inputs := []string{"Straße", "CAFÉ", "Cafe\u0301", "ÅNGSTRÖM"}
+	expected := []string{"strasse", "café", "café", "ångström"}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				k := (i + j) % len(inputs)
+				result, _ := TypeConversions.ValueToNormalizedString(ldvalue.String(inputs[k]))
+				assert.Equal(t, expected[k], result)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestValueToDuration(t *testing.T) {
+	t.Run("valid values", func(t *testing.T) {
+		for _, p := range []struct {
+			input    string
+			expected time.Duration
+		}{
+			{"P30D", 30 * 24 * time.Hour},
+			{"P1W", 7 * 24 * time.Hour},
+			{"PT1H30M", 90 * time.Minute},
+			{"P1DT12H", 36 * time.Hour},
+			{"P1W1DT1H1M1S", 8*24*time.Hour + time.Hour + time.Minute + time.Second},
+			{"PT0.5S", 500 * time.Millisecond},
+			{"PT0,5S", 500 * time.Millisecond},
+			{"-P7D", -7 * 24 * time.Hour},
+			{"+PT1M", time.Minute},
+			{"720h", 720 * time.Hour},
+			{"-1h30m", -90 * time.Minute},
+			{"0s", 0},
+		} {
+			t.Run(p.input, func(t *testing.T) {
+				result, ok := TypeConversions.ValueToDuration(ldvalue.String(p.input))
+				assert.True(t, ok)
+				assert.Equal(t, p.expected, result)
+			})
+		}
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		for _, value := range []ldvalue.Value{
+			ldvalue.Null(),
+			ldvalue.Bool(false),
+			ldvalue.Int(1000),
+			ldvalue.String(""),
+			ldvalue.String("P"),
+			ldvalue.String("PT"),
+			ldvalue.String("P1Y"),
+			ldvalue.String("P1M"),
+			ldvalue.String("PT1D"),
+			ldvalue.String("P1H"),
+			ldvalue.String("P1DT"),
+			ldvalue.String("P-1D"),
+			ldvalue.String("P1e3D"),
+			ldvalue.String("PT1H1H"),
+			ldvalue.String("PT1S1H"),
+			ldvalue.String("P1D1W"),
+			ldvalue.String("PInfD"),
+			ldvalue.String("30 days"),
+			ldvalue.ArrayOf(),
+		} {
+			t.Run(value.JSONString(), func(t *testing.T) {
+				_, ok := TypeConversions.ValueToDuration(value)
+				assert.False(t, ok)
+			})
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package ldmodel
+
+import (
+	"fmt"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+// ValidationSeverity describes how serious a problem is that was found by ValidateFlag or ValidateSegment.
+type ValidationSeverity string
+
+const (
+	// ValidationSeverityError means that evaluating the flag could fail with a MALFORMED_FLAG error, or
+	// that the configuration can never behave as intended.
+	ValidationSeverityError ValidationSeverity = "error"
+	// ValidationSeverityWarning means that part of the configuration will be silently ignored by the
+	// evaluator: for instance, a clause value that is not valid for the clause's operator, which will
+	// never match anything.
+	ValidationSeverityWarning ValidationSeverity = "warning"
+)
+
+// ValidationFinding describes a problem found by ValidateFlag or ValidateSegment.
+type ValidationFinding struct {
+	// Path identifies the part of the configuration that has the problem. It is similar to a JSON
+	// Pointer (RFC 6901) into the JSON representation of the flag or segment, such as
+	// "/rules/0/clauses/1/values/2". An empty path refers to the flag or segment as a whole.
+	Path string
+	// Severity is ValidationSeverityError or ValidationSeverityWarning.
+	Severity ValidationSeverity
+	// Message is a human-readable description of the problem.
+	Message string
+}
+
+// String returns a description of the finding in the format "severity at path: message".
+func (f ValidationFinding) String() string {
+	return fmt.Sprintf("%s at %q: %s", f.Severity, f.Path, f.Message)
+}
+
+// ValidateFlag checks a flag configuration for problems that would otherwise only be found, or would
+// be silently ignored, when the flag is evaluated. It returns nil if there are no problems.
+//
+// This only checks the flag itself: it cannot tell whether a prerequisite flag or a segment that the
+// flag refers to exists, or whether the prerequisite's variation index is valid. A clause operator
+// that is not built in is reported as a warning unless customOperators has a preprocessor for it,
+// since the validator has no other way to know about custom operators; customOperators can be nil.
+func ValidateFlag(flag *FeatureFlag, customOperators CustomOperatorPreprocessors) []ValidationFinding {
+	v := validator{numVariations: len(flag.Variations), customOperators: customOperators}
+	for i, p := range flag.Prerequisites {
+		if p.Key == flag.Key {
+			v.errorf(fmt.Sprintf("/prerequisites/%d/key", i), "flag %q cannot be a prerequisite of itself", p.Key)
+		}
+	}
+	v.validateTargets("/targets", flag.Targets)
+	v.validateTargets("/contextTargets", flag.ContextTargets)
+	for i, r := range flag.Rules {
+		path := fmt.Sprintf("/rules/%d", i)
+		v.validateClauses(path, r.Clauses)
+		v.validateVariationOrRollout(path, r.VariationOrRollout)
+	}
+	v.validateVariationOrRollout("/fallthrough", flag.Fallthrough)
+	if flag.OffVariation.IsDefined() {
+		v.validateVariationIndex("/offVariation", flag.OffVariation.IntValue())
+	}
+	return v.findings
+}
+
+// ValidateSegment checks a segment configuration for problems that would otherwise only be found, or
+// would be silently ignored, when the segment is evaluated. It returns nil if there are no problems.
+//
+// As with ValidateFlag, this cannot tell whether any segments that are referenced by segmentMatch
+// clauses exist, and customOperators determines which custom operators are known.
+func ValidateSegment(segment *Segment, customOperators CustomOperatorPreprocessors) []ValidationFinding {
+	v := validator{customOperators: customOperators}
+	for i, r := range segment.Rules {
+		path := fmt.Sprintf("/rules/%d", i)
+		v.validateClauses(path, r.Clauses)
+		if r.Weight.IsDefined() {
+			if w := r.Weight.IntValue(); w < 0 || w > 100000 {
+				v.warnf(path+"/weight", "weight %d is not in the range 0-100000", w)
+			}
+			v.validateBucketBy(path+"/bucketBy", r.BucketBy)
+		}
+	}
+	return v.findings
+}
+
+type validator struct {
+	numVariations   int
+	customOperators CustomOperatorPreprocessors
+	findings        []ValidationFinding
+}
+
+func (v *validator) errorf(path, format string, args ...interface{}) {
+	v.findings = append(v.findings, ValidationFinding{Path: path, Severity: ValidationSeverityError,
+		Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(path, format string, args ...interface{}) {
+	v.findings = append(v.findings, ValidationFinding{Path: path, Severity: ValidationSeverityWarning,
+		Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validateVariationIndex(path string, index int) {
+	if index < 0 || index >= v.numVariations {
+		v.errorf(path, "variation index %d is out of range (flag has %d variations)", index, v.numVariations)
+	}
+}
+
+func (v *validator) validateTargets(path string, targets []Target) {
+	for i, t := range targets {
+		v.validateVariationIndex(fmt.Sprintf("%s/%d/variation", path, i), t.Variation)
+	}
+}
+
+func (v *validator) validateVariationOrRollout(path string, vr VariationOrRollout) {
+	if vr.Variation.IsDefined() {
+		v.validateVariationIndex(path+"/variation", vr.Variation.IntValue())
+		return
+	}
+	if len(vr.Rollout.Variations) == 0 {
+		v.errorf(path, "must have either a variation or a rollout with at least one bucket")
+		return
+	}
+	totalWeight := 0
+	for i, wv := range vr.Rollout.Variations {
+		bucketPath := fmt.Sprintf("%s/rollout/variations/%d", path, i)
+		v.validateVariationIndex(bucketPath+"/variation", wv.Variation)
+		if wv.Weight < 0 {
+			v.warnf(bucketPath+"/weight", "weight %d is negative", wv.Weight)
+		}
+		totalWeight += wv.Weight
+	}
+	if totalWeight != 100000 {
+		v.warnf(path+"/rollout/variations", "weights add up to %d rather than 100000", totalWeight)
+	}
+	if !vr.Rollout.IsExperiment() { // experiments always bucket by key, so bucketBy is ignored
+		v.validateBucketBy(path+"/rollout/bucketBy", vr.Rollout.BucketBy)
+	}
+}
+
+func (v *validator) validateBucketBy(path string, attr ldattr.Ref) {
+	if attr.IsDefined() && attr.Err() != nil {
+		v.errorf(path, "invalid attribute reference %q: %s", attr.String(), attr.Err())
+	}
+}
+
+func (v *validator) validateClauses(rulePath string, clauses []Clause) {
+	for i, c := range clauses {
+		path := fmt.Sprintf("%s/clauses/%d", rulePath, i)
+		if c.Op != OperatorSegmentMatch {
+			if !c.Attribute.IsDefined() {
+				v.errorf(path+"/attribute", "attribute must not be empty")
+			} else if c.Attribute.Err() != nil {
+				v.errorf(path+"/attribute", "invalid attribute reference %q: %s", c.Attribute.String(),
+					c.Attribute.Err())
+			}
+		}
+		if !IsBuiltInOperator(c.Op) && v.customOperators.get(c.Op) == nil {
+			v.warnf(path+"/op", "unknown operator %q; this clause will never match", c.Op)
+			continue
+		}
+		if len(c.Values) == 0 {
+			v.warnf(path+"/values", "clause has no values; it will never match")
+		}
+		for j, value := range c.Values {
+			if problem := v.checkClauseValue(c.Op, value); problem != "" {
+				v.warnf(fmt.Sprintf("%s/values/%d", path, j), "%s; this value will never match", problem)
+			}
+		}
+	}
+}
+
+// checkClauseValue returns a description of why the value can never be matched by the operator, or
+// an empty string if it is valid.
+func (v *validator) checkClauseValue(op Operator, value ldvalue.Value) string {
+	switch op {
+	case OperatorIn:
+		return ""
+	case OperatorContainsAll, OperatorContainsNone:
+		if !asPrimitiveValueKey(value).isValid() {
+			return fmt.Sprintf("%s is not a string, number, or boolean", value.JSONString())
+		}
+	case OperatorMatches:
+		if parseRegexp(value) == nil {
+			return fmt.Sprintf("%s is not a valid regular expression", value.JSONString())
+		}
+	case OperatorBefore, OperatorAfter:
+		if _, ok := parseDateTime(value); !ok {
+			return fmt.Sprintf("%s is not a valid timestamp", value.JSONString())
+		}
+	case OperatorBeforeRelative, OperatorAfterRelative:
+		if _, ok := parseDuration(value); !ok {
+			return fmt.Sprintf("%s is not a valid duration", value.JSONString())
+		}
+	case OperatorSemVerEqual, OperatorSemVerLessThan, OperatorSemVerGreaterThan:
+		if _, ok := parseSemVer(value); !ok {
+			return fmt.Sprintf("%s is not a valid semantic version", value.JSONString())
+		}
+	case OperatorIPInRange:
+		if _, ok := parseIPRange(value); !ok {
+			return fmt.Sprintf("%s is not a valid IP address or CIDR range", value.JSONString())
+		}
+	case OperatorIPEquals:
+		if _, ok := parseIPAddress(value); !ok {
+			return fmt.Sprintf("%s is not a valid IP address", value.JSONString())
+		}
+	case OperatorLessThan, OperatorLessThanOrEqual, OperatorGreaterThan, OperatorGreaterThanOrEqual,
+		OperatorSizeGreaterThan, OperatorSizeLessThan:
+		if !value.IsNumber() {
+			return fmt.Sprintf("%s is not a number", value.JSONString())
+		}
+	case OperatorEndsWith, OperatorStartsWith, OperatorContains, OperatorSegmentMatch,
+		OperatorInIgnoreCase, OperatorEndsWithIgnoreCase, OperatorStartsWithIgnoreCase, OperatorContainsIgnoreCase:
+		if !value.IsString() {
+			return fmt.Sprintf("%s is not a string", value.JSONString())
+		}
+	default:
+		if fn := v.customOperators.get(op); fn != nil && fn(value) == nil {
+			return fmt.Sprintf("%s is not valid for operator %q", value.JSONString(), op)
+		}
+	}
+	return ""
+}
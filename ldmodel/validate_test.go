@@ -0,0 +1,189 @@
+package ldmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldattr"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+)
+
+func makeValidFlagForValidation() FeatureFlag {
+	return FeatureFlag{
+		Key:           "flag",
+		Prerequisites: []Prerequisite{{Key: "other", Variation: 5}},
+		Targets:       []Target{{Values: []string{"a"}, Variation: 1}},
+		Rules: []FlagRule{
+			{
+				VariationOrRollout: VariationOrRollout{Variation: ldvalue.NewOptionalInt(0)},
+				Clauses: []Clause{
+					{Attribute: ldattr.NewRef("name"), Op: OperatorMatches, Values: []ldvalue.Value{ldvalue.String("^a")}},
+					{Op: OperatorSegmentMatch, Values: []ldvalue.Value{ldvalue.String("segment")}},
+				},
+			},
+		},
+		Fallthrough: VariationOrRollout{Rollout: Rollout{
+			Variations: []WeightedVariation{{Variation: 0, Weight: 60000}, {Variation: 1, Weight: 40000}},
+			BucketBy:   ldattr.NewRef("email"),
+		}},
+		OffVariation: ldvalue.NewOptionalInt(1),
+		Variations:   []ldvalue.Value{ldvalue.Bool(false), ldvalue.Bool(true)},
+	}
+}
+
+func TestValidateValidFlag(t *testing.T) {
+	f := makeValidFlagForValidation()
+	assert.Nil(t, ValidateFlag(&f, nil))
+}
+
+func TestValidateFlagErrors(t *testing.T) {
+	for _, p := range []struct {
+		name         string
+		modify       func(*FeatureFlag)
+		expectedPath string
+	}{
+		{"off variation out of range", func(f *FeatureFlag) { f.OffVariation = ldvalue.NewOptionalInt(2) },
+			"/offVariation"},
+		{"target variation out of range", func(f *FeatureFlag) { f.Targets[0].Variation = -1 },
+			"/targets/0/variation"},
+		{"context target variation out of range",
+			func(f *FeatureFlag) { f.ContextTargets = []Target{{Variation: 0}, {Variation: 3}} },
+			"/contextTargets/1/variation"},
+		{"rule variation out of range", func(f *FeatureFlag) { f.Rules[0].Variation = ldvalue.NewOptionalInt(2) },
+			"/rules/0/variation"},
+		{"rule with no variation or rollout", func(f *FeatureFlag) { f.Rules[0].Variation = ldvalue.OptionalInt{} },
+			"/rules/0"},
+		{"rollout bucket variation out of range", func(f *FeatureFlag) { f.Fallthrough.Rollout.Variations[1].Variation = 2 },
+			"/fallthrough/rollout/variations/1/variation"},
+		{"invalid rollout bucketBy", func(f *FeatureFlag) { f.Fallthrough.Rollout.BucketBy = ldattr.NewRef("///") },
+			"/fallthrough/rollout/bucketBy"},
+		{"empty clause attribute", func(f *FeatureFlag) { f.Rules[0].Clauses[0].Attribute = ldattr.Ref{} },
+			"/rules/0/clauses/0/attribute"},
+		{"invalid clause attribute", func(f *FeatureFlag) { f.Rules[0].Clauses[0].Attribute = ldattr.NewRef("///") },
+			"/rules/0/clauses/0/attribute"},
+		{"prerequisite of itself", func(f *FeatureFlag) { f.Prerequisites[0].Key = f.Key },
+			"/prerequisites/0/key"},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			f := makeValidFlagForValidation()
+			p.modify(&f)
+			findings := ValidateFlag(&f, nil)
+			if assert.Len(t, findings, 1) {
+				assert.Equal(t, p.expectedPath, findings[0].Path)
+				assert.Equal(t, ValidationSeverityError, findings[0].Severity)
+			}
+		})
+	}
+}
+
+func TestValidateFlagWarnings(t *testing.T) {
+	for _, p := range []struct {
+		name         string
+		modify       func(*FeatureFlag)
+		expectedPath string
+	}{
+		{"rollout weights do not add up", func(f *FeatureFlag) { f.Fallthrough.Rollout.Variations[1].Weight = 1 },
+			"/fallthrough/rollout/variations"},
+		{"unknown operator", func(f *FeatureFlag) { f.Rules[0].Clauses[0].Op = "unknown" },
+			"/rules/0/clauses/0/op"},
+		{"clause with no values", func(f *FeatureFlag) { f.Rules[0].Clauses[0].Values = nil },
+			"/rules/0/clauses/0/values"},
+		{"invalid regex", func(f *FeatureFlag) { f.Rules[0].Clauses[0].Values[0] = ldvalue.String("(") },
+			"/rules/0/clauses/0/values/0"},
+		{"invalid semver", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorSemVerEqual
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("1.0.0"), ldvalue.String("x")}
+		}, "/rules/0/clauses/0/values/1"},
+		{"invalid date", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorBefore
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.Int(1000), ldvalue.String("yesterday")}
+		}, "/rules/0/clauses/0/values/1"},
+		{"invalid duration", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorAfterRelative
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("-P30D"), ldvalue.String("30 days")}
+		}, "/rules/0/clauses/0/values/1"},
+		{"non-numeric value for numeric operator", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorLessThan
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("3")}
+		}, "/rules/0/clauses/0/values/0"},
+		{"non-string value for case-insensitive operator", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorInIgnoreCase
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("a"), ldvalue.Int(1)}
+		}, "/rules/0/clauses/0/values/1"},
+		{"non-primitive value for containsAll", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorContainsAll
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("a"), ldvalue.ArrayOf(ldvalue.String("b"))}
+		}, "/rules/0/clauses/0/values/1"},
+		{"non-numeric value for size operator", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorSizeGreaterThan
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("3")}
+		}, "/rules/0/clauses/0/values/0"},
+		{"invalid IP range", func(f *FeatureFlag) {
+			f.Rules[0].Clauses[0].Op = OperatorIPInRange
+			f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("10.0.0.0/8"), ldvalue.String("10.0.0.0/33")}
+		}, "/rules/0/clauses/0/values/1"},
+		{"non-string segment key", func(f *FeatureFlag) { f.Rules[0].Clauses[1].Values[0] = ldvalue.Int(1) },
+			"/rules/0/clauses/1/values/0"},
+	} {
+		t.Run(p.name, func(t *testing.T) {
+			f := makeValidFlagForValidation()
+			p.modify(&f)
+			findings := ValidateFlag(&f, nil)
+			if assert.Len(t, findings, 1) {
+				assert.Equal(t, p.expectedPath, findings[0].Path)
+				assert.Equal(t, ValidationSeverityWarning, findings[0].Severity)
+			}
+		})
+	}
+}
+
+func TestValidateFlagWithCustomOperator(t *testing.T) {
+	op := Operator("validateTestOp")
+	customOperators := CustomOperatorPreprocessors{op: func(v ldvalue.Value) interface{} {
+		if v.IsString() {
+			return v.StringValue()
+		}
+		return nil
+	}}
+
+	f := makeValidFlagForValidation()
+	f.Rules[0].Clauses[0].Op = op
+	f.Rules[0].Clauses[0].Values = []ldvalue.Value{ldvalue.String("a"), ldvalue.Int(1)}
+
+	assert.Equal(t, []ValidationFinding{{Path: "/rules/0/clauses/0/values/1", Severity: ValidationSeverityWarning,
+		Message: `1 is not valid for operator "validateTestOp"; this value will never match`}},
+		ValidateFlag(&f, customOperators))
+
+	assert.Equal(t, []ValidationFinding{{Path: "/rules/0/clauses/0/op", Severity: ValidationSeverityWarning,
+		Message: `unknown operator "validateTestOp"; this clause will never match`}}, ValidateFlag(&f, nil))
+}
+
+func TestValidateSegment(t *testing.T) {
+	s := Segment{
+		Key: "segment",
+		Rules: []SegmentRule{
+			{Clauses: []Clause{{Attribute: ldattr.NewRef("name"), Op: OperatorIn, Values: []ldvalue.Value{ldvalue.Null()}}}},
+			{Weight: ldvalue.NewOptionalInt(50000), BucketBy: ldattr.NewRef("email")},
+		},
+	}
+	assert.Nil(t, ValidateSegment(&s, nil))
+
+	s.Rules[0].Clauses[0].Attribute = ldattr.Ref{}
+	s.Rules[1].Weight = ldvalue.NewOptionalInt(100001)
+	s.Rules[1].BucketBy = ldattr.NewRef("///")
+	findings := ValidateSegment(&s, nil)
+	if assert.Len(t, findings, 3) {
+		assert.Equal(t, "/rules/0/clauses/0/attribute", findings[0].Path)
+		assert.Equal(t, ValidationSeverityError, findings[0].Severity)
+		assert.Equal(t, "/rules/1/weight", findings[1].Path)
+		assert.Equal(t, ValidationSeverityWarning, findings[1].Severity)
+		assert.Equal(t, "/rules/1/bucketBy", findings[2].Path)
+		assert.Equal(t, ValidationSeverityError, findings[2].Severity)
+	}
+}
+
+func TestValidationFindingString(t *testing.T) {
+	f := ValidationFinding{Path: "/offVariation", Severity: ValidationSeverityError, Message: "bad"}
+	assert.Equal(t, `error at "/offVariation": bad`, f.String())
+}
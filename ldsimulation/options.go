@@ -0,0 +1,38 @@
+package ldsimulation
+
+import "runtime"
+
+// Option is an optional parameter for Run, Compare, or CompareRollouts.
+type Option interface {
+	apply(c *config)
+}
+
+type config struct {
+	concurrency int
+}
+
+func makeConfig(options []Option) config {
+	c := config{concurrency: runtime.GOMAXPROCS(0)}
+	for _, o := range options {
+		if o != nil {
+			o.apply(&c)
+		}
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	return c
+}
+
+type optionConcurrency struct{ concurrency int }
+
+// OptionConcurrency is an option for Run, Compare, or CompareRollouts that specifies how many goroutines
+// should be used to evaluate the contexts. The default is runtime.GOMAXPROCS(0). Values less than 1 are
+// treated as 1.
+func OptionConcurrency(concurrency int) Option {
+	return optionConcurrency{concurrency: concurrency}
+}
+
+func (o optionConcurrency) apply(c *config) {
+	c.concurrency = o.concurrency
+}
@@ -0,0 +1,8 @@
+// Package ldsimulation runs a population of evaluation contexts through a feature flag and summarizes
+// the results.
+//
+// This is meant for estimating the effect of a flag configuration change before making it: for
+// instance, how many contexts would be served each variation after a rollout percentage is changed,
+// or which contexts would be matched by a new rule. It is built on the evaluation.Evaluator, so the
+// results are exactly the same as what real evaluations of the same flag data would produce.
+package ldsimulation
@@ -0,0 +1,37 @@
+package ldsimulation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+)
+
+// ReadContexts parses a corpus of evaluation contexts in JSON Lines format: that is, one JSON object
+// per line, each of which is in the same format that ldcontext.Context uses for JSON unmarshaling.
+// Old-style user JSON is also accepted. Blank lines are ignored.
+//
+// If any line cannot be parsed, ReadContexts returns an error that includes the line number.
+func ReadContexts(r io.Reader) ([]ldcontext.Context, error) {
+	var ret []ldcontext.Context
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) != 0 {
+			var c ldcontext.Context
+			if jsonErr := json.Unmarshal(trimmed, &c); jsonErr != nil {
+				return nil, fmt.Errorf("invalid context on line %d: %w", lineNum, jsonErr)
+			}
+			ret = append(ret, c)
+		}
+		if err == io.EOF {
+			return ret, nil
+		}
+	}
+}
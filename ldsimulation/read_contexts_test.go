@@ -0,0 +1,44 @@
+package ldsimulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadContexts(t *testing.T) {
+	input := `{"kind": "user", "key": "a", "name": "Lucy"}
+
+{"kind": "multi", "user": {"key": "b"}, "org": {"key": "c"}}
+{"key": "d", "email": "d@example.com"}`
+
+	contexts, err := ReadContexts(strings.NewReader(input))
+
+	require.NoError(t, err)
+	assert.Equal(t, []ldcontext.Context{
+		ldcontext.NewBuilder("a").Name("Lucy").Build(),
+		ldcontext.NewMulti(ldcontext.New("b"), ldcontext.NewWithKind("org", "c")),
+		ldcontext.NewBuilder("d").SetString("email", "d@example.com").Build(),
+	}, contexts)
+}
+
+func TestReadContextsWithTrailingNewline(t *testing.T) {
+	contexts, err := ReadContexts(strings.NewReader("{\"kind\": \"user\", \"key\": \"a\"}\n"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []ldcontext.Context{ldcontext.New("a")}, contexts)
+}
+
+func TestReadContextsReportsLineNumberOfError(t *testing.T) {
+	input := `{"kind": "user", "key": "a"}
+{"kind": "user", "key": ""}`
+
+	_, err := ReadContexts(strings.NewReader(input))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
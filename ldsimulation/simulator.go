@@ -0,0 +1,231 @@
+package ldsimulation
+
+import (
+	"sync"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	evaluation "github.com/launchdarkly/go-server-sdk-evaluation/v3"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+)
+
+// Report is the aggregated result of evaluating a flag for every context in a corpus. It is returned
+// by Run.
+type Report struct {
+	// FlagKey and FlagVersion identify the flag that was evaluated.
+	FlagKey     string
+	FlagVersion int
+
+	// Total is the number of contexts that were evaluated.
+	Total int
+
+	// Variations is the number of contexts that received each variation, keyed by variation index.
+	Variations map[int]int
+
+	// NoVariation is the number of contexts for which the result had no variation index: either
+	// because of an error, or because the flag was off and had no off variation.
+	NoVariation int
+
+	// ReasonKinds is the number of contexts for each kind of evaluation reason.
+	ReasonKinds map[ldreason.EvalReasonKind]int
+
+	// Rules is the number of contexts that were matched by each flag rule, keyed by rule index. It is
+	// not keyed by rule ID, because rule IDs are optional and are not guaranteed to be unique.
+	Rules map[int]RuleMatches
+
+	// Errors is the number of contexts for which evaluation failed, keyed by the kind of error.
+	Errors map[ldreason.EvalErrorKind]int
+
+	// ExperimentVariations is the number of contexts that were placed in an experiment, keyed by the
+	// variation index of the experiment bucket they were assigned to. These contexts are also counted
+	// in Variations.
+	ExperimentVariations map[int]int
+}
+
+// RuleMatches is the number of contexts that were matched by a flag rule. It is part of Report.
+type RuleMatches struct {
+	// RuleID is the rule's ID, if any.
+	RuleID string
+	// Count is the number of contexts that were matched by the rule.
+	Count int
+}
+
+// Comparison is the result of evaluating two versions of the same flag for every context in a corpus.
+// It is returned by Compare.
+type Comparison struct {
+	// Before and After are the reports for each version of the flag.
+	Before Report
+	After  Report
+
+	// Transitions is the number of contexts that switched from one variation to another, keyed by the
+	// pair of variations. Contexts that received the same variation from both versions are not counted.
+	Transitions map[Transition]int
+
+	// Changes describes every context that switched from one variation to another, in the same order
+	// as the contexts in the corpus.
+	Changes []Change
+}
+
+// Transition describes a change from one variation index to another. Either of the indexes may be
+// undefined, if the result had no variation.
+type Transition struct {
+	From ldvalue.OptionalInt
+	To   ldvalue.OptionalInt
+}
+
+// Change describes a context that received a different variation from two versions of a flag.
+type Change struct {
+	// Index is the position of the context in the corpus.
+	Index   int
+	Context ldcontext.Context
+	Before  ldreason.EvaluationDetail
+	After   ldreason.EvaluationDetail
+}
+
+// Run evaluates a flag for every context in the corpus, and returns the aggregated results.
+//
+// The contexts are evaluated concurrently; see OptionConcurrency. The evaluator must be configured
+// with a DataProvider that can provide any prerequisite flags or segments that the flag references.
+// No prerequisite events are recorded.
+func Run(
+	evaluator evaluation.Evaluator,
+	flag *ldmodel.FeatureFlag,
+	contexts []ldcontext.Context,
+	options ...Option,
+) Report {
+	results := evaluateAll(evaluator, flag, contexts, makeConfig(options))
+	return makeReport(flag, results)
+}
+
+// Compare evaluates two versions of the same flag for every context in the corpus, and returns the
+// aggregated results for each as well as a description of which contexts would switch variations.
+//
+// The parameters have the same meaning as for Run.
+func Compare(
+	evaluator evaluation.Evaluator,
+	before, after *ldmodel.FeatureFlag,
+	contexts []ldcontext.Context,
+	options ...Option,
+) Comparison {
+	config := makeConfig(options)
+	beforeResults := evaluateAll(evaluator, before, contexts, config)
+	afterResults := evaluateAll(evaluator, after, contexts, config)
+	ret := Comparison{
+		Before:      makeReport(before, beforeResults),
+		After:       makeReport(after, afterResults),
+		Transitions: make(map[Transition]int),
+	}
+	for i := range contexts {
+		b, a := beforeResults[i].Detail, afterResults[i].Detail
+		if b.VariationIndex == a.VariationIndex {
+			continue
+		}
+		ret.Transitions[Transition{From: b.VariationIndex, To: a.VariationIndex}]++
+		ret.Changes = append(ret.Changes, Change{Index: i, Context: contexts[i], Before: b, After: a})
+	}
+	return ret
+}
+
+// CompareRollouts measures how many of the contexts in the corpus would receive a different variation
+// if a percentage rollout or experiment were changed from one configuration to another. It is the
+// counterpart of evaluation.EstimateRolloutImpact for a specific set of contexts.
+//
+// It works by evaluating two flags whose fallthrough is the specified rollout, so the results are
+// exactly what the evaluator would produce, including any options such as
+// evaluation.EvaluatorOptionEnableSecondaryKey. The variation values of these flags are their indexes.
+// In the returned Comparison, every result has the reason FALLTHROUGH; the reports identify the
+// flags by RolloutSpec.FlagKey, and have a FlagVersion of 1 for "before" and 2 for "after".
+func CompareRollouts(
+	evaluator evaluation.Evaluator,
+	before, after evaluation.RolloutSpec,
+	contexts []ldcontext.Context,
+	options ...Option,
+) Comparison {
+	beforeFlag, afterFlag := makeRolloutFlag(before, 1), makeRolloutFlag(after, 2)
+	return Compare(evaluator, &beforeFlag, &afterFlag, contexts, options...)
+}
+
+func makeRolloutFlag(spec evaluation.RolloutSpec, version int) ldmodel.FeatureFlag {
+	numVariations := 0
+	for _, wv := range spec.Rollout.Variations {
+		if wv.Variation >= numVariations {
+			numVariations = wv.Variation + 1
+		}
+	}
+	variations := make([]ldvalue.Value, numVariations)
+	for i := range variations {
+		variations[i] = ldvalue.Int(i)
+	}
+	return ldbuilders.NewFlagBuilder(spec.FlagKey).Version(version).Salt(spec.Salt).On(true).
+		Fallthrough(ldmodel.VariationOrRollout{Rollout: spec.Rollout}).Variations(variations...).Build()
+}
+
+func evaluateAll(
+	evaluator evaluation.Evaluator,
+	flag *ldmodel.FeatureFlag,
+	contexts []ldcontext.Context,
+	config config,
+) []evaluation.Result {
+	results := make([]evaluation.Result, len(contexts))
+	evaluate := func(context ldcontext.Context) evaluation.Result {
+		return evaluator.Evaluate(flag, context, nil)
+	}
+	if p, ok := evaluator.(evaluation.PreparingEvaluator); ok {
+		prepared := p.Prepare(flag)
+		evaluate = func(context ldcontext.Context) evaluation.Result {
+			return prepared.Evaluate(context, nil)
+		}
+	}
+	// Each worker takes every nth context, so that they never write to the same element of results.
+	var wg sync.WaitGroup
+	for w := 0; w < config.concurrency && w < len(contexts); w++ {
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			for i := start; i < len(contexts); i += config.concurrency {
+				results[i] = evaluate(contexts[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+	return results
+}
+
+func makeReport(flag *ldmodel.FeatureFlag, results []evaluation.Result) Report {
+	r := Report{
+		FlagKey:              flag.Key,
+		FlagVersion:          flag.Version,
+		Total:                len(results),
+		Variations:           make(map[int]int),
+		ReasonKinds:          make(map[ldreason.EvalReasonKind]int),
+		Rules:                make(map[int]RuleMatches),
+		Errors:               make(map[ldreason.EvalErrorKind]int),
+		ExperimentVariations: make(map[int]int),
+	}
+	for _, result := range results {
+		detail := result.Detail
+		if detail.VariationIndex.IsDefined() {
+			r.Variations[detail.VariationIndex.IntValue()]++
+			// Result.IsExperiment is also true if the flag tracks events for this reason, so we check
+			// the reason instead.
+			if detail.Reason.IsInExperiment() {
+				r.ExperimentVariations[detail.VariationIndex.IntValue()]++
+			}
+		} else {
+			r.NoVariation++
+		}
+		r.ReasonKinds[detail.Reason.GetKind()]++
+		switch detail.Reason.GetKind() {
+		case ldreason.EvalReasonRuleMatch:
+			m := r.Rules[detail.Reason.GetRuleIndex()]
+			m.RuleID = detail.Reason.GetRuleID()
+			m.Count++
+			r.Rules[detail.Reason.GetRuleIndex()] = m
+		case ldreason.EvalReasonError:
+			r.Errors[detail.Reason.GetErrorKind()]++
+		}
+	}
+	return r
+}
@@ -0,0 +1,202 @@
+package ldsimulation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/launchdarkly/go-sdk-common/v3/ldcontext"
+	"github.com/launchdarkly/go-sdk-common/v3/ldreason"
+	"github.com/launchdarkly/go-sdk-common/v3/ldvalue"
+	evaluation "github.com/launchdarkly/go-server-sdk-evaluation/v3"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldbuilders"
+	"github.com/launchdarkly/go-server-sdk-evaluation/v3/ldmodel"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type simpleDataProvider struct{}
+
+func (d simpleDataProvider) GetFeatureFlag(key string) *ldmodel.FeatureFlag { return nil }
+func (d simpleDataProvider) GetSegment(key string) *ldmodel.Segment         { return nil }
+
+func makeTestContexts(n int) []ldcontext.Context {
+	ret := make([]ldcontext.Context, 0, n)
+	for i := 0; i < n; i++ {
+		builder := ldcontext.NewBuilder(fmt.Sprintf("user%d", i))
+		if i%10 == 0 {
+			builder.SetBool("beta", true)
+		}
+		ret = append(ret, builder.Build())
+	}
+	return ret
+}
+
+func makeTestFlag(rolloutWeight int) ldmodel.FeatureFlag {
+	return ldbuilders.NewFlagBuilder("flag").Version(1).On(true).
+		AddRule(ldbuilders.NewRuleBuilder().ID("beta-rule").Variation(2).
+			Clauses(ldbuilders.Clause("beta", ldmodel.OperatorIn, ldvalue.Bool(true)))).
+		Fallthrough(ldbuilders.Rollout(
+			ldbuilders.Bucket(0, 100000-rolloutWeight),
+			ldbuilders.Bucket(1, rolloutWeight),
+		)).
+		Variations(ldvalue.String("a"), ldvalue.String("b"), ldvalue.String("beta")).
+		Build()
+}
+
+func TestRun(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	flag := makeTestFlag(50000)
+	contexts := append(makeTestContexts(1000), ldcontext.New(""))
+
+	report := Run(evaluator, &flag, contexts, OptionConcurrency(4))
+
+	assert.Equal(t, flag.Key, report.FlagKey)
+	assert.Equal(t, flag.Version, report.FlagVersion)
+	assert.Equal(t, 1001, report.Total)
+	assert.Equal(t, 1, report.NoVariation)
+	assert.Equal(t, 100, report.Variations[2])
+	assert.Equal(t, 900, report.Variations[0]+report.Variations[1])
+	assert.InDelta(t, 450, report.Variations[1], 50)
+	assert.Equal(t, map[ldreason.EvalReasonKind]int{
+		ldreason.EvalReasonRuleMatch:   100,
+		ldreason.EvalReasonFallthrough: 900,
+		ldreason.EvalReasonError:       1,
+	}, report.ReasonKinds)
+	assert.Equal(t, map[int]RuleMatches{0: {RuleID: "beta-rule", Count: 100}}, report.Rules)
+	assert.Equal(t, map[ldreason.EvalErrorKind]int{ldreason.EvalErrorUserNotSpecified: 1}, report.Errors)
+	assert.Len(t, report.ExperimentVariations, 0)
+}
+
+func TestRunIsSameAsSequentialEvaluation(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	flag := makeTestFlag(30000)
+	contexts := makeTestContexts(500)
+
+	expected := Report{
+		FlagKey:              flag.Key,
+		FlagVersion:          flag.Version,
+		Total:                len(contexts),
+		Variations:           make(map[int]int),
+		ReasonKinds:          make(map[ldreason.EvalReasonKind]int),
+		Rules:                make(map[int]RuleMatches),
+		Errors:               make(map[ldreason.EvalErrorKind]int),
+		ExperimentVariations: make(map[int]int),
+	}
+	for _, c := range contexts {
+		result := evaluator.Evaluate(&flag, c, nil)
+		expected.Variations[result.Detail.VariationIndex.IntValue()]++
+		expected.ReasonKinds[result.Detail.Reason.GetKind()]++
+		if result.Detail.Reason.GetKind() == ldreason.EvalReasonRuleMatch {
+			m := expected.Rules[result.Detail.Reason.GetRuleIndex()]
+			m.RuleID = result.Detail.Reason.GetRuleID()
+			m.Count++
+			expected.Rules[result.Detail.Reason.GetRuleIndex()] = m
+		}
+	}
+
+	for _, concurrency := range []int{0, 1, 3, 1000} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			assert.Equal(t, expected, Run(evaluator, &flag, contexts, OptionConcurrency(concurrency)))
+		})
+	}
+}
+
+func TestRunCountsExperimentVariations(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	flag := ldbuilders.NewFlagBuilder("flag").On(true).
+		Fallthrough(ldbuilders.Experiment(ldvalue.OptionalInt{},
+			ldbuilders.Bucket(0, 50000),
+			ldbuilders.Bucket(1, 50000),
+		)).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).
+		Build()
+
+	report := Run(evaluator, &flag, makeTestContexts(200))
+
+	assert.Equal(t, report.Variations, report.ExperimentVariations)
+	assert.Equal(t, 200, report.ExperimentVariations[0]+report.ExperimentVariations[1])
+}
+
+func TestRunDoesNotCountTrackedEventsAsExperimentVariations(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	flag := ldbuilders.NewFlagBuilder("flag").On(true).FallthroughVariation(1).TrackEventsFallthrough(true).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).
+		Build()
+
+	report := Run(evaluator, &flag, makeTestContexts(10))
+
+	assert.Equal(t, map[int]int{1: 10}, report.Variations)
+	assert.Len(t, report.ExperimentVariations, 0)
+}
+
+func TestRunCountsRulesByIndex(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	flag := ldbuilders.NewFlagBuilder("flag").On(true).OffVariation(0).FallthroughVariation(0).
+		AddRule(ldbuilders.NewRuleBuilder().ID("same-id").Variation(1).
+			Clauses(ldbuilders.Clause("key", ldmodel.OperatorIn, ldvalue.String("user0")))).
+		AddRule(ldbuilders.NewRuleBuilder().ID("same-id").Variation(1).
+			Clauses(ldbuilders.Clause("key", ldmodel.OperatorIn, ldvalue.String("user1")))).
+		AddRule(ldbuilders.NewRuleBuilder().Variation(1).
+			Clauses(ldbuilders.Clause("key", ldmodel.OperatorIn, ldvalue.String("user2")))).
+		Variations(ldvalue.String("a"), ldvalue.String("b")).
+		Build()
+
+	report := Run(evaluator, &flag, makeTestContexts(10))
+
+	assert.Equal(t, map[int]RuleMatches{
+		0: {RuleID: "same-id", Count: 1},
+		1: {RuleID: "same-id", Count: 1},
+		2: {Count: 1},
+	}, report.Rules)
+}
+
+func TestCompare(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	before := makeTestFlag(20000)
+	after := makeTestFlag(60000)
+	after.Version = 2
+	contexts := makeTestContexts(1000)
+
+	comparison := Compare(evaluator, &before, &after, contexts)
+
+	assert.Equal(t, Run(evaluator, &before, contexts), comparison.Before)
+	assert.Equal(t, Run(evaluator, &after, contexts), comparison.After)
+
+	// Increasing the rollout percentage of variation 1 can only move contexts from variation 0 to 1.
+	require.Len(t, comparison.Transitions, 1)
+	moved := comparison.Transitions[Transition{From: ldvalue.NewOptionalInt(0), To: ldvalue.NewOptionalInt(1)}]
+	assert.Equal(t, comparison.After.Variations[1]-comparison.Before.Variations[1], moved)
+	assert.Len(t, comparison.Changes, moved)
+	for i, c := range comparison.Changes {
+		if i > 0 {
+			assert.Greater(t, c.Index, comparison.Changes[i-1].Index)
+		}
+		assert.Equal(t, contexts[c.Index], c.Context)
+		assert.Equal(t, ldvalue.String("a"), c.Before.Value)
+		assert.Equal(t, ldvalue.String("b"), c.After.Value)
+	}
+}
+
+func TestCompareRollouts(t *testing.T) {
+	evaluator := evaluation.NewEvaluator(simpleDataProvider{})
+	before := evaluation.RolloutSpec{FlagKey: "flag", Salt: "salt",
+		Rollout: ldbuilders.Rollout(ldbuilders.Bucket(0, 50000), ldbuilders.Bucket(1, 50000)).Rollout}
+	after := before
+	after.Rollout = ldbuilders.Rollout(ldbuilders.Bucket(0, 30000), ldbuilders.Bucket(2, 20000),
+		ldbuilders.Bucket(1, 50000)).Rollout
+	contexts := makeTestContexts(10000)
+
+	comparison := CompareRollouts(evaluator, before, after, contexts)
+
+	assert.Equal(t, 10000, comparison.Before.Total)
+	assert.Equal(t, 2, comparison.After.FlagVersion)
+	require.Len(t, comparison.Transitions, 1)
+	moved := comparison.Transitions[Transition{From: ldvalue.NewOptionalInt(0), To: ldvalue.NewOptionalInt(2)}]
+	assert.Equal(t, comparison.After.Variations[2], moved)
+	estimate := evaluation.EstimateRolloutImpact(before, after)
+	assert.InDelta(t, estimate.TotalMoved, float64(moved)/float64(len(contexts)), 0.02)
+	for _, c := range comparison.Changes {
+		assert.Equal(t, ldvalue.Int(2), c.After.Value)
+	}
+}